@@ -18,7 +18,21 @@ const (
 	scryptDKLen = 32
 )
 
+// ScryptParams represents the scrypt key derivation parameters.
+type ScryptParams struct {
+	N, R, P int
+}
+
 var (
+	// StandardScryptParams uses 256MB memory and takes about 1s on a modern processor.
+	StandardScryptParams = ScryptParams{N: 1 << 18, R: 8, P: 1}
+
+	// LightScryptParams uses 4MB memory and takes about 100ms on a modern processor.
+	LightScryptParams = ScryptParams{N: 1 << 12, R: 8, P: 6}
+
+	// LegacyScryptParams are the parameters used by GetDerivedKey.
+	LegacyScryptParams = ScryptParams{N: scryptN, R: scryptR, P: scryptP}
+
 	// ErrDecrypt represents the decrypt error message.
 	ErrDecrypt = errors.New("could not decrypt key with given passphrase")
 )
@@ -57,6 +71,48 @@ func GetDerivedKey(auth string, salt []byte) []byte {
 	return derivedKey
 }
 
+// DeriveKey derives a 32 bytes key from auth using scrypt with params p.
+func DeriveKey(auth string, salt []byte, p ScryptParams) ([]byte, error) {
+	return scrypt.Key([]byte(auth), salt, p.N, p.R, p.P, scryptDKLen)
+}
+
+// GCMEncrypt encrypts plainText with AES-GCM using a random nonce, and returns
+// nonce || cipherText || tag. additionalData is authenticated but not encrypted.
+func GCMEncrypt(key, plainText, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := utils.GetRandomBytes(aead.NonceSize())
+	return aead.Seal(nonce, nonce, plainText, additionalData), nil
+}
+
+// GCMDecrypt decrypts the output of GCMEncrypt, and returns ErrDecrypt if
+// the data or additionalData was modified or the key is wrong.
+func GCMDecrypt(key, data, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrDecrypt
+	}
+	nonce, cipherText := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plainText, err := aead.Open(nil, nonce, cipherText, additionalData)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plainText, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	aesBlock, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(aesBlock)
+}
+
 // Encrypt encrypts a key(plain text) using the specified key into bytes
 // that can be decrypted later on.
 func Encrypt(derivedKey []byte, key []byte, iv []byte) ([]byte, error) {
@@ -78,6 +134,11 @@ func Decrypt(derivedKey []byte, cipherText []byte, iv, mac []byte) ([]byte, erro
 	return plainText, err
 }
 
+// DecryptCTR decrypts the output of Encrypt without checking a mac.
+func DecryptCTR(derivedKey []byte, cipherText []byte, iv []byte) ([]byte, error) {
+	return aesCTRXOR(derivedKey[:16], cipherText, iv)
+}
+
 // PKCS7Padding or PKCS5UnPadding padding plain text.
 func PKCS7Padding(plainText []byte, blockSize int) []byte {
 	padding := blockSize - len(plainText)%blockSize
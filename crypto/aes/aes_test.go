@@ -38,3 +38,33 @@ func TestCBCEncryptWithBase64(t *testing.T) {
 		t.Errorf("enc result error %x %x %x", enc, key, iv)
 	}
 }
+
+func TestGCMEncrypt(t *testing.T) {
+	derivedKey, err := DeriveKey(password, salt, LightScryptParams)
+	if err != nil {
+		t.Fatalf("DeriveKey error %s", err)
+	}
+	ad := []byte("address")
+	cipherText, err := GCMEncrypt(derivedKey[:16], []byte(plainText), ad)
+	if err != nil {
+		t.Errorf("GCMEncrypt error %s", err)
+	}
+
+	other, _ := GCMEncrypt(derivedKey[:16], []byte(plainText), ad)
+	if bytes.Equal(cipherText, other) {
+		t.Errorf("GCMEncrypt reuses nonce")
+	}
+
+	decryptText, err := GCMDecrypt(derivedKey[:16], cipherText, ad)
+	if !bytes.Equal(decryptText, []byte(plainText)) {
+		t.Errorf("GCMDecrypt error %s", err)
+	}
+
+	if _, err := GCMDecrypt(derivedKey[:16], cipherText, []byte("other")); err != ErrDecrypt {
+		t.Errorf("GCMDecrypt with wrong additional data error %v", err)
+	}
+	cipherText[len(cipherText)-1] ^= 1
+	if _, err := GCMDecrypt(derivedKey[:16], cipherText, ad); err != ErrDecrypt {
+		t.Errorf("GCMDecrypt with modified cipher text error %v", err)
+	}
+}
@@ -0,0 +1,359 @@
+package bip32
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/maiiz/coinlib/crypto"
+	"github.com/maiiz/coinlib/crypto/secp256k1"
+	"github.com/maiiz/coinlib/encoding/base58"
+	"github.com/maiiz/coinlib/params"
+)
+
+const (
+	// HardenedKeyStart is the index of the first hardened child key.
+	HardenedKeyStart = 0x80000000
+
+	// Purpose is the BIP44 purpose field.
+	Purpose = 44
+
+	// ExternalChain is the BIP44 chain of receiving addresses.
+	ExternalChain = 0
+	// InternalChain is the BIP44 chain of change addresses.
+	InternalChain = 1
+
+	// MinSeedSize is the minimum length of the master seed.
+	MinSeedSize = 16
+	// MaxSeedSize is the maximum length of the master seed.
+	MaxSeedSize = 64
+
+	// serializedKeyLen is the length of a serialized extended key:
+	// version(4) depth(1) fingerprint(4) child(4) chaincode(32) key(33).
+	serializedKeyLen = 4 + 1 + 4 + 4 + 32 + 33
+)
+
+var (
+	masterKey = []byte("Bitcoin seed")
+
+	ErrInvalidSeed       = errors.New("seed length must be between 128 and 512 bits")
+	ErrUnusableSeed      = errors.New("seed produces an invalid master key")
+	ErrInvalidChild      = errors.New("derived child key is invalid")
+	ErrDeriveHardFromPub = errors.New("cannot derive a hardened key from a public key")
+	ErrNotPrivate        = errors.New("extended key is not a private key")
+	ErrInvalidKeyLen     = errors.New("serialized extended key length is invalid")
+	ErrBadChecksum       = errors.New("bad extended key checksum")
+	ErrWrongVersion      = errors.New("extended key version does not match chain")
+	ErrInvalidPath       = errors.New("invalid derivation path")
+)
+
+// ExtendedKey represents a BIP32 extended private or public key.
+type ExtendedKey struct {
+	chain     *params.ChainParams
+	key       []byte // 32 bytes private key or 33 bytes compressed public key
+	chainCode []byte
+	parentFP  []byte
+	depth     uint8
+	childNum  uint32
+	isPrivate bool
+}
+
+// NewMaster creates the master extended key from seed.
+func NewMaster(seed []byte, chain *params.ChainParams) (*ExtendedKey, error) {
+	if len(seed) < MinSeedSize || len(seed) > MaxSeedSize {
+		return nil, ErrInvalidSeed
+	}
+
+	mac := hmac.New(sha512.New, masterKey)
+	mac.Write(seed)
+	l := mac.Sum(nil)
+
+	k := new(big.Int).SetBytes(l[:32])
+	if k.Sign() == 0 || k.Cmp(secp256k1.N) >= 0 {
+		return nil, ErrUnusableSeed
+	}
+
+	return &ExtendedKey{
+		chain:     chain,
+		key:       l[:32],
+		chainCode: l[32:],
+		parentFP:  []byte{0, 0, 0, 0},
+		isPrivate: true,
+	}, nil
+}
+
+// IsPrivate reports whether the extended key is a private key.
+func (k *ExtendedKey) IsPrivate() bool { return k.isPrivate }
+
+// Depth returns the depth of the key in the tree, the master key is 0.
+func (k *ExtendedKey) Depth() uint8 { return k.depth }
+
+// ChildIndex returns the index this key was derived with.
+func (k *ExtendedKey) ChildIndex() uint32 { return k.childNum }
+
+// ChainCode returns the chain code of the key.
+func (k *ExtendedKey) ChainCode() []byte { return append([]byte{}, k.chainCode...) }
+
+// ParentFingerprint returns the fingerprint of the parent key.
+func (k *ExtendedKey) ParentFingerprint() uint32 { return binary.BigEndian.Uint32(k.parentFP) }
+
+// Fingerprint returns the fingerprint of the key, the first 4 bytes of hash160(pubkey).
+func (k *ExtendedKey) Fingerprint() uint32 {
+	return binary.BigEndian.Uint32(crypto.Hash160(k.PublicKeyBytes())[:4])
+}
+
+// PublicKeyBytes returns the compressed public key.
+func (k *ExtendedKey) PublicKeyBytes() []byte {
+	if !k.isPrivate {
+		return k.key
+	}
+	return secp256k1.ToECDSA(k.key).Public().CompressedBytes()
+}
+
+// PrivateKey returns the secp256k1 private key.
+func (k *ExtendedKey) PrivateKey() (*secp256k1.PrivateKey, error) {
+	if !k.isPrivate {
+		return nil, ErrNotPrivate
+	}
+	return secp256k1.ToECDSA(k.key), nil
+}
+
+// PublicKey returns the secp256k1 public key.
+func (k *ExtendedKey) PublicKey() (*secp256k1.PublicKey, error) {
+	pub, err := secp256k1.DecompressPubkey(k.PublicKeyBytes())
+	if err != nil {
+		return nil, err
+	}
+	return (*secp256k1.PublicKey)(pub), nil
+}
+
+// Child derives the child extended key at index i.
+// Indexes starting at HardenedKeyStart derive hardened keys.
+func (k *ExtendedKey) Child(i uint32) (*ExtendedKey, error) {
+	isHardened := i >= HardenedKeyStart
+	if isHardened && !k.isPrivate {
+		return nil, ErrDeriveHardFromPub
+	}
+
+	data := make([]byte, 0, 37)
+	if isHardened {
+		data = append(data, 0x00)
+		data = append(data, k.key...)
+	} else {
+		data = append(data, k.PublicKeyBytes()...)
+	}
+	data = append(data, uint32Bytes(i)...)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	l := mac.Sum(nil)
+
+	il := new(big.Int).SetBytes(l[:32])
+	if il.Cmp(secp256k1.N) >= 0 {
+		return nil, ErrInvalidChild
+	}
+
+	var childKey []byte
+	if k.isPrivate {
+		il.Add(il, new(big.Int).SetBytes(k.key))
+		il.Mod(il, secp256k1.N)
+		if il.Sign() == 0 {
+			return nil, ErrInvalidChild
+		}
+		childKey = paddedBytes(il, 32)
+	} else {
+		curve := secp256k1.S256()
+		pub, err := secp256k1.DecompressPubkey(k.key)
+		if err != nil {
+			return nil, err
+		}
+		x, y := curve.ScalarBaseMult(l[:32])
+		x, y = curve.Add(x, y, pub.X, pub.Y)
+		if x.Sign() == 0 && y.Sign() == 0 {
+			return nil, ErrInvalidChild
+		}
+		childKey = secp256k1.PublicKey{Curve: curve, X: x, Y: y}.CompressedBytes()
+	}
+
+	return &ExtendedKey{
+		chain:     k.chain,
+		key:       childKey,
+		chainCode: l[32:],
+		parentFP:  crypto.Hash160(k.PublicKeyBytes())[:4],
+		depth:     k.depth + 1,
+		childNum:  i,
+		isPrivate: k.isPrivate,
+	}, nil
+}
+
+// Derive derives the descendant key following path.
+func (k *ExtendedKey) Derive(path []uint32) (*ExtendedKey, error) {
+	var err error
+	key := k
+	for _, i := range path {
+		if key, err = key.Child(i); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// Neuter returns the extended public key of k.
+func (k *ExtendedKey) Neuter() *ExtendedKey {
+	if !k.isPrivate {
+		return k
+	}
+	return &ExtendedKey{
+		chain:     k.chain,
+		key:       k.PublicKeyBytes(),
+		chainCode: k.chainCode,
+		parentFP:  k.parentFP,
+		depth:     k.depth,
+		childNum:  k.childNum,
+		isPrivate: false,
+	}
+}
+
+// Serialize returns the 78 bytes serialization of the key, without checksum.
+func (k *ExtendedKey) Serialize() []byte {
+	buf := new(bytes.Buffer)
+	if k.isPrivate {
+		buf.Write(k.chain.HDPrivateKeyPrefix[:])
+	} else {
+		buf.Write(k.chain.HDPublicKeyPrefix[:])
+	}
+	buf.WriteByte(k.depth)
+	buf.Write(k.parentFP)
+	buf.Write(uint32Bytes(k.childNum))
+	buf.Write(k.chainCode)
+	if k.isPrivate {
+		buf.WriteByte(0x00)
+	}
+	buf.Write(k.key)
+	return buf.Bytes()
+}
+
+// String returns the base58check encoded key, known as xprv/xpub.
+func (k *ExtendedKey) String() string {
+	b := k.Serialize()
+	checkSum := crypto.DoubleSha256(b)
+	return base58.StdEncoding.Encode(append(b, checkSum[:4]...))
+}
+
+// ParseExtendedKey decodes a base58check xprv/xpub of the chain.
+func ParseExtendedKey(s string, chain *params.ChainParams) (*ExtendedKey, error) {
+	b := base58.StdEncoding.Decode(s)
+	if len(b) != serializedKeyLen+4 {
+		return nil, ErrInvalidKeyLen
+	}
+
+	payload, sum := b[:serializedKeyLen], b[serializedKeyLen:]
+	checkSum := crypto.DoubleSha256(payload)
+	if !bytes.Equal(checkSum[:4], sum) {
+		return nil, ErrBadChecksum
+	}
+
+	var (
+		version   = payload[:4]
+		isPrivate = payload[45] == 0x00
+	)
+	if (isPrivate && !bytes.Equal(version, chain.HDPrivateKeyPrefix[:])) ||
+		(!isPrivate && !bytes.Equal(version, chain.HDPublicKeyPrefix[:])) {
+		return nil, ErrWrongVersion
+	}
+
+	k := &ExtendedKey{
+		chain:     chain,
+		depth:     payload[4],
+		parentFP:  append([]byte{}, payload[5:9]...),
+		childNum:  binary.BigEndian.Uint32(payload[9:13]),
+		chainCode: append([]byte{}, payload[13:45]...),
+		isPrivate: isPrivate,
+	}
+	if isPrivate {
+		k.key = append([]byte{}, payload[46:78]...)
+		d := new(big.Int).SetBytes(k.key)
+		if d.Sign() == 0 || d.Cmp(secp256k1.N) >= 0 {
+			return nil, ErrInvalidChild
+		}
+	} else {
+		k.key = append([]byte{}, payload[45:78]...)
+		if _, err := secp256k1.DecompressPubkey(k.key); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// ParsePath parses a derivation path such as m/44'/0'/0'/0/1.
+// Both ' and h mark hardened indexes.
+func ParsePath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if len(parts) == 0 || parts[0] != "m" {
+		return nil, ErrInvalidPath
+	}
+
+	result := make([]uint32, 0, len(parts)-1)
+	for _, p := range parts[1:] {
+		var offset uint32
+		if strings.HasSuffix(p, "'") || strings.HasSuffix(p, "h") || strings.HasSuffix(p, "H") {
+			offset = HardenedKeyStart
+			p = p[:len(p)-1]
+		}
+		i, err := strconv.ParseUint(p, 10, 32)
+		if err != nil || i >= HardenedKeyStart {
+			return nil, ErrInvalidPath
+		}
+		result = append(result, uint32(i)+offset)
+	}
+	return result, nil
+}
+
+// FormatPath returns the string form of a derivation path.
+func FormatPath(path []uint32) string {
+	s := "m"
+	for _, i := range path {
+		if i >= HardenedKeyStart {
+			s += fmt.Sprintf("/%d'", i-HardenedKeyStart)
+		} else {
+			s += fmt.Sprintf("/%d", i)
+		}
+	}
+	return s
+}
+
+// BIP44Account returns the path m/44'/coin'/account'.
+func BIP44Account(coinType, account uint32) []uint32 {
+	return []uint32{
+		Purpose + HardenedKeyStart,
+		coinType + HardenedKeyStart,
+		account + HardenedKeyStart,
+	}
+}
+
+// BIP44Path returns the path m/44'/coin'/account'/change/index.
+func BIP44Path(coinType, account, change, index uint32) []uint32 {
+	return append(BIP44Account(coinType, account), change, index)
+}
+
+func uint32Bytes(i uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, i)
+	return b
+}
+
+func paddedBytes(n *big.Int, size int) []byte {
+	b := n.Bytes()
+	if len(b) >= size {
+		return b
+	}
+	result := make([]byte, size)
+	copy(result[size-len(b):], b)
+	return result
+}
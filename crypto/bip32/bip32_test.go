@@ -0,0 +1,92 @@
+package bip32
+
+import (
+	"testing"
+
+	"github.com/maiiz/coinlib/params"
+	"github.com/maiiz/coinlib/utils"
+)
+
+// Test vector 1 of BIP32.
+var vectorTests = []struct {
+	path string
+	xpub string
+	xprv string
+}{
+	{
+		"m",
+		"xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8",
+		"xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi",
+	},
+	{
+		"m/0'",
+		"xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw",
+		"xprv9uHRZZhk6KAJC1avXpDAp4MDc3sQKNxDiPvvkX8Br5ngLNv1TxvUxt4cV1rGL5hj6KCesnDYUhd7oWgT11eZG7XnxHrnYeSvkzY7d2bhkJ7",
+	},
+	{
+		"m/0'/1",
+		"xpub6ASuArnXKPbfEwhqN6e3mwBcDTgzisQN1wXN9BJcM47sSikHjJf3UFHKkNAWbWMiGj7Wf5uMash7SyYq527Hqck2AxYysAA7xmALppuCkwQ",
+		"xprv9wTYmMFdV23N2TdNG573QoEsfRrWKQgWeibmLntzniatZvR9BmLnvSxqu53Kw1UmYPxLgboyZQaXwTCg8MSY3H2EU4pWcQDnRnrVA1xe8fs",
+	},
+	{
+		"m/0'/1/2'",
+		"xpub6D4BDPcP2GT577Vvch3R8wDkScZWzQzMMUm3PWbmWvVJrZwQY4VUNgqFJPMM3No2dFDFGTsxxpG5uJh7n7epu4trkrX7x7DogT5Uv6fcLW5",
+		"xprv9z4pot5VBttmtdRTWfWQmoH1taj2axGVzFqSb8C9xaxKymcFzXBDptWmT7FwuEzG3ryjH4ktypQSAewRiNMjANTtpgP4mLTj34bhnZX7UiM",
+	},
+}
+
+func TestVector(t *testing.T) {
+	seed := utils.HexToBytes("000102030405060708090a0b0c0d0e0f")
+	chain := params.SelectChain(params.BTC)
+	master, err := NewMaster(seed, chain)
+	if err != nil {
+		t.Fatalf("NewMaster error %v", err)
+	}
+
+	for _, test := range vectorTests {
+		path, err := ParsePath(test.path)
+		if err != nil {
+			t.Fatalf("ParsePath(%s) error %v", test.path, err)
+		}
+		key, err := master.Derive(path)
+		if err != nil {
+			t.Fatalf("Derive(%s) error %v", test.path, err)
+		}
+		if key.String() != test.xprv {
+			t.Errorf("%s xprv = %s, except %s", test.path, key.String(), test.xprv)
+		}
+		if key.Neuter().String() != test.xpub {
+			t.Errorf("%s xpub = %s, except %s", test.path, key.Neuter().String(), test.xpub)
+		}
+		if FormatPath(path) != test.path {
+			t.Errorf("FormatPath = %s, except %s", FormatPath(path), test.path)
+		}
+
+		parsed, err := ParseExtendedKey(test.xprv, chain)
+		if err != nil || parsed.String() != test.xprv {
+			t.Errorf("ParseExtendedKey(%s) error %v", test.xprv, err)
+		}
+	}
+}
+
+func TestPublicDerivation(t *testing.T) {
+	chain := params.SelectChain(params.BTC)
+	master, _ := NewMaster(utils.HexToBytes("000102030405060708090a0b0c0d0e0f"), chain)
+	account, _ := master.Derive(BIP44Account(chain.HDCoinType, 0))
+
+	priv, err := account.Derive([]uint32{InternalChain, 7})
+	if err != nil {
+		t.Fatalf("private derivation error %v", err)
+	}
+	pub, err := account.Neuter().Derive([]uint32{InternalChain, 7})
+	if err != nil {
+		t.Fatalf("public derivation error %v", err)
+	}
+	if priv.Neuter().String() != pub.String() {
+		t.Errorf("public derivation mismatch %s != %s", priv.Neuter().String(), pub.String())
+	}
+
+	if _, err := account.Neuter().Child(HardenedKeyStart); err != ErrDeriveHardFromPub {
+		t.Errorf("hardened derivation from public key, got %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package bip39
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/maiiz/coinlib/utils"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// SeedSize represents the length of the seed derived from a mnemonic.
+	SeedSize = 64
+
+	seedIterations = 2048
+	bitsPerWord    = 11
+)
+
+var (
+	wordIndex = make(map[string]int, len(englishWords))
+
+	bigOne       = big.NewInt(1)
+	bigWordsMask = big.NewInt(1<<bitsPerWord - 1)
+
+	ErrEntropyLength   = errors.New("entropy length must be [128, 256] and a multiple of 32")
+	ErrInvalidMnemonic = errors.New("invalid mnemonic word count")
+	ErrUnknownWord     = errors.New("mnemonic contains a word not in the wordlist")
+	ErrChecksum        = errors.New("mnemonic checksum mismatch")
+)
+
+func init() {
+	for i, w := range englishWords {
+		wordIndex[w] = i
+	}
+}
+
+// NewEntropy returns random entropy of bitSize bits,
+// 128 bits for a 12 words mnemonic and 256 bits for 24 words.
+func NewEntropy(bitSize int) ([]byte, error) {
+	if err := validateEntropyBits(bitSize); err != nil {
+		return nil, err
+	}
+	return utils.GetRandomBytes(bitSize / 8), nil
+}
+
+// GenerateMnemonic returns a new random mnemonic of bitSize bits entropy.
+func GenerateMnemonic(bitSize int) (string, error) {
+	entropy, err := NewEntropy(bitSize)
+	if err != nil {
+		return "", err
+	}
+	return NewMnemonic(entropy)
+}
+
+// NewMnemonic encodes entropy to mnemonic words.
+func NewMnemonic(entropy []byte) (string, error) {
+	entropyBits := len(entropy) * 8
+	if err := validateEntropyBits(entropyBits); err != nil {
+		return "", err
+	}
+
+	// entropy || first ENT/32 bits of sha256(entropy)
+	var (
+		checksumBits = entropyBits / 32
+		wordCount    = (entropyBits + checksumBits) / bitsPerWord
+		h            = sha256.Sum256(entropy)
+		b            = new(big.Int).SetBytes(entropy)
+	)
+	b.Lsh(b, uint(checksumBits))
+	b.Or(b, big.NewInt(int64(h[0]>>uint(8-checksumBits))))
+
+	words := make([]string, wordCount)
+	idx := new(big.Int)
+	for i := wordCount - 1; i >= 0; i-- {
+		idx.And(b, bigWordsMask)
+		words[i] = englishWords[idx.Int64()]
+		b.Rsh(b, bitsPerWord)
+	}
+	return strings.Join(words, " "), nil
+}
+
+// EntropyFromMnemonic decodes mnemonic to entropy and verifies the checksum.
+func EntropyFromMnemonic(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words)%3 != 0 || len(words) < 12 || len(words) > 24 {
+		return nil, ErrInvalidMnemonic
+	}
+
+	b := new(big.Int)
+	for _, w := range words {
+		i, ok := wordIndex[w]
+		if !ok {
+			return nil, ErrUnknownWord
+		}
+		b.Lsh(b, bitsPerWord)
+		b.Or(b, big.NewInt(int64(i)))
+	}
+
+	var (
+		checksumBits = len(words) * bitsPerWord / 33
+		entropyBits  = checksumBits * 32
+		checksum     = new(big.Int).And(b, new(big.Int).Sub(new(big.Int).Lsh(bigOne, uint(checksumBits)), bigOne))
+	)
+	b.Rsh(b, uint(checksumBits))
+
+	entropy := make([]byte, entropyBits/8)
+	raw := b.Bytes()
+	copy(entropy[len(entropy)-len(raw):], raw)
+
+	h := sha256.Sum256(entropy)
+	if int64(h[0]>>uint(8-checksumBits)) != checksum.Int64() {
+		return nil, ErrChecksum
+	}
+	return entropy, nil
+}
+
+// IsMnemonicValid reports whether mnemonic has valid words and checksum.
+func IsMnemonicValid(mnemonic string) bool {
+	_, err := EntropyFromMnemonic(mnemonic)
+	return err == nil
+}
+
+// NewSeed derives the 64 bytes seed from mnemonic and passphrase,
+// the mnemonic is not validated.
+func NewSeed(mnemonic, passphrase string) []byte {
+	password := norm.NFKD.String(strings.Join(strings.Fields(mnemonic), " "))
+	salt := norm.NFKD.String("mnemonic" + passphrase)
+	return pbkdf2.Key([]byte(password), []byte(salt), seedIterations, SeedSize, sha512.New)
+}
+
+// MnemonicToSeed validates mnemonic and derives the seed.
+func MnemonicToSeed(mnemonic, passphrase string) ([]byte, error) {
+	if _, err := EntropyFromMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+	return NewSeed(mnemonic, passphrase), nil
+}
+
+func validateEntropyBits(bitSize int) error {
+	if bitSize%32 != 0 || bitSize < 128 || bitSize > 256 {
+		return ErrEntropyLength
+	}
+	return nil
+}
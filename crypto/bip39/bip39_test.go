@@ -0,0 +1,96 @@
+package bip39
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// Test vectors of BIP39, the passphrase is "TREZOR".
+var vectorTests = []struct {
+	entropy  string
+	mnemonic string
+	seed     string
+}{
+	{
+		"00000000000000000000000000000000",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+	},
+	{
+		"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+		"legal winner thank year wave sausage worth useful legal winner thank yellow",
+		"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+	},
+	{
+		"ffffffffffffffffffffffffffffffff",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+		"ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069",
+	},
+	{
+		"808080808080808080808080808080808080808080808080",
+		"letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic avoid letter always",
+		"107d7c02a5aa6f38c58083ff74f04c607c2d2c0ecc55501dadd72d025b751bc27fe913ffb796f841c49b1d33b610cf0e91d3aa239027f5e99fe4ce9e5088cd65",
+	},
+	{
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art",
+		"bda85446c68413707090a52022edd26a1c9462295029f2e60cd7c4f2bbd3097170af7a4d73245cafa9c3cca8d561a7c3de6f5d4a10be8ed2a5e608d68f92fcc8",
+	},
+}
+
+var invalidMnemonicTests = []string{
+	"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon",
+	"legal winner thank year wave sausage worth useful legal winner thank yellow yellow",
+	"letter advice cage absurd amount doctor acoustic avoid letter advice caged above",
+	"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo why",
+	"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon letter",
+}
+
+func TestVector(t *testing.T) {
+	for _, test := range vectorTests {
+		entropy, _ := hex.DecodeString(test.entropy)
+		mnemonic, err := NewMnemonic(entropy)
+		if err != nil || mnemonic != test.mnemonic {
+			t.Errorf("NewMnemonic(%s) = %s, except %s, err %v", test.entropy, mnemonic, test.mnemonic, err)
+		}
+
+		decoded, err := EntropyFromMnemonic(test.mnemonic)
+		if err != nil || !bytes.Equal(decoded, entropy) {
+			t.Errorf("EntropyFromMnemonic(%s) = %x, except %s, err %v", test.mnemonic, decoded, test.entropy, err)
+		}
+
+		seed, err := MnemonicToSeed(test.mnemonic, "TREZOR")
+		if err != nil || hex.EncodeToString(seed) != test.seed {
+			t.Errorf("MnemonicToSeed(%s) = %x, except %s, err %v", test.mnemonic, seed, test.seed, err)
+		}
+	}
+}
+
+func TestInvalidMnemonic(t *testing.T) {
+	for _, mnemonic := range invalidMnemonicTests {
+		if IsMnemonicValid(mnemonic) {
+			t.Errorf("IsMnemonicValid(%s) = true, except false", mnemonic)
+		}
+	}
+}
+
+func TestGenerateMnemonic(t *testing.T) {
+	for _, bits := range []int{128, 256} {
+		mnemonic, err := GenerateMnemonic(bits)
+		if err != nil {
+			t.Fatalf("GenerateMnemonic(%d) error %v", bits, err)
+		}
+		if n := len(strings.Fields(mnemonic)); n != bits/32*3 {
+			t.Errorf("GenerateMnemonic(%d) has %d words", bits, n)
+		}
+		if !IsMnemonicValid(mnemonic) {
+			t.Errorf("GenerateMnemonic(%d) is invalid: %s", bits, mnemonic)
+		}
+	}
+
+	if _, err := GenerateMnemonic(100); err != ErrEntropyLength {
+		t.Errorf("GenerateMnemonic(100) error %v, except %v", err, ErrEntropyLength)
+	}
+}
@@ -80,6 +80,19 @@ func DoubleSha256(data []byte) Hash {
 	return NewHash(h[:])
 }
 
+// TaggedHash calculates the BIP340 tagged hash of the input data:
+// sha256(sha256(tag) || sha256(tag) || data).
+func TaggedHash(tag string, data ...[]byte) Hash {
+	tagHash := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	for _, d := range data {
+		h.Write(d)
+	}
+	return NewHash(h.Sum(nil))
+}
+
 // Hash160 calculates hash for bitcoin/litecoin/bcc address.
 func Hash160(d []byte) []byte {
 	h := sha256.Sum256(d)
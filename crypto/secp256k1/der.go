@@ -0,0 +1,155 @@
+package secp256k1
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+)
+
+var (
+	errInvalidDER    = errors.New("invalid DER signature")
+	errInvalidPubkey = errors.New("invalid public key")
+)
+
+// DER returns the signature in strict DER with low S, the format required by
+// bitcoin scripts (BIP66 and BIP62 rule 5):
+// 0x30 <len> 0x02 <len R> <R> 0x02 <len S> <S>.
+func (sig *Signature) DER() []byte {
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:64])
+	if s.Cmp(halfN) > 0 {
+		s.Sub(N, s)
+	}
+
+	rb, sb := derInteger(r), derInteger(s)
+	b := make([]byte, 0, 6+len(rb)+len(sb))
+	b = append(b, 0x30, byte(4+len(rb)+len(sb)))
+	b = append(b, 0x02, byte(len(rb)))
+	b = append(b, rb...)
+	b = append(b, 0x02, byte(len(sb)))
+	return append(b, sb...)
+}
+
+// derInteger returns the minimal big-endian bytes of the positive integer,
+// prefixed with zero if the high bit is set.
+func derInteger(n *big.Int) []byte {
+	b := n.Bytes()
+	if len(b) == 0 || b[0]&0x80 != 0 {
+		b = append([]byte{0x00}, b...)
+	}
+	return b
+}
+
+// ParseDERSignature parses the r and s of a DER signature as laxly as bitcoin
+// consensus does, some BER encodings are accepted. An r or s larger than 32
+// bytes is returned as zero, which never verifies.
+func ParseDERSignature(sig []byte) (r, s *big.Int, err error) {
+	pos := 0
+	// sequence tag and length
+	if pos == len(sig) || sig[pos] != 0x30 {
+		return nil, nil, errInvalidDER
+	}
+	pos++
+	if pos == len(sig) {
+		return nil, nil, errInvalidDER
+	}
+	if n := int(sig[pos]); n&0x80 != 0 {
+		n -= 0x80
+		if n > len(sig)-pos-1 {
+			return nil, nil, errInvalidDER
+		}
+		pos += n
+	}
+	pos++
+
+	rb, pos, err := derLaxInteger(sig, pos)
+	if err != nil {
+		return nil, nil, err
+	}
+	sb, _, err := derLaxInteger(sig, pos)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rb, sb = bytes.TrimLeft(rb, "\x00"), bytes.TrimLeft(sb, "\x00")
+	if len(rb) > 32 || len(sb) > 32 {
+		return new(big.Int), new(big.Int), nil
+	}
+	return new(big.Int).SetBytes(rb), new(big.Int).SetBytes(sb), nil
+}
+
+// derLaxInteger returns the bytes of the integer element at pos and the
+// position after it.
+func derLaxInteger(sig []byte, pos int) ([]byte, int, error) {
+	if pos >= len(sig) || sig[pos] != 0x02 {
+		return nil, 0, errInvalidDER
+	}
+	pos++
+	if pos == len(sig) {
+		return nil, 0, errInvalidDER
+	}
+	n := int(sig[pos])
+	pos++
+	if n&0x80 != 0 {
+		n -= 0x80
+		if n > len(sig)-pos {
+			return nil, 0, errInvalidDER
+		}
+		for n > 0 && sig[pos] == 0 {
+			pos++
+			n--
+		}
+		if n >= 8 {
+			return nil, 0, errInvalidDER
+		}
+		size := 0
+		for ; n > 0; n-- {
+			size = size<<8 + int(sig[pos])
+			pos++
+		}
+		n = size
+	}
+	if n > len(sig)-pos {
+		return nil, 0, errInvalidDER
+	}
+	return sig[pos : pos+n], pos + n, nil
+}
+
+// IsLowS reports whether the s of the DER signature is not larger than N/2.
+func IsLowS(sig []byte) bool {
+	_, s, err := ParseDERSignature(sig)
+	return err == nil && s.Cmp(halfN) <= 0
+}
+
+// ParsePubkey parses a public key in the compressed, uncompressed or hybrid
+// format.
+func ParsePubkey(pubkey []byte) (*PublicKey, error) {
+	switch {
+	case len(pubkey) == 33 && (pubkey[0] == 0x02 || pubkey[0] == 0x03):
+		pub, err := DecompressPubkey(pubkey)
+		if err != nil {
+			return nil, err
+		}
+		return (*PublicKey)(pub), nil
+
+	case len(pubkey) == 65 && (pubkey[0] == 0x04 || pubkey[0] == 0x06 || pubkey[0] == 0x07):
+		x := new(big.Int).SetBytes(pubkey[1:33])
+		y := new(big.Int).SetBytes(pubkey[33:])
+		if !S256().IsOnCurve(x, y) || (pubkey[0] != 0x04 && pubkey[0]&1 != byte(y.Bit(0))) {
+			return nil, errInvalidPubkey
+		}
+		return &PublicKey{Curve: S256(), X: x, Y: y}, nil
+	}
+	return nil, errInvalidPubkey
+}
+
+// VerifyDER reports whether the DER signature of hash is signed by the public
+// key, a high s is accepted.
+func (pub *PublicKey) VerifyDER(hash, sig []byte) bool {
+	r, s, err := ParseDERSignature(sig)
+	if err != nil {
+		return false
+	}
+	return ecdsa.Verify((*ecdsa.PublicKey)(pub), hash, r, s)
+}
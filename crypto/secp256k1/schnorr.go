@@ -0,0 +1,228 @@
+package secp256k1
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/maiiz/coinlib/crypto"
+	"github.com/maiiz/coinlib/utils"
+
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+var (
+	errInvalidPrivkey = errors.New("invalid private key")
+	errInvalidTweak   = errors.New("invalid taproot tweak")
+	errSchnorrSign    = errors.New("schnorr signing failed")
+)
+
+// XOnly returns the 32 bytes x coordinate of the public key, the x-only
+// public key of BIP340.
+func (pub *PublicKey) XOnly() []byte {
+	return math.PaddedBigBytes(pub.X, 32)
+}
+
+// ParseXOnlyPubkey returns the public key of the x coordinate with an even y,
+// the lift_x of BIP340.
+func ParseXOnlyPubkey(pubkey []byte) (*PublicKey, error) {
+	if len(pubkey) != 32 {
+		return nil, errInvalidPubkey
+	}
+	p := S256().Params().P
+	x := new(big.Int).SetBytes(pubkey)
+	if x.Cmp(p) >= 0 {
+		return nil, errInvalidPubkey
+	}
+	// y^2 = x^3 + 7
+	c := new(big.Int).Exp(x, big.NewInt(3), p)
+	c.Add(c, big.NewInt(7))
+	c.Mod(c, p)
+	y := new(big.Int).ModSqrt(c, p)
+	if y == nil {
+		return nil, errInvalidPubkey
+	}
+	if y.Bit(0) == 1 {
+		y.Sub(p, y)
+	}
+	return &PublicKey{Curve: S256(), X: x, Y: y}, nil
+}
+
+// scalarBaseMult returns kG, x is nil for the point at infinity.
+func scalarBaseMult(k *big.Int) (*big.Int, *big.Int) {
+	if k.Sign() == 0 {
+		return nil, nil
+	}
+	return S256().ScalarBaseMult(math.PaddedBigBytes(k, 32))
+}
+
+// scalarMult returns kP, x is nil for the point at infinity.
+func scalarMult(x, y, k *big.Int) (*big.Int, *big.Int) {
+	if k.Sign() == 0 {
+		return nil, nil
+	}
+	return S256().ScalarMult(x, y, math.PaddedBigBytes(k, 32))
+}
+
+// addPoints returns the sum of the points, x is nil for the point at infinity.
+func addPoints(x1, y1, x2, y2 *big.Int) (*big.Int, *big.Int) {
+	switch {
+	case x1 == nil:
+		return x2, y2
+	case x2 == nil:
+		return x1, y1
+	case x1.Cmp(x2) == 0:
+		if y1.Cmp(y2) != 0 {
+			return nil, nil
+		}
+		return S256().Double(x1, y1)
+	}
+	return S256().Add(x1, y1, x2, y2)
+}
+
+// schnorrChallenge returns the challenge e of the nonce point, the x-only
+// public key and the message.
+func schnorrChallenge(rx, pubkey, hash []byte) *big.Int {
+	h := crypto.TaggedHash("BIP0340/challenge", rx, pubkey, hash)
+	e := new(big.Int).SetBytes(h[:])
+	return e.Mod(e, N)
+}
+
+// SignSchnorr returns the 64 bytes BIP340 signature of the hash, auxRand is
+// 32 bytes of fresh randomness which is read from crypto/rand if nil.
+func (priv *PrivateKey) SignSchnorr(hash, auxRand []byte) ([]byte, error) {
+	if priv.D.Sign() == 0 || priv.D.Cmp(N) >= 0 {
+		return nil, errInvalidPrivkey
+	}
+	if auxRand == nil {
+		auxRand = make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, auxRand); err != nil {
+			return nil, err
+		}
+	}
+
+	// the private key of the public key with an even y
+	d := new(big.Int).Set(priv.D)
+	px, py := scalarBaseMult(d)
+	if py.Bit(0) == 1 {
+		d.Sub(N, d)
+	}
+	pubkey := math.PaddedBigBytes(px, 32)
+
+	t := math.PaddedBigBytes(d, 32)
+	defer utils.ZeroMemory(t)
+	aux := crypto.TaggedHash("BIP0340/aux", auxRand)
+	for i := range t {
+		t[i] ^= aux[i]
+	}
+	nonce := crypto.TaggedHash("BIP0340/nonce", t, pubkey, hash)
+	k := new(big.Int).SetBytes(nonce[:])
+	if k.Mod(k, N).Sign() == 0 {
+		return nil, errSchnorrSign
+	}
+	rx, ry := scalarBaseMult(k)
+	if ry.Bit(0) == 1 {
+		k.Sub(N, k)
+	}
+
+	r := math.PaddedBigBytes(rx, 32)
+	s := schnorrChallenge(r, pubkey, hash)
+	s.Mul(s, d)
+	s.Add(s, k)
+	s.Mod(s, N)
+	sig := append(r, math.PaddedBigBytes(s, 32)...)
+
+	// verify the signature against faults in the computation.
+	if !(&PublicKey{Curve: S256(), X: px, Y: py}).VerifySchnorr(hash, sig) {
+		return nil, errSchnorrSign
+	}
+	return sig, nil
+}
+
+// VerifySchnorr reports whether the 64 bytes BIP340 signature of hash is
+// signed by the public key, only the x coordinate of the key is used.
+func (pub *PublicKey) VerifySchnorr(hash, sig []byte) bool {
+	if len(sig) != 64 || pub.X == nil {
+		return false
+	}
+	p := S256().Params().P
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	if r.Cmp(p) >= 0 || s.Cmp(N) >= 0 {
+		return false
+	}
+
+	px, py := pub.X, pub.Y
+	if py.Bit(0) == 1 {
+		py = new(big.Int).Sub(p, py)
+	}
+	e := schnorrChallenge(sig[:32], pub.XOnly(), hash)
+
+	// R = sG - eP
+	sx, sy := scalarBaseMult(s)
+	ex, ey := scalarMult(px, py, e.Sub(N, e).Mod(e, N))
+	rx, ry := addPoints(sx, sy, ex, ey)
+	return rx != nil && ry.Bit(0) == 0 && rx.Cmp(r) == 0
+}
+
+// taprootTweak returns the tweak t of the x-only internal key committing to
+// the merkle root of the scripts.
+func taprootTweak(internalKey, merkleRoot []byte) (*big.Int, error) {
+	h := crypto.TaggedHash("TapTweak", internalKey, merkleRoot)
+	t := new(big.Int).SetBytes(h[:])
+	if t.Cmp(N) >= 0 {
+		return nil, errInvalidTweak
+	}
+	return t, nil
+}
+
+// TweakTaprootPubkey returns the x-only output key Q = P + tG of the x-only
+// internal key P and the parity of its y (BIP341). The merkle root of the
+// scripts is empty for an output without scripts.
+func TweakTaprootPubkey(internalKey, merkleRoot []byte) (outputKey []byte, parity byte, err error) {
+	pub, err := ParseXOnlyPubkey(internalKey)
+	if err != nil {
+		return nil, 0, err
+	}
+	t, err := taprootTweak(internalKey, merkleRoot)
+	if err != nil {
+		return nil, 0, err
+	}
+	tx, ty := scalarBaseMult(t)
+	qx, qy := addPoints(pub.X, pub.Y, tx, ty)
+	if qx == nil {
+		return nil, 0, errInvalidTweak
+	}
+	return math.PaddedBigBytes(qx, 32), byte(qy.Bit(0)), nil
+}
+
+// CheckTaprootTweak reports whether the x-only output key with the parity is
+// tweaked from the x-only internal key by the merkle root.
+func CheckTaprootTweak(internalKey, merkleRoot, outputKey []byte, parity byte) bool {
+	q, p, err := TweakTaprootPubkey(internalKey, merkleRoot)
+	return err == nil && p == parity && bytes.Equal(q, outputKey)
+}
+
+// TweakTaproot returns the private key of the output key tweaked from the
+// x-only public key of the key by TweakTaprootPubkey.
+func (priv *PrivateKey) TweakTaproot(merkleRoot []byte) (*PrivateKey, error) {
+	if priv.D.Sign() == 0 || priv.D.Cmp(N) >= 0 {
+		return nil, errInvalidPrivkey
+	}
+	d := new(big.Int).Set(priv.D)
+	px, py := scalarBaseMult(d)
+	if py.Bit(0) == 1 {
+		d.Sub(N, d)
+	}
+	t, err := taprootTweak(math.PaddedBigBytes(px, 32), merkleRoot)
+	if err != nil {
+		return nil, err
+	}
+	d.Add(d, t)
+	if d.Mod(d, N).Sign() == 0 {
+		return nil, errInvalidTweak
+	}
+	return ToECDSA(math.PaddedBigBytes(d, 32)), nil
+}
@@ -0,0 +1,96 @@
+package secp256k1
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
+	"io/ioutil"
+	"testing"
+)
+
+func TestSchnorr(t *testing.T) {
+	// test-vectors.csv of BIP340
+	data, err := ioutil.ReadFile("testdata/bip340_vectors.csv")
+	if err != nil {
+		t.Fatalf("ReadFile error %v", err)
+	}
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll error %v", err)
+	}
+
+	var priv0 *PrivateKey
+	for _, record := range records[1:] {
+		var (
+			index     = record[0]
+			xonly, _  = hex.DecodeString(record[2])
+			aux, _    = hex.DecodeString(record[3])
+			msg, _    = hex.DecodeString(record[4])
+			except, _ = hex.DecodeString(record[5])
+			result    = record[6] == "TRUE"
+		)
+		if record[1] != "" {
+			priv, _ := HexToECDSA(record[1])
+			if priv0 == nil {
+				priv0 = priv
+			}
+			sig, err := priv.SignSchnorr(msg, aux)
+			if err != nil {
+				t.Errorf("#%s SignSchnorr error %v", index, err)
+			} else if !bytes.Equal(sig, except) {
+				t.Errorf("#%s SignSchnorr %X, except %s", index, sig, record[5])
+			}
+			if pub := (*PublicKey)(&priv.PublicKey); !bytes.Equal(pub.XOnly(), xonly) {
+				t.Errorf("#%s XOnly %X, except %s", index, pub.XOnly(), record[2])
+			}
+		}
+
+		pub, err := ParseXOnlyPubkey(xonly)
+		if err != nil {
+			if result {
+				t.Errorf("#%s ParseXOnlyPubkey error %v", index, err)
+			}
+			continue
+		}
+		if ok := pub.VerifySchnorr(msg, except); ok != result {
+			t.Errorf("#%s VerifySchnorr %v, except %v (%s)", index, ok, result, record[7])
+		}
+	}
+
+	// the signatures of random aux are valid
+	sig, err := priv0.SignSchnorr(make([]byte, 32), nil)
+	if err != nil || !(*PublicKey)(&priv0.PublicKey).VerifySchnorr(make([]byte, 32), sig) {
+		t.Errorf("SignSchnorr %x error %v, except valid", sig, err)
+	}
+
+	// the field size is not a valid x coordinate
+	p, _ := hex.DecodeString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC2F")
+	if _, err := ParseXOnlyPubkey(p); err == nil {
+		t.Errorf("ParseXOnlyPubkey error nil, except invalid")
+	}
+}
+
+func TestTweakTaproot(t *testing.T) {
+	// BIP86 first receiving address
+	internalKey, _ := hex.DecodeString("cc8a4bc64d897bddc5fbc2f670f7a8ba0b386779106cf1223c6fc5d7cd6fc115")
+	outputKey, _ := hex.DecodeString("a60869f0dbcf1dc659c9cecbaf8050135ea9e8cdc487053f1dc6880949dc684c")
+	q, parity, err := TweakTaprootPubkey(internalKey, nil)
+	if err != nil || !bytes.Equal(q, outputKey) || parity != 1 {
+		t.Errorf("TweakTaprootPubkey %x %d error %v, except %x 1", q, parity, err, outputKey)
+	}
+	if !CheckTaprootTweak(internalKey, nil, outputKey, 1) || CheckTaprootTweak(internalKey, nil, outputKey, 0) {
+		t.Errorf("CheckTaprootTweak of parity not match")
+	}
+
+	// the tweaked private key signs for the output key
+	priv, _ := HexToECDSA("289c2857d4598e37fb9647507e47a309d6133539bf21a8b9cb6df88fd5232032")
+	root := bytes.Repeat([]byte{1}, 32)
+	tweaked, err := priv.TweakTaproot(root)
+	if err != nil {
+		t.Fatalf("TweakTaproot error %v", err)
+	}
+	q, _, _ = TweakTaprootPubkey((*PublicKey)(&priv.PublicKey).XOnly(), root)
+	if x := (*PublicKey)(&tweaked.PublicKey).XOnly(); !bytes.Equal(x, q) {
+		t.Errorf("TweakTaproot public key %x, except %x", x, q)
+	}
+}
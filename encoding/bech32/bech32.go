@@ -0,0 +1,231 @@
+package bech32
+
+import (
+	"errors"
+	"strings"
+)
+
+const charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+var gen = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+// the checksum constants of bech32 (BIP173) and bech32m (BIP350).
+const (
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+var (
+	ErrInvalidLength   = errors.New("invalid bech32 length")
+	ErrInvalidChar     = errors.New("invalid bech32 character")
+	ErrMixedCase       = errors.New("mixed case bech32 string")
+	ErrInvalidChecksum = errors.New("invalid bech32 checksum")
+	ErrInvalidPadding  = errors.New("invalid bech32 padding")
+	ErrInvalidProgram  = errors.New("invalid witness program")
+	ErrInvalidHRP      = errors.New("bech32 hrp not match")
+)
+
+func polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+func hrpExpand(hrp string) []byte {
+	b := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		b = append(b, hrp[i]>>5)
+	}
+	b = append(b, 0)
+	for i := 0; i < len(hrp); i++ {
+		b = append(b, hrp[i]&31)
+	}
+	return b
+}
+
+func checksum(hrp string, data []byte, c uint32) []byte {
+	values := append(hrpExpand(hrp), data...)
+	values = append(values, 0, 0, 0, 0, 0, 0)
+	mod := polymod(values) ^ c
+	sum := make([]byte, 6)
+	for i := range sum {
+		sum[i] = byte(mod>>uint(5*(5-i))) & 31
+	}
+	return sum
+}
+
+// Encode returns the bech32 string of the hrp and the 5 bits data.
+func Encode(hrp string, data []byte) (string, error) {
+	return encode(hrp, data, bech32Const)
+}
+
+// EncodeM returns the bech32m string of the hrp and the 5 bits data.
+func EncodeM(hrp string, data []byte) (string, error) {
+	return encode(hrp, data, bech32mConst)
+}
+
+func encode(hrp string, data []byte, c uint32) (string, error) {
+	if len(hrp)+len(data)+7 > 90 {
+		return "", ErrInvalidLength
+	}
+	hrp = strings.ToLower(hrp)
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, d := range append(data[:len(data):len(data)], checksum(hrp, data, c)...) {
+		if d > 31 {
+			return "", ErrInvalidChar
+		}
+		sb.WriteByte(charset[d])
+	}
+	return sb.String(), nil
+}
+
+// Decode returns the hrp and the 5 bits data of the bech32 string.
+func Decode(s string) (string, []byte, error) {
+	hrp, data, c, err := decode(s)
+	if err == nil && c != bech32Const {
+		err = ErrInvalidChecksum
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return hrp, data, nil
+}
+
+// DecodeM returns the hrp and the 5 bits data of the bech32m string.
+func DecodeM(s string) (string, []byte, error) {
+	hrp, data, c, err := decode(s)
+	if err == nil && c != bech32mConst {
+		err = ErrInvalidChecksum
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return hrp, data, nil
+}
+
+// decode returns the hrp, the 5 bits data and the checksum constant of the
+// string, the checksum is checked by the callers.
+func decode(s string) (string, []byte, uint32, error) {
+	if len(s) < 8 || len(s) > 90 {
+		return "", nil, 0, ErrInvalidLength
+	}
+	lower := strings.ToLower(s)
+	if lower != s && strings.ToUpper(s) != s {
+		return "", nil, 0, ErrMixedCase
+	}
+	s = lower
+
+	pos := strings.LastIndexByte(s, '1')
+	if pos < 1 || pos+7 > len(s) {
+		return "", nil, 0, ErrInvalidLength
+	}
+	hrp := s[:pos]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, 0, ErrInvalidChar
+		}
+	}
+
+	data := make([]byte, 0, len(s)-pos-1)
+	for i := pos + 1; i < len(s); i++ {
+		d := strings.IndexByte(charset, s[i])
+		if d < 0 {
+			return "", nil, 0, ErrInvalidChar
+		}
+		data = append(data, byte(d))
+	}
+	c := polymod(append(hrpExpand(hrp), data...))
+	return hrp, data[:len(data)-6], c, nil
+}
+
+// ConvertBits regroups the bits of data from fromBits to toBits per byte, the
+// last group is padded with zero if pad.
+func ConvertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var (
+		acc    uint32
+		bits   uint
+		result []byte
+		maxv   = uint32(1)<<toBits - 1
+	)
+	for _, b := range data {
+		if uint32(b)>>fromBits != 0 {
+			return nil, ErrInvalidChar
+		}
+		acc = acc<<fromBits | uint32(b)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			result = append(result, byte(acc>>bits&maxv))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			result = append(result, byte(acc<<(toBits-bits)&maxv))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxv != 0 {
+		return nil, ErrInvalidPadding
+	}
+	return result, nil
+}
+
+// EncodeSegwitAddress returns the address of the witness program, bech32
+// (BIP173) for version 0 and bech32m (BIP350) for the later versions.
+func EncodeSegwitAddress(hrp string, version byte, program []byte) (string, error) {
+	if err := checkProgram(version, program); err != nil {
+		return "", err
+	}
+	data, err := ConvertBits(program, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	if version == 0 {
+		return Encode(hrp, append([]byte{version}, data...))
+	}
+	return EncodeM(hrp, append([]byte{version}, data...))
+}
+
+// DecodeSegwitAddress returns the witness version and program of the address
+// of hrp.
+func DecodeSegwitAddress(hrp, addr string) (byte, []byte, error) {
+	h, data, c, err := decode(addr)
+	if err != nil {
+		return 0, nil, err
+	}
+	if h != strings.ToLower(hrp) {
+		return 0, nil, ErrInvalidHRP
+	}
+	if len(data) < 1 {
+		return 0, nil, ErrInvalidProgram
+	}
+	if (data[0] == 0 && c != bech32Const) || (data[0] != 0 && c != bech32mConst) {
+		return 0, nil, ErrInvalidChecksum
+	}
+	program, err := ConvertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := checkProgram(data[0], program); err != nil {
+		return 0, nil, err
+	}
+	return data[0], program, nil
+}
+
+func checkProgram(version byte, program []byte) error {
+	if version > 16 || len(program) < 2 || len(program) > 40 {
+		return ErrInvalidProgram
+	}
+	if version == 0 && len(program) != 20 && len(program) != 32 {
+		return ErrInvalidProgram
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package bech32
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// Test vectors of BIP173.
+var validChecksums = []string{
+	"A12UEL5L",
+	"a12uel5l",
+	"an83characterlonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1tt5tgs",
+	"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw",
+	"11qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqc8247j",
+	"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w",
+}
+
+var invalidChecksums = []string{
+	"\x201nwldj5",
+	"pzry9x0s0muk",
+	"1pzry9x0s0muk",
+	"x1b4n0q5v",
+	"li1dgmt3",
+	"A1G7SGD8",
+	"10a06t8",
+	"1qzzfhee",
+}
+
+// Test vectors of BIP350.
+var validChecksumsM = []string{
+	"A1LQFN3A",
+	"a1lqfn3a",
+	"abcdef1l7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx",
+	"?1v759aa",
+}
+
+var segwitTests = []struct {
+	hrp, addr string
+	version   byte
+	program   string
+}{
+	{"bc", "BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", 0, "751e76e8199196d454941c45d1b3a323f1433bd6"},
+	{"tb", "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", 0, "1863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262"},
+	{"tb", "tb1qqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesrxh6hy", 0, "000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433"},
+	{"bc", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", 1, "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+}
+
+func TestChecksum(t *testing.T) {
+	for _, s := range validChecksums {
+		hrp, data, err := Decode(s)
+		if err != nil {
+			t.Errorf("Decode(%s) error %v", s, err)
+			continue
+		}
+		encoded, err := Encode(hrp, data)
+		if err != nil || encoded != strings.ToLower(s) {
+			t.Errorf("Encode = %s, except %s, err %v", encoded, strings.ToLower(s), err)
+		}
+	}
+	for _, s := range invalidChecksums {
+		if _, _, err := Decode(s); err == nil {
+			t.Errorf("Decode(%q) of invalid string", s)
+		}
+	}
+
+	for _, s := range validChecksumsM {
+		hrp, data, err := DecodeM(s)
+		if err != nil {
+			t.Errorf("DecodeM(%s) error %v", s, err)
+			continue
+		}
+		encoded, err := EncodeM(hrp, data)
+		if err != nil || encoded != strings.ToLower(s) {
+			t.Errorf("EncodeM = %s, except %s, err %v", encoded, strings.ToLower(s), err)
+		}
+		// the checksums of bech32 and bech32m are not interchangeable
+		if _, _, err := Decode(s); err != ErrInvalidChecksum {
+			t.Errorf("Decode(%s) of bech32m error %v, except %v", s, err, ErrInvalidChecksum)
+		}
+	}
+	if _, _, err := DecodeM(validChecksums[0]); err != ErrInvalidChecksum {
+		t.Errorf("DecodeM(%s) of bech32 error %v, except %v", validChecksums[0], err, ErrInvalidChecksum)
+	}
+}
+
+func TestSegwitAddress(t *testing.T) {
+	for _, test := range segwitTests {
+		version, program, err := DecodeSegwitAddress(test.hrp, test.addr)
+		if err != nil || version != test.version || hex.EncodeToString(program) != test.program {
+			t.Errorf("DecodeSegwitAddress(%s) = %d %x, err %v", test.addr, version, program, err)
+		}
+		b, _ := hex.DecodeString(test.program)
+		addr, err := EncodeSegwitAddress(test.hrp, test.version, b)
+		if err != nil || addr != strings.ToLower(test.addr) {
+			t.Errorf("EncodeSegwitAddress = %s, except %s, err %v", addr, strings.ToLower(test.addr), err)
+		}
+	}
+
+	if _, _, err := DecodeSegwitAddress("tb", segwitTests[0].addr); err != ErrInvalidHRP {
+		t.Errorf("DecodeSegwitAddress of another hrp error %v, except %v", err, ErrInvalidHRP)
+	}
+	// the version 1 address in bech32 and the version 0 address in bech32m
+	for _, addr := range []string{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh"} {
+		if _, _, err := DecodeSegwitAddress("bc", addr); err != ErrInvalidChecksum {
+			t.Errorf("DecodeSegwitAddress(%s) error %v, except %v", addr, err, ErrInvalidChecksum)
+		}
+	}
+	// invalid program length of version 0
+	if _, err := EncodeSegwitAddress("bc", 0, make([]byte, 16)); err != ErrInvalidProgram {
+		t.Errorf("EncodeSegwitAddress of 16 bytes program error %v, except %v", err, ErrInvalidProgram)
+	}
+}
@@ -1,14 +1,18 @@
 package keystore
 
 import (
+	"bytes"
 	"crypto/ecdsa"
-	"encoding/binary"
 	"errors"
 	"fmt"
-	"os"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/maiiz/coinlib/crypto"
 	"github.com/maiiz/coinlib/crypto/aes"
+	"github.com/maiiz/coinlib/crypto/bip32"
+	"github.com/maiiz/coinlib/crypto/bip39"
 	"github.com/maiiz/coinlib/crypto/secp256k1"
 	"github.com/maiiz/coinlib/params"
 	"github.com/maiiz/coinlib/utils"
@@ -17,16 +21,26 @@ import (
 const (
 	encryptKeySize          = 32
 	walletFile              = "wallet.dat"
+	addrsFile               = "addrs.txt"
+	changesFile             = "changes.txt"
 	changeAddressNum uint32 = 20
+	hdSeedSize              = 32
+	// maxAppendKeys is the most keys appended by a single AppendKeys.
+	maxAppendKeys = 10000
 )
 
 var (
-	walletMagic = utils.HexToBytes("0901419396d7679bf46d7c0c28a7a8eb2d793bea3c9bea222e7eedc77dc7e174")
-
 	ErrNoWalletFile    = errors.New("no wallet file")
 	ErrKeyNotFind      = errors.New("key not find")
 	ErrWrongPasspharse = errors.New("mac not match")
 	ErrFileNotEmpty    = errors.New("wallet file not empty")
+	ErrNotHDWallet     = errors.New("not a hd wallet")
+	ErrInvalidMagic    = errors.New("invalid wallet magic")
+	ErrLegacyWallet    = errors.New("legacy wallet needs passphrase to upgrade")
+	ErrKeyExists       = errors.New("key already exists")
+	ErrLocked          = errors.New("wallet is locked")
+	ErrInvalidKeyNum   = errors.New("invalid number of keys")
+	ErrEmptyPassphrase = errors.New("empty passphrase")
 )
 
 type (
@@ -35,170 +49,530 @@ type (
 	EncryptKey [encryptKeySize]byte
 )
 
-// KeyStore represents the key storage manager.
+// KeyInfo represents the metadata of a wallet key.
+type KeyInfo struct {
+	Address utils.Address
+	Chain   string
+	Created time.Time
+	Label   string
+	// Change reports whether the key is a change (internal) key.
+	Change bool
+	// Index is the child index of a hd key, or the sequence of a random key.
+	Index uint32
+	// Imported reports whether the key was imported, it has no index.
+	Imported bool
+}
+
+// keyEntry represents a wallet key with its encrypted private key.
+type keyEntry struct {
+	KeyInfo
+	key []byte
+}
+
+// KeyStore represents the key storage manager, it is safe for concurrent use.
 type KeyStore struct {
-	file          *os.File
-	keys          map[utils.Address][]byte
-	salt, iv, mac []byte
+	mu sync.Mutex
+	// path is the wallet file path, the address files are written next to it.
+	path  string
+	chain *params.ChainParams
+
+	keys      map[utils.Address]*keyEntry
+	entries   []*keyEntry
+	salt, mac []byte
+	scrypt    aes.ScryptParams
+	// seed is the encrypted master seed of a hd wallet, nil otherwise.
+	seed []byte
+	// legacy is set if the wallet file is of version 0, its keys are
+	// re-encrypted on the first use of the passphrase.
+	legacy *legacyInfo
+
+	// unlocked is the cached derived key while the wallet is unlocked.
+	unlocked  []byte
+	lockTimer *time.Timer
 }
 
-// New returns a new keystore instance.
+// New returns a new keystore instance of wallet.dat in the working directory,
+// using the selected chain params.Params.
 func New() *KeyStore {
+	return NewKeyStore(walletFile, nil)
+}
+
+// NewKeyStore returns a new keystore instance of the wallet file path and chain.
+// The address files are written in the directory of the wallet file.
+func NewKeyStore(path string, chain *params.ChainParams) *KeyStore {
 	return &KeyStore{
-		keys: make(map[utils.Address][]byte),
-		salt: make([]byte, 32),
-		iv:   make([]byte, 16),
-		mac:  make([]byte, 32),
+		path:   path,
+		chain:  chain,
+		keys:   make(map[utils.Address]*keyEntry),
+		salt:   make([]byte, 32),
+		mac:    make([]byte, 32),
+		scrypt: aes.LightScryptParams,
 	}
 }
 
-// Open opens the wallet file and load wallet data.
-func (ks *KeyStore) open() (err error) {
-	f, err := utils.OpenFile(walletFile)
+// SetScryptParams sets the scrypt parameters used to encrypt a new wallet,
+// the parameters of an existing wallet are read from its file.
+func (ks *KeyStore) SetScryptParams(p aes.ScryptParams) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.scrypt = p
+}
+
+// GenerateKeys generate many pair of private/public key, and write it to file.
+func (ks *KeyStore) GenerateKeys(num uint32, auth string) error {
+	return ks.generate(nil, num, auth)
+}
+
+// GenerateHDKeys derives keys from the master seed along the BIP44 path
+// m/44'/coin'/0'/change/index and writes them to file, the change addresses
+// are derived on the internal chain. A random seed is used if seed is nil.
+func (ks *KeyStore) GenerateHDKeys(seed []byte, num uint32, auth string) error {
+	if seed == nil {
+		seed = utils.GetRandomBytes(hdSeedSize)
+	}
+	if _, err := bip32.NewMaster(seed, ks.params()); err != nil {
+		return err
+	}
+	return ks.generate(seed, num, auth)
+}
+
+// GenerateMnemonicKeys creates a hd wallet from a new mnemonic of bitSize bits
+// entropy (128 for 12 words, 256 for 24 words), and returns the mnemonic.
+// The mnemonic and passphrase are all needed to restore the wallet.
+func (ks *KeyStore) GenerateMnemonicKeys(bitSize int, passphrase string, num uint32, auth string) (string, error) {
+	mnemonic, err := bip39.GenerateMnemonic(bitSize)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
-	ks.file = f
-	return err
+	if err := ks.RestoreFromMnemonic(mnemonic, passphrase, num, auth); err != nil {
+		return "", err
+	}
+	return mnemonic, nil
 }
 
-func (ks *KeyStore) write(b []byte) (n int, err error) { return ks.file.Write(b) }
-func (ks *KeyStore) close() error                      { return ks.file.Close() }
-func (ks *KeyStore) read(b []byte) (n int, err error)  { return ks.file.Read(b) }
+// RestoreFromMnemonic rebuilds the hd wallet file from mnemonic and passphrase.
+func (ks *KeyStore) RestoreFromMnemonic(mnemonic, passphrase string, num uint32, auth string) error {
+	seed, err := bip39.MnemonicToSeed(mnemonic, passphrase)
+	if err != nil {
+		return err
+	}
+	defer utils.ZeroMemory(seed)
 
-// GenerateKeys generate many pair of private/public key, and write it to file.
-func (ks *KeyStore) GenerateKeys(num uint32, auth string) error {
-	ks.open()
-	if ks.file != nil {
-		fileInfo, err := ks.file.Stat()
-		if err != nil {
+	return ks.GenerateHDKeys(seed, num, auth)
+}
+
+// generate creates a new wallet file with changeAddressNum change keys and
+// num receive keys, the keys are derived from seed if it is not nil.
+func (ks *KeyStore) generate(seed []byte, num uint32, auth string) error {
+	if auth == "" {
+		return ErrEmptyPassphrase
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if !isEmptyFile(ks.path) {
+		return ErrFileNotEmpty
+	}
+
+	// EncryptInfo
+	ks.salt = utils.GetRandomBytes(32)
+	derivedKey, err := aes.DeriveKey(auth, ks.salt, ks.scrypt)
+	if err != nil {
+		return err
+	}
+	ks.mac = crypto.Keccak256(derivedKey[16:32])
+
+	if seed != nil {
+		if ks.seed, err = encryptSeed(derivedKey, seed); err != nil {
 			return err
-		} else if fileInfo.Size() > 0 {
-			return ErrFileNotEmpty
 		}
-		// Write Address
-		addrFile, _ := utils.OpenFile("addrs.txt")
-		defer addrFile.Close()
-
-		// Write Change Address
-		changeFile, _ := utils.OpenFile("changes.txt")
-		defer changeFile.Close()
-
-		// Write EncryptInfo
-		ks.salt, ks.iv = aes.GenEncryptInfo()
-		derivedKey := aes.GetDerivedKey(auth, ks.salt)
-		ks.mac = crypto.Keccak256(derivedKey[16:32])
-
-		ks.write(walletMagic)
-		ks.write(ks.salt)
-		ks.write(ks.iv)
-		ks.write(ks.mac)
-
-		// write length
-		buf := make([]byte, 4)
-		binary.LittleEndian.PutUint32(buf, num+changeAddressNum)
-		ks.write(buf)
-
-		// Write Address->Key
-		for i := uint32(0); i < num+changeAddressNum; i++ {
-			priv, pub := generateKey(params.Params.IsCompressed)
-			addr := params.Params.AddressHashFunc(pub)
-			encryptKey, err := aes.Encrypt(derivedKey[:16], priv, ks.iv)
-			if err != nil {
-				panic(err)
-			}
+	}
+
+	changes, err := ks.addKeys(derivedKey, changeAddressNum, true)
+	if err != nil {
+		return err
+	}
+	addrs, err := ks.addKeys(derivedKey, num, false)
+	if err != nil {
+		return err
+	}
+	if err := ks.save(); err != nil {
+		return err
+	}
+	return ks.exportAddresses(addrs, changes)
+}
+
+// AppendKeys appends num receive keys to wallet file, the keys of a hd wallet
+// are derived following the last index. num must be in 1..10000.
+func (ks *KeyStore) AppendKeys(num int, auth string) error {
+	return ks.appendKeys(num, false, auth)
+}
 
-			ks.write(addr)
-			if i < changeAddressNum {
-				changeFile.WriteString(params.Params.ToAddress(addr))
-				changeFile.WriteString("\n")
-			} else {
-				addrFile.WriteString(params.Params.ToAddress(addr))
-				addrFile.WriteString("\n")
+// AppendChangeKeys appends num change keys to wallet file.
+func (ks *KeyStore) AppendChangeKeys(num int, auth string) error {
+	return ks.appendKeys(num, true, auth)
+}
+
+func (ks *KeyStore) appendKeys(num int, change bool, auth string) error {
+	if num <= 0 || num > maxAppendKeys {
+		return ErrInvalidKeyNum
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if err := ks.loadIfEmpty(); err != nil {
+		return err
+	}
+
+	derivedKey, err := ks.derivedKey(auth)
+	if err != nil {
+		return err
+	}
+
+	added, err := ks.addKeys(derivedKey, uint32(num), change)
+	if err != nil {
+		return err
+	}
+	if err := ks.save(); err != nil {
+		return err
+	}
+	if change {
+		return ks.exportAddresses(nil, added)
+	}
+	return ks.exportAddresses(added, nil)
+}
+
+// addKeys creates num keys on the receive or change chain and adds them to the wallet.
+func (ks *KeyStore) addKeys(derivedKey []byte, num uint32, change bool) ([]*keyEntry, error) {
+	var (
+		account *bip32.ExtendedKey
+		err     error
+	)
+	if ks.seed != nil {
+		if account, err = ks.accountKey(derivedKey); err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		index = ks.nextIndex(change)
+		added = make([]*keyEntry, 0, num)
+	)
+	for i := index; i < index+num; i++ {
+		var priv, pub []byte
+		if account != nil {
+			chain := uint32(bip32.ExternalChain)
+			if change {
+				chain = bip32.InternalChain
+			}
+			if priv, pub, err = deriveKey(account, []uint32{chain, i}, ks.params().IsCompressed); err != nil {
+				return nil, err
 			}
-			// addrFile.WriteString(fmt.Sprintf("0x%x%x\n", addr, priv))
+		} else {
+			priv, pub = generateKey(ks.params().IsCompressed)
+		}
 
-			ks.write(encryptKey)
-			ks.keys[utils.BytesToAddress(addr)] = encryptKey
+		addr := utils.BytesToAddress(ks.params().AddressHashFunc(pub))
+		key, err := encryptKey(derivedKey, addr, priv)
+		utils.ZeroMemory(priv)
+		if err != nil {
+			return nil, err
+		}
 
+		entry := &keyEntry{
+			KeyInfo: KeyInfo{
+				Address: addr,
+				Chain:   ks.params().Currency,
+				Created: time.Now(),
+				Change:  change,
+				Index:   i,
+			},
+			key: key,
 		}
-		ks.close()
-		return nil
+		ks.addEntry(entry)
+		added = append(added, entry)
 	}
-	return ErrNoWalletFile
+	return added, nil
 }
 
-// Load loads wallet data.
-func (ks *KeyStore) Load() error {
-	if utils.FileExist(walletFile) {
-		if err := ks.open(); err != nil {
-			return err
+// importKey adds the private key to the wallet as an imported key.
+func (ks *KeyStore) importKey(priv []byte, auth string) (KeyInfo, error) {
+	derivedKey, err := ks.derivedKey(auth)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+
+	pub := secp256k1.ToECDSA(priv).Public()
+	pubBytes := pub.Bytes()
+	if ks.params().IsCompressed {
+		pubBytes = pub.CompressedBytes()
+	}
+	addr := utils.BytesToAddress(ks.params().AddressHashFunc(pubBytes))
+	if _, ok := ks.keys[addr]; ok {
+		return KeyInfo{}, ErrKeyExists
+	}
+	key, err := encryptKey(derivedKey, addr, priv)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+
+	entry := &keyEntry{
+		KeyInfo: KeyInfo{
+			Address:  addr,
+			Chain:    ks.params().Currency,
+			Created:  time.Now(),
+			Imported: true,
+		},
+		key: key,
+	}
+	ks.addEntry(entry)
+	if err := ks.save(); err != nil {
+		return KeyInfo{}, err
+	}
+	if err := ks.exportAddresses([]*keyEntry{entry}, nil); err != nil {
+		return KeyInfo{}, err
+	}
+	return entry.KeyInfo, nil
+}
+
+func (ks *KeyStore) addEntry(entry *keyEntry) {
+	if _, ok := ks.keys[entry.Address]; ok {
+		return
+	}
+	ks.keys[entry.Address] = entry
+	ks.entries = append(ks.entries, entry)
+}
+
+// nextIndex returns the next unused index on the receive or change chain.
+func (ks *KeyStore) nextIndex(change bool) uint32 {
+	var next uint32
+	for _, entry := range ks.entries {
+		if !entry.Imported && entry.Change == change && entry.Index >= next {
+			next = entry.Index + 1
 		}
+	}
+	return next
+}
 
-		// Read DecryptInfo
-		var (
-			magic = make([]byte, 32)
-			buf   = make([]byte, 4)
-		)
-		ks.read(magic)
-		ks.read(ks.salt)
-		ks.read(ks.iv)
-		ks.read(ks.mac)
-
-		ks.read(buf)
-		num := binary.LittleEndian.Uint32(buf)
-
-		for i := uint32(0); i < num+changeAddressNum; i++ {
-			var (
-				addr       = make([]byte, 20)
-				encryptKey = make([]byte, 32)
-			)
-			ks.read(addr)
-			ks.read(encryptKey)
-
-			ks.keys[utils.BytesToAddress(addr)] = encryptKey
+// exportAddresses appends the addresses to addrs.txt and changes.txt next to the wallet file.
+func (ks *KeyStore) exportAddresses(addrs, changes []*keyEntry) error {
+	for file, entries := range map[string][]*keyEntry{addrsFile: addrs, changesFile: changes} {
+		if len(entries) == 0 {
+			continue
+		}
+		f, err := utils.OpenFile(filepath.Join(filepath.Dir(ks.path), file))
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			f.WriteString(ks.params().ToAddress(entry.Address[:]))
+			f.WriteString("\n")
+		}
+		if err := f.Close(); err != nil {
+			return err
 		}
+	}
+	return nil
+}
+
+// Load loads wallet data, wallet files of older versions are re-encrypted in
+// the current version on the first use of the passphrase.
+func (ks *KeyStore) Load() error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	return ks.load()
+}
+
+// loadIfEmpty loads the wallet file if no key is in memory.
+func (ks *KeyStore) loadIfEmpty() error {
+	if len(ks.entries) != 0 {
 		return nil
 	}
-	return ErrNoWalletFile
+	return ks.load()
+}
+
+// Path returns the wallet file path.
+func (ks *KeyStore) Path() string {
+	return ks.path
+}
+
+// params returns the chain params of the keystore, or the selected params.Params.
+func (ks *KeyStore) params() *params.ChainParams {
+	if ks.chain != nil {
+		return ks.chain
+	}
+	return params.Params
+}
+
+// Keys returns the metadata of all wallet keys in file order.
+func (ks *KeyStore) Keys() []KeyInfo {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	infos := make([]KeyInfo, len(ks.entries))
+	for i, entry := range ks.entries {
+		infos[i] = entry.KeyInfo
+	}
+	return infos
+}
+
+// KeyInfo returns the metadata of the key of address.
+func (ks *KeyStore) KeyInfo(addr utils.Address) (KeyInfo, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	entry, ok := ks.keys[addr]
+	if !ok {
+		return KeyInfo{}, ErrKeyNotFind
+	}
+	return entry.KeyInfo, nil
+}
+
+// SetLabel sets the label of the key and writes it to file,
+// it fails with ErrLegacyWallet until a legacy wallet is re-encrypted.
+func (ks *KeyStore) SetLabel(addr utils.Address, label string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	entry, ok := ks.keys[addr]
+	if !ok {
+		return ErrKeyNotFind
+	}
+	entry.Label = label
+	return ks.save()
+}
+
+// IsHD reports whether the wallet keys are derived from a master seed.
+func (ks *KeyStore) IsHD() bool {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	return ks.seed != nil
+}
+
+// AccountKey returns the BIP44 account extended key m/44'/coin'/0' of a hd wallet,
+// use Neuter().String() to export the account xpub.
+func (ks *KeyStore) AccountKey(auth string) (*bip32.ExtendedKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ks.seed == nil {
+		return nil, ErrNotHDWallet
+	}
+
+	derivedKey, err := ks.derivedKey(auth)
+	if err != nil {
+		return nil, err
+	}
+	return ks.accountKey(derivedKey)
+}
+
+func (ks *KeyStore) accountKey(derivedKey []byte) (*bip32.ExtendedKey, error) {
+	seed, err := decryptSeed(derivedKey, ks.seed)
+	if err != nil {
+		return nil, err
+	}
+	defer utils.ZeroMemory(seed)
+
+	master, err := bip32.NewMaster(seed, ks.params())
+	if err != nil {
+		return nil, err
+	}
+	return master.Derive(bip32.BIP44Account(ks.params().HDCoinType, 0))
+}
+
+// derivedKey derives the encryption key from auth and verifies it against the mac,
+// a legacy wallet is re-encrypted first.
+func (ks *KeyStore) derivedKey(auth string) ([]byte, error) {
+	if ks.legacy != nil {
+		return ks.upgrade(auth)
+	}
+
+	derivedKey, err := aes.DeriveKey(auth, ks.salt, ks.scrypt)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(crypto.Keccak256(derivedKey[16:32]), ks.mac) {
+		return nil, ErrWrongPasspharse
+	}
+	return derivedKey, nil
 }
 
 // SignMessage signs message with all wallet keys.
 func (ks *KeyStore) SignMessage(message, auth string) error {
-	for k, v := range ks.keys {
-		derivedKey := aes.GetDerivedKey(auth, ks.salt)
-		privBytes, err := aes.Decrypt(derivedKey[:16], v, ks.iv, ks.mac)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	derivedKey, err := ks.derivedKey(auth)
+	if err != nil {
+		return err
+	}
+	for _, entry := range ks.entries {
+		privBytes, err := decryptKey(derivedKey, entry)
 		if err != nil {
 			return err
 		}
 		signature, _ := secp256k1.ToECDSA(privBytes).Sign(crypto.EthSignHash([]byte(message)))
-		fmt.Printf("0x%x,%x\n", k[:], signature.Bytes())
+		utils.ZeroMemory(privBytes)
+		fmt.Printf("0x%x,%x\n", entry.Address[:], signature.Bytes())
 	}
 	return nil
 }
 
 // GetPrivkey gets privatekey by address.
-func (ks KeyStore) GetPrivkey(addr utils.Address, auth string) (*ecdsa.PrivateKey, error) {
-	// ks.mu.Lock()
-	// defer ks.mu.UnLock()
-	encryptKey, ok := ks.keys[addr]
+func (ks *KeyStore) GetPrivkey(addr utils.Address, auth string) (*ecdsa.PrivateKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	_, privBytes, err := ks.privateKey(addr, auth)
+	if err != nil {
+		return nil, err
+	}
+	defer utils.ZeroMemory(privBytes)
+
+	return (*ecdsa.PrivateKey)(secp256k1.ToECDSA(privBytes)), err
+}
+
+// GetUnlockedPrivkey gets privatekey by address with the key cached by Unlock,
+// it returns ErrLocked if the wallet is locked.
+func (ks *KeyStore) GetUnlockedPrivkey(addr utils.Address) (*ecdsa.PrivateKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	derivedKey, err := ks.unlockedKey()
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := ks.keys[addr]
 	if !ok {
 		return nil, ErrKeyNotFind
 	}
-
-	derivedKey := aes.GetDerivedKey(auth, ks.salt)
-	privBytes, err := aes.Decrypt(derivedKey[:16], encryptKey, ks.iv, ks.mac)
+	privBytes, err := decryptKey(derivedKey, entry)
 	if err != nil {
 		return nil, err
 	}
+	defer utils.ZeroMemory(privBytes)
 
-	return (*ecdsa.PrivateKey)(secp256k1.ToECDSA(privBytes)), err
+	return (*ecdsa.PrivateKey)(secp256k1.ToECDSA(privBytes)), nil
 }
 
-// AppendKeys appends keys to wallet file.
-// TODO.
-func (ks *KeyStore) AppendKeys(num int, auth string) error {
-	return nil
+// privateKey returns the entry and the decrypted private key of address.
+func (ks *KeyStore) privateKey(addr utils.Address, auth string) (*keyEntry, []byte, error) {
+	entry, ok := ks.keys[addr]
+	if !ok {
+		return nil, nil, ErrKeyNotFind
+	}
+
+	derivedKey, err := ks.derivedKey(auth)
+	if err != nil {
+		return nil, nil, err
+	}
+	priv, err := decryptKey(derivedKey, entry)
+	if err != nil {
+		return nil, nil, err
+	}
+	return entry, priv, nil
 }
 
 func generateKey(compressed bool) (priv, pub []byte) {
@@ -212,3 +586,20 @@ func generateKey(compressed bool) (priv, pub []byte) {
 	}
 	return privKey.SecretBytes(), privKey.Public().Bytes()
 }
+
+// deriveKey derives the key of the account at path.
+func deriveKey(account *bip32.ExtendedKey, path []uint32, compressed bool) (priv, pub []byte, err error) {
+	key, err := account.Derive(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	privKey, err := key.PrivateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if compressed {
+		return privKey.SecretBytes(), privKey.Public().CompressedBytes(), nil
+	}
+	return privKey.SecretBytes(), privKey.Public().Bytes(), nil
+}
@@ -0,0 +1,479 @@
+package keystore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maiiz/coinlib/crypto"
+	"github.com/maiiz/coinlib/crypto/aes"
+	"github.com/maiiz/coinlib/crypto/secp256k1"
+	"github.com/maiiz/coinlib/params"
+	"github.com/maiiz/coinlib/utils"
+)
+
+const testAuth = "woyouyizhixiaomaolv"
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "keystore")
+	if err != nil {
+		t.Fatalf("TempDir error %v", err)
+	}
+	return dir
+}
+
+func TestGenerateAndAppendKeys(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, walletFile)
+	ks := NewKeyStore(path, params.GetChain(params.BTC))
+	if err := ks.GenerateHDKeys(nil, 5, ""); err != ErrEmptyPassphrase {
+		t.Errorf("GenerateHDKeys with empty passphrase error %v, except %v", err, ErrEmptyPassphrase)
+	}
+	if err := ks.GenerateHDKeys(nil, 5, testAuth); err != nil {
+		t.Fatalf("GenerateHDKeys error %v", err)
+	}
+	if err := ks.GenerateKeys(5, testAuth); err != ErrFileNotEmpty {
+		t.Errorf("GenerateKeys on existing wallet error %v, except %v", err, ErrFileNotEmpty)
+	}
+
+	ks = NewKeyStore(path, params.GetChain(params.BTC))
+	if err := ks.AppendKeys(3, "wrong"); err != ErrWrongPasspharse {
+		t.Errorf("AppendKeys with wrong passphrase error %v", err)
+	}
+	if err := ks.AppendKeys(3, testAuth); err != nil {
+		t.Fatalf("AppendKeys error %v", err)
+	}
+
+	ks = NewKeyStore(path, params.GetChain(params.BTC))
+	if err := ks.Load(); err != nil {
+		t.Fatalf("Load error %v", err)
+	}
+	keys := ks.Keys()
+	if len(keys) != int(changeAddressNum)+8 {
+		t.Fatalf("wallet has %d keys, except %d", len(keys), int(changeAddressNum)+8)
+	}
+	if last := keys[len(keys)-1]; last.Change || last.Index != 7 || last.Chain != params.BTC {
+		t.Errorf("unexpected last key %+v", last)
+	}
+	for _, key := range keys {
+		if _, err := ks.GetPrivkey(key.Address, testAuth); err != nil {
+			t.Errorf("GetPrivkey(%x) error %v", key.Address, err)
+		}
+	}
+
+	addrs, _ := ioutil.ReadFile(filepath.Join(dir, addrsFile))
+	if n := len(strings.Fields(string(addrs))); n != 8 {
+		t.Errorf("%s has %d addresses, except 8", addrsFile, n)
+	}
+}
+
+func TestAppendKeys(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	var (
+		path  = filepath.Join(dir, walletFile)
+		chain = params.GetChain(params.BTC)
+		seed  = bytes.Repeat([]byte{1}, hdSeedSize)
+	)
+	ks := NewKeyStore(path, chain)
+	if err := ks.GenerateHDKeys(seed, 2, testAuth); err != nil {
+		t.Fatalf("GenerateHDKeys error %v", err)
+	}
+	for _, num := range []int{-1, 0, maxAppendKeys + 1} {
+		if err := ks.AppendKeys(num, testAuth); err != ErrInvalidKeyNum {
+			t.Errorf("AppendKeys(%d) error %v, except %v", num, err, ErrInvalidKeyNum)
+		}
+	}
+	if err := ks.AppendKeys(3, testAuth); err != nil {
+		t.Fatalf("AppendKeys error %v", err)
+	}
+	if err := ks.AppendChangeKeys(2, testAuth); err != nil {
+		t.Fatalf("AppendChangeKeys error %v", err)
+	}
+
+	// the indexes continue on each chain after reload.
+	ks = NewKeyStore(path, chain)
+	if err := ks.AppendKeys(1, testAuth); err != nil {
+		t.Fatalf("AppendKeys of loaded wallet error %v", err)
+	}
+	var receive, change []uint32
+	for _, key := range ks.Keys() {
+		if key.Change {
+			change = append(change, key.Index)
+		} else {
+			receive = append(receive, key.Index)
+		}
+	}
+	for i, index := range receive {
+		if index != uint32(i) {
+			t.Errorf("receive key #%d has index %d", i, index)
+		}
+	}
+	for i, index := range change {
+		if index != uint32(i) {
+			t.Errorf("change key #%d has index %d", i, index)
+		}
+	}
+	if len(receive) != 6 || len(change) != int(changeAddressNum)+2 {
+		t.Fatalf("wallet has %d receive and %d change keys, except 6 and %d", len(receive), len(change), changeAddressNum+2)
+	}
+
+	// the appended keys are derived as a wallet generated with all of them.
+	all := NewKeyStore(filepath.Join(dir, "all", walletFile), chain)
+	os.MkdirAll(filepath.Join(dir, "all"), 0700)
+	if err := all.GenerateHDKeys(seed, 6, testAuth); err != nil {
+		t.Fatalf("GenerateHDKeys error %v", err)
+	}
+	for _, key := range all.Keys() {
+		info, err := ks.KeyInfo(key.Address)
+		if err != nil || info.Change != key.Change || info.Index != key.Index {
+			t.Errorf("appended wallet misses %x %+v", key.Address, key)
+		}
+	}
+}
+
+func TestRestoreFromMnemonic(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	chain := params.GetChain(params.LTC)
+	ks := NewKeyStore(filepath.Join(dir, "a", walletFile), chain)
+	os.MkdirAll(filepath.Join(dir, "a"), 0700)
+	mnemonic, err := ks.GenerateMnemonicKeys(128, "passphrase", 2, testAuth)
+	if err != nil {
+		t.Fatalf("GenerateMnemonicKeys error %v", err)
+	}
+
+	restored := NewKeyStore(filepath.Join(dir, "b", walletFile), chain)
+	os.MkdirAll(filepath.Join(dir, "b"), 0700)
+	if err := restored.RestoreFromMnemonic(mnemonic, "passphrase", 2, testAuth); err != nil {
+		t.Fatalf("RestoreFromMnemonic error %v", err)
+	}
+
+	for _, key := range ks.Keys() {
+		if _, err := restored.KeyInfo(key.Address); err != nil {
+			t.Errorf("restored wallet misses %x", key.Address)
+		}
+	}
+}
+
+// TestRestoreFromMnemonicVector checks the BIP44 keys of the BIP39 test
+// mnemonic with an empty passphrase.
+func TestRestoreFromMnemonicVector(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	const (
+		mnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+		// m/44'/0'/0'
+		accountXpub = "xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj"
+		// m/44'/0'/0'/0/0
+		address = "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA"
+	)
+	chain := params.GetChain(params.BTC)
+	ks := NewKeyStore(filepath.Join(dir, walletFile), chain)
+	if err := ks.RestoreFromMnemonic(mnemonic, "", 1, testAuth); err != nil {
+		t.Fatalf("RestoreFromMnemonic error %v", err)
+	}
+
+	account, err := ks.AccountKey(testAuth)
+	if err != nil {
+		t.Fatalf("AccountKey error %v", err)
+	}
+	if xpub := account.Neuter().String(); xpub != accountXpub {
+		t.Errorf("account xpub %s, except %s", xpub, accountXpub)
+	}
+	found := false
+	for _, key := range ks.Keys() {
+		if !key.Change && key.Index == 0 {
+			found = true
+			if addr := chain.ToAddress(key.Address[:]); addr != address {
+				t.Errorf("address %s, except %s", addr, address)
+			}
+		}
+	}
+	if !found {
+		t.Error("no receive key of index 0")
+	}
+}
+
+func TestWallets(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	wallets := NewWallets(dir)
+	btc, err := wallets.Open("btc", params.GetChain(params.BTC))
+	if err != nil {
+		t.Fatalf("Open error %v", err)
+	}
+	eth, err := wallets.Open("eth", params.GetChain(params.ETH))
+	if err != nil {
+		t.Fatalf("Open error %v", err)
+	}
+	if _, err := wallets.Open("btc", params.GetChain(params.BTC)); err != ErrWalletOpened {
+		t.Errorf("Open twice error %v", err)
+	}
+	if _, err := wallets.Open("../btc", params.GetChain(params.BTC)); err != ErrInvalidName {
+		t.Errorf("Open invalid name error %v", err)
+	}
+
+	if err := btc.GenerateKeys(1, testAuth); err != nil {
+		t.Fatalf("GenerateKeys error %v", err)
+	}
+	if err := eth.GenerateKeys(1, testAuth); err != nil {
+		t.Fatalf("GenerateKeys error %v", err)
+	}
+
+	names, err := wallets.List()
+	if err != nil || strings.Join(names, ",") != "btc,eth" {
+		t.Errorf("List = %v, error %v", names, err)
+	}
+	if !utils.FileExist(filepath.Join(dir, "eth", addrsFile)) {
+		t.Errorf("address file not written next to the wallet")
+	}
+
+	if err := btc.Unlock(testAuth, 0); err != nil {
+		t.Fatalf("Unlock error %v", err)
+	}
+	if err := wallets.Close("btc"); err != nil {
+		t.Fatalf("Close error %v", err)
+	}
+	if btc.IsUnlocked() {
+		t.Errorf("closed wallet is still unlocked")
+	}
+	if _, err := wallets.Get("btc"); err != ErrWalletNotOpened {
+		t.Errorf("Get closed wallet error %v, except %v", err, ErrWalletNotOpened)
+	}
+}
+
+// writeV0Wallet writes an unversioned wallet file of a random key, encrypted
+// with AES-CTR as the old keystore did.
+func writeV0Wallet(t *testing.T, path string, chain *params.ChainParams) (utils.Address, []byte) {
+	priv, pub := generateKey(chain.IsCompressed)
+	addr := utils.BytesToAddress(chain.AddressHashFunc(pub))
+
+	salt, iv := aes.GenEncryptInfo()
+	derivedKey := aes.GetDerivedKey(testAuth, salt)
+	key, err := aes.Encrypt(derivedKey[:16], priv, iv)
+	if err != nil {
+		t.Fatalf("Encrypt error %v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	buf.Write(walletMagic)
+	buf.Write(salt)
+	buf.Write(iv)
+	buf.Write(crypto.Keccak256(derivedKey[16:32]))
+	binary.Write(buf, binary.LittleEndian, uint32(1))
+	buf.Write(addr[:])
+	buf.Write(key)
+	if err := utils.WriteToFile(buf.Bytes(), path); err != nil {
+		t.Fatalf("WriteToFile error %v", err)
+	}
+	return addr, priv
+}
+
+func TestUpgradeLegacyWallet(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, walletFile)
+	chain := params.GetChain(params.BTC)
+	addr, priv := writeV0Wallet(t, path, chain)
+
+	ks := NewKeyStore(path, chain)
+	if err := ks.Load(); err != nil {
+		t.Fatalf("Load error %v", err)
+	}
+	if err := ks.SetLabel(addr, "legacy"); err != ErrLegacyWallet {
+		t.Errorf("SetLabel on legacy wallet error %v, except %v", err, ErrLegacyWallet)
+	}
+	if _, err := ks.GetPrivkey(addr, "wrong"); err != ErrWrongPasspharse {
+		t.Errorf("GetPrivkey with wrong passphrase error %v", err)
+	}
+	key, err := ks.GetPrivkey(addr, testAuth)
+	if err != nil {
+		t.Fatalf("GetPrivkey error %v", err)
+	}
+	if !bytes.Equal((*secp256k1.PrivateKey)(key).SecretBytes(), priv) {
+		t.Errorf("GetPrivkey = %x, except %x", (*secp256k1.PrivateKey)(key).SecretBytes(), priv)
+	}
+	if !utils.FileExist(path + ".v0") {
+		t.Errorf("legacy wallet is not kept")
+	}
+
+	ks = NewKeyStore(path, chain)
+	if err := ks.Load(); err != nil {
+		t.Fatalf("Load upgraded wallet error %v", err)
+	}
+	if ks.legacy != nil {
+		t.Errorf("upgraded wallet is still legacy")
+	}
+	if err := ks.SetLabel(addr, "upgraded"); err != nil {
+		t.Errorf("SetLabel error %v", err)
+	}
+	if key, err := ks.GetPrivkey(addr, testAuth); err != nil || !bytes.Equal((*secp256k1.PrivateKey)(key).SecretBytes(), priv) {
+		t.Errorf("GetPrivkey of upgraded wallet error %v", err)
+	}
+}
+
+func TestUnsupportedVersion(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, walletFile)
+	buf := new(bytes.Buffer)
+	buf.Write(fileMagic)
+	binary.Write(buf, binary.LittleEndian, uint32(1))
+	if err := utils.WriteToFile(buf.Bytes(), path); err != nil {
+		t.Fatalf("WriteToFile error %v", err)
+	}
+	if err := NewKeyStore(path, params.GetChain(params.BTC)).Load(); err != ErrUnsupportedVersion {
+		t.Errorf("Load version 1 wallet error %v, except %v", err, ErrUnsupportedVersion)
+	}
+}
+
+func TestScryptLimits(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, walletFile)
+	if err := NewKeyStore(path, params.GetChain(params.BTC)).GenerateKeys(1, testAuth); err != nil {
+		t.Fatalf("GenerateKeys error %v", err)
+	}
+	data, _ := ioutil.ReadFile(path)
+
+	// scryptN, scryptR and scryptP follow the magic, version and flags.
+	offset := len(fileMagic) + 8
+	for i, v := range []uint32{maxScryptN + 1, maxScryptR + 1, maxScryptP + 1} {
+		tampered := append([]byte{}, data...)
+		binary.LittleEndian.PutUint32(tampered[offset+4*i:], v)
+		if err := utils.WriteToFile(tampered, path); err != nil {
+			t.Fatalf("WriteToFile error %v", err)
+		}
+		if err := NewKeyStore(path, params.GetChain(params.BTC)).Load(); err != ErrInvalidWallet {
+			t.Errorf("#%d Load wallet of scrypt param %d error %v, except %v", i, v, err, ErrInvalidWallet)
+		}
+	}
+}
+
+func TestTamperedKey(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	ks := NewKeyStore(filepath.Join(dir, walletFile), params.GetChain(params.BTC))
+	if err := ks.GenerateKeys(1, testAuth); err != nil {
+		t.Fatalf("GenerateKeys error %v", err)
+	}
+
+	// a key moved to another address fails authentication.
+	first, second := ks.entries[0], ks.entries[1]
+	first.key, second.key = second.key, first.key
+	if _, err := ks.GetPrivkey(first.Address, testAuth); err != aes.ErrDecrypt {
+		t.Errorf("GetPrivkey of swapped key error %v, except %v", err, aes.ErrDecrypt)
+	}
+}
+
+func TestChangePassphrase(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, walletFile)
+	chain := params.GetChain(params.BTC)
+	ks := NewKeyStore(path, chain)
+	if err := ks.GenerateHDKeys(nil, 2, testAuth); err != nil {
+		t.Fatalf("GenerateHDKeys error %v", err)
+	}
+	old, _ := ioutil.ReadFile(path)
+	addr := ks.Keys()[0].Address
+	want, _ := ks.GetPrivkey(addr, testAuth)
+
+	if err := ks.ChangePassphrase(testAuth, ""); err != ErrEmptyPassphrase {
+		t.Errorf("ChangePassphrase to empty passphrase error %v", err)
+	}
+	if err := ks.ChangePassphrase("wrong", "new"); err != ErrWrongPasspharse {
+		t.Errorf("ChangePassphrase with wrong passphrase error %v", err)
+	}
+	if err := ks.ChangePassphrase(testAuth, "new"); err != nil {
+		t.Fatalf("ChangePassphrase error %v", err)
+	}
+	if backup, _ := ioutil.ReadFile(path + backupSuffix); !bytes.Equal(backup, old) {
+		t.Errorf("backup differs from the previous wallet file")
+	}
+
+	ks = NewKeyStore(path, chain)
+	if err := ks.Load(); err != nil {
+		t.Fatalf("Load error %v", err)
+	}
+	if _, err := ks.GetPrivkey(addr, testAuth); err != ErrWrongPasspharse {
+		t.Errorf("GetPrivkey with old passphrase error %v", err)
+	}
+	key, err := ks.GetPrivkey(addr, "new")
+	if err != nil || key.D.Cmp(want.D) != 0 {
+		t.Errorf("GetPrivkey with new passphrase error %v", err)
+	}
+	if err := ks.AppendKeys(1, "new"); err != nil {
+		t.Errorf("AppendKeys after ChangePassphrase error %v", err)
+	}
+}
+
+func TestUnlock(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	ks := NewKeyStore(filepath.Join(dir, walletFile), params.GetChain(params.BTC))
+	if err := ks.GenerateKeys(1, testAuth); err != nil {
+		t.Fatalf("GenerateKeys error %v", err)
+	}
+	addr := ks.Keys()[0].Address
+	if _, err := ks.GetUnlockedPrivkey(addr); err != ErrLocked {
+		t.Errorf("GetUnlockedPrivkey of locked wallet error %v, except %v", err, ErrLocked)
+	}
+	if err := ks.Unlock("wrong", 0); err != ErrWrongPasspharse {
+		t.Errorf("Unlock with wrong passphrase error %v", err)
+	}
+	if err := ks.Unlock(testAuth, 0); err != nil {
+		t.Fatalf("Unlock error %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ks.GetUnlockedPrivkey(addr); err != nil {
+				t.Errorf("GetUnlockedPrivkey of unlocked wallet error %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, err := ks.GetPrivkey(addr, ""); err != ErrWrongPasspharse {
+		t.Errorf("GetPrivkey of unlocked wallet without passphrase error %v", err)
+	}
+	if err := ks.ChangePassphrase("", "new"); err != ErrWrongPasspharse {
+		t.Errorf("ChangePassphrase of unlocked wallet without passphrase error %v", err)
+	}
+
+	cached := ks.unlocked
+	ks.Lock()
+	if ks.IsUnlocked() || !bytes.Equal(cached, make([]byte, len(cached))) {
+		t.Errorf("Lock does not zero the cached key")
+	}
+
+	if err := ks.Unlock(testAuth, 50*time.Millisecond); err != nil {
+		t.Fatalf("Unlock error %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if ks.IsUnlocked() {
+		t.Errorf("wallet is not locked after timeout")
+	}
+}
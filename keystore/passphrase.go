@@ -0,0 +1,105 @@
+package keystore
+
+import (
+	"io/ioutil"
+
+	"github.com/maiiz/coinlib/crypto"
+	"github.com/maiiz/coinlib/crypto/aes"
+	"github.com/maiiz/coinlib/utils"
+)
+
+// backupSuffix is appended to the wallet file path to keep the previous
+// wallet file on passphrase change.
+const backupSuffix = ".bak"
+
+// ChangePassphrase re-encrypts the wallet seed and keys under newAuth with a
+// new salt and writes the wallet atomically, the previous wallet file is kept
+// as path.bak. The wallet is locked after the change.
+func (ks *KeyStore) ChangePassphrase(oldAuth, newAuth string) error {
+	if newAuth == "" {
+		return ErrEmptyPassphrase
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if err := ks.loadIfEmpty(); err != nil {
+		return err
+	}
+
+	oldKey, err := ks.derivedKey(oldAuth)
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(ks.path)
+	if err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomic(data, ks.path+backupSuffix); err != nil {
+		return err
+	}
+
+	_, undo, err := ks.reencrypt(newAuth, func(data, additionalData []byte) ([]byte, error) {
+		return aes.GCMDecrypt(oldKey[:16], data, additionalData)
+	})
+	if err != nil {
+		return err
+	}
+	if err := ks.save(); err != nil {
+		undo()
+		return err
+	}
+	ks.lock()
+	return nil
+}
+
+// reencrypt decrypts the seed and keys with decrypt, and encrypts them under
+// the key derived from auth with a new salt. The wallet is changed in memory
+// only if all succeed, undo restores it.
+func (ks *KeyStore) reencrypt(auth string, decrypt func(data, additionalData []byte) ([]byte, error)) (derivedKey []byte, undo func(), err error) {
+	salt := utils.GetRandomBytes(32)
+	derivedKey, err = aes.DeriveKey(auth, salt, ks.scrypt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var seed []byte
+	if ks.seed != nil {
+		plain, err := decrypt(ks.seed, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		seed, err = encryptSeed(derivedKey, plain)
+		utils.ZeroMemory(plain)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	keys := make([][]byte, len(ks.entries))
+	for i, entry := range ks.entries {
+		plain, err := decrypt(entry.key, entry.Address[:])
+		if err != nil {
+			return nil, nil, err
+		}
+		keys[i], err = encryptKey(derivedKey, entry.Address, plain)
+		utils.ZeroMemory(plain)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	oldSalt, oldMac, oldSeed := ks.salt, ks.mac, ks.seed
+	for i, entry := range ks.entries {
+		keys[i], entry.key = entry.key, keys[i]
+	}
+	ks.salt, ks.mac, ks.seed = salt, crypto.Keccak256(derivedKey[16:32]), seed
+
+	undo = func() {
+		ks.salt, ks.mac, ks.seed = oldSalt, oldMac, oldSeed
+		for i, entry := range ks.entries {
+			entry.key = keys[i]
+		}
+	}
+	return derivedKey, undo, nil
+}
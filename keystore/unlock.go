@@ -0,0 +1,75 @@
+package keystore
+
+import (
+	"time"
+
+	"github.com/maiiz/coinlib/utils"
+)
+
+// Unlock verifies auth and caches the derived key in memory, so GetUnlockedPrivkey
+// returns keys without deriving the key again. The wallet is locked after
+// timeout, or until Lock is called if timeout is 0.
+func (ks *KeyStore) Unlock(auth string, timeout time.Duration) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if auth == "" {
+		return ErrWrongPasspharse
+	}
+	if err := ks.loadIfEmpty(); err != nil {
+		return err
+	}
+	derivedKey, err := ks.derivedKey(auth)
+	if err != nil {
+		return err
+	}
+
+	ks.lock()
+	ks.unlocked = derivedKey
+	if timeout > 0 {
+		var timer *time.Timer
+		timer = time.AfterFunc(timeout, func() {
+			ks.mu.Lock()
+			defer ks.mu.Unlock()
+			// the wallet may be locked and unlocked again before the timer fires.
+			if ks.lockTimer == timer {
+				ks.lock()
+			}
+		})
+		ks.lockTimer = timer
+	}
+	return nil
+}
+
+// Lock zeroes the cached derived key of an unlocked wallet.
+func (ks *KeyStore) Lock() {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.lock()
+}
+
+// IsUnlocked reports whether the derived key is cached.
+func (ks *KeyStore) IsUnlocked() bool {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	return ks.unlocked != nil
+}
+
+// unlockedKey returns the cached derived key, or ErrLocked if the wallet is locked.
+func (ks *KeyStore) unlockedKey() ([]byte, error) {
+	if ks.unlocked == nil {
+		return nil, ErrLocked
+	}
+	return ks.unlocked, nil
+}
+
+func (ks *KeyStore) lock() {
+	if ks.lockTimer != nil {
+		ks.lockTimer.Stop()
+		ks.lockTimer = nil
+	}
+	if ks.unlocked != nil {
+		utils.ZeroMemory(ks.unlocked)
+		ks.unlocked = nil
+	}
+}
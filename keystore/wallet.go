@@ -0,0 +1,348 @@
+package keystore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/maiiz/coinlib/crypto"
+	"github.com/maiiz/coinlib/crypto/aes"
+	"github.com/maiiz/coinlib/encoding/varint"
+	"github.com/maiiz/coinlib/utils"
+)
+
+// Wallet file layout, integers are little endian.
+//
+//	magic(32) version(4) flags(4) scryptN(4) scryptR(4) scryptP(4) salt(32) mac(32)
+//	varint(len) encrypt seed
+//	count(4)
+//	count * entry
+//
+// entry:
+//
+//	address(20) varint(len) encrypt key
+//	varint(len) chain created(8) flags(1) index(4) varint(len) label
+//
+// The seed and keys are encrypted with AES-GCM under the first 16 bytes of the
+// scrypt derived key, each with its own nonce, and the key is authenticated
+// with its address. The mac is keccak256 of the last 16 bytes.
+//
+// Version 0 files have no version, flags, scrypt params, seed and metadata,
+// they start with walletMagic and have iv(16) after the salt. Their keys are
+// encrypted with AES-CTR under the shared iv and are re-encrypted in the
+// current version on the first use of the passphrase.
+const (
+	walletVersion uint32 = 2
+
+	flagHD uint32 = 1 << 0
+
+	entryChange   byte = 1 << 0
+	entryImported byte = 1 << 1
+
+	// The scrypt params come from the wallet file, larger ones would take
+	// gigabytes of memory or minutes to derive the key.
+	maxScryptN = 1 << 20
+	maxScryptR = 8
+	maxScryptP = 16
+)
+
+var (
+	walletMagic = utils.HexToBytes("0901419396d7679bf46d7c0c28a7a8eb2d793bea3c9bea222e7eedc77dc7e174")
+	fileMagic   = utils.HexToBytes("0901419396d7679bf46d7c0c28a7a8eb2d793bea3c9bea222e7eedc77dc7e17f")
+
+	ErrInvalidWallet      = errors.New("invalid wallet file")
+	ErrUnsupportedVersion = errors.New("unsupported wallet version")
+)
+
+// legacyInfo keeps the encrypt info of a version 0 wallet file.
+type legacyInfo struct {
+	iv []byte
+	// data is the old wallet file, it is kept as path.v0 on upgrade.
+	data []byte
+}
+
+// load reads the wallet file, a wallet of an older version is kept as legacy.
+func (ks *KeyStore) load() error {
+	path := ks.path
+	if !utils.FileExist(path) {
+		return ErrNoWalletFile
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if len(data) < len(fileMagic) {
+		return ErrInvalidWallet
+	}
+
+	var (
+		magic = data[:len(fileMagic)]
+		r     = bytes.NewReader(data[len(fileMagic):])
+	)
+	switch {
+	case bytes.Equal(magic, fileMagic):
+		if err := ks.decode(r); err != nil {
+			return err
+		}
+	case bytes.Equal(magic, walletMagic):
+		fileInfo, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if err := ks.decodeV0(r, fileInfo.ModTime()); err != nil {
+			return err
+		}
+	default:
+		return ErrInvalidMagic
+	}
+	if ks.legacy != nil {
+		ks.legacy.data = data
+	}
+	return nil
+}
+
+// upgrade decrypts the keys of a legacy wallet with auth, re-encrypts them in
+// the current version and writes the wallet, the old file is kept as
+// path.v0. It returns the new derived key.
+func (ks *KeyStore) upgrade(auth string) ([]byte, error) {
+	oldKey, err := aes.DeriveKey(auth, ks.salt, aes.LegacyScryptParams)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(crypto.Keccak256(oldKey[16:32]), ks.mac) {
+		return nil, ErrWrongPasspharse
+	}
+
+	if err := utils.WriteFileAtomic(ks.legacy.data, ks.path+".v0"); err != nil {
+		return nil, err
+	}
+
+	legacy := ks.legacy
+	derivedKey, undo, err := ks.reencrypt(auth, func(data, additionalData []byte) ([]byte, error) {
+		return aes.Decrypt(oldKey, data, legacy.iv, ks.mac)
+	})
+	if err != nil {
+		return nil, err
+	}
+	ks.legacy = nil
+	if err := ks.save(); err != nil {
+		undo()
+		ks.legacy = legacy
+		return nil, err
+	}
+	return derivedKey, nil
+}
+
+// save writes the wallet file atomically.
+func (ks *KeyStore) save() error {
+	if ks.legacy != nil {
+		return ErrLegacyWallet
+	}
+	return utils.WriteFileAtomic(ks.encode(), ks.path)
+}
+
+// encode returns the wallet file of the current version.
+func (ks *KeyStore) encode() []byte {
+	var (
+		buf   = new(bytes.Buffer)
+		flags uint32
+	)
+	if ks.seed != nil {
+		flags |= flagHD
+	}
+
+	buf.Write(fileMagic)
+	binary.Write(buf, binary.LittleEndian, walletVersion)
+	binary.Write(buf, binary.LittleEndian, flags)
+	for _, v := range []int{ks.scrypt.N, ks.scrypt.R, ks.scrypt.P} {
+		binary.Write(buf, binary.LittleEndian, uint32(v))
+	}
+	buf.Write(ks.salt)
+	buf.Write(ks.mac)
+	writeBytes(buf, ks.seed)
+
+	binary.Write(buf, binary.LittleEndian, uint32(len(ks.entries)))
+	for _, entry := range ks.entries {
+		buf.Write(entry.Address[:])
+		writeBytes(buf, entry.key)
+		writeBytes(buf, []byte(entry.Chain))
+		binary.Write(buf, binary.LittleEndian, entry.Created.Unix())
+		var entryFlags byte
+		if entry.Change {
+			entryFlags |= entryChange
+		}
+		if entry.Imported {
+			entryFlags |= entryImported
+		}
+		buf.WriteByte(entryFlags)
+		binary.Write(buf, binary.LittleEndian, entry.Index)
+		writeBytes(buf, []byte(entry.Label))
+	}
+	return buf.Bytes()
+}
+
+// decode reads a versioned wallet file following the magic.
+func (ks *KeyStore) decode(r io.Reader) error {
+	var version, flags, num uint32
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return ErrInvalidWallet
+	}
+	if version != walletVersion {
+		return ErrUnsupportedVersion
+	}
+	if err := binary.Read(r, binary.LittleEndian, &flags); err != nil {
+		return ErrInvalidWallet
+	}
+	var scrypt [3]uint32
+	if err := binary.Read(r, binary.LittleEndian, &scrypt); err != nil {
+		return ErrInvalidWallet
+	}
+	if scrypt[0] > maxScryptN || scrypt[1] > maxScryptR || scrypt[2] > maxScryptP {
+		return ErrInvalidWallet
+	}
+	ks.scrypt = aes.ScryptParams{N: int(scrypt[0]), R: int(scrypt[1]), P: int(scrypt[2])}
+	if err := readFull(r, ks.salt, ks.mac); err != nil {
+		return err
+	}
+
+	seed, err := readBytes(r)
+	if err != nil {
+		return err
+	}
+	if flags&flagHD != 0 {
+		ks.seed = seed
+	}
+
+	if err := binary.Read(r, binary.LittleEndian, &num); err != nil {
+		return ErrInvalidWallet
+	}
+	for i := uint32(0); i < num; i++ {
+		var (
+			entry   = new(keyEntry)
+			created int64
+			flags   = make([]byte, 1)
+		)
+		if _, err := io.ReadFull(r, entry.Address[:]); err != nil {
+			return ErrInvalidWallet
+		}
+		if entry.key, err = readBytes(r); err != nil {
+			return err
+		}
+		chain, err := readBytes(r)
+		if err != nil {
+			return err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &created); err != nil {
+			return ErrInvalidWallet
+		}
+		if _, err := io.ReadFull(r, flags); err != nil {
+			return ErrInvalidWallet
+		}
+		if err := binary.Read(r, binary.LittleEndian, &entry.Index); err != nil {
+			return ErrInvalidWallet
+		}
+		label, err := readBytes(r)
+		if err != nil {
+			return err
+		}
+
+		entry.Chain = string(chain)
+		entry.Created = time.Unix(created, 0)
+		entry.Change = flags[0]&entryChange != 0
+		entry.Imported = flags[0]&entryImported != 0
+		entry.Label = string(label)
+		ks.addEntry(entry)
+	}
+	return nil
+}
+
+// decodeV0 reads an unversioned wallet file following the magic, the first
+// changeAddressNum keys are change keys.
+func (ks *KeyStore) decodeV0(r io.Reader, created time.Time) error {
+	ks.legacy = &legacyInfo{iv: make([]byte, 16)}
+	if err := readFull(r, ks.salt, ks.legacy.iv, ks.mac); err != nil {
+		return err
+	}
+
+	var num uint32
+	if err := binary.Read(r, binary.LittleEndian, &num); err != nil {
+		return ErrInvalidWallet
+	}
+	for i := uint32(0); i < num; i++ {
+		entry := &keyEntry{
+			KeyInfo: KeyInfo{
+				Chain:   ks.params().Currency,
+				Created: created,
+				Change:  i < changeAddressNum,
+				Index:   i,
+			},
+			key: make([]byte, encryptKeySize),
+		}
+		if !entry.Change {
+			entry.Index -= changeAddressNum
+		}
+		if _, err := io.ReadFull(r, entry.Address[:]); err != nil {
+			return ErrInvalidWallet
+		}
+		if _, err := io.ReadFull(r, entry.key); err != nil {
+			return ErrInvalidWallet
+		}
+		ks.addEntry(entry)
+	}
+	return nil
+}
+
+func encryptKey(derivedKey []byte, addr utils.Address, priv []byte) ([]byte, error) {
+	return aes.GCMEncrypt(derivedKey[:16], priv, addr[:])
+}
+
+func decryptKey(derivedKey []byte, entry *keyEntry) ([]byte, error) {
+	return aes.GCMDecrypt(derivedKey[:16], entry.key, entry.Address[:])
+}
+
+func encryptSeed(derivedKey, seed []byte) ([]byte, error) {
+	return aes.GCMEncrypt(derivedKey[:16], seed, nil)
+}
+
+func decryptSeed(derivedKey, seed []byte) ([]byte, error) {
+	return aes.GCMDecrypt(derivedKey[:16], seed, nil)
+}
+
+func readFull(r io.Reader, bufs ...[]byte) error {
+	for _, b := range bufs {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return ErrInvalidWallet
+		}
+	}
+	return nil
+}
+
+func writeBytes(w io.Writer, b []byte) {
+	varint.WriteVarInt(w, uint64(len(b)))
+	w.Write(b)
+}
+
+func readBytes(r io.Reader) ([]byte, error) {
+	n, err := varint.ReadVarInt(r)
+	if err != nil || n > 1<<16 {
+		return nil, ErrInvalidWallet
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, ErrInvalidWallet
+	}
+	return b, nil
+}
+
+// isEmptyFile reports whether the file does not exist or is empty.
+func isEmptyFile(path string) bool {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	return fileInfo.Size() == 0
+}
@@ -0,0 +1,115 @@
+package keystore
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/maiiz/coinlib/params"
+)
+
+var (
+	ErrWalletOpened    = errors.New("wallet already opened")
+	ErrWalletNotOpened = errors.New("wallet not opened")
+	ErrInvalidName     = errors.New("invalid wallet name")
+)
+
+// Wallets manages named keystores in a directory, the wallet of name is
+// stored at dir/name/wallet.dat with its address files.
+type Wallets struct {
+	mu     sync.RWMutex
+	dir    string
+	stores map[string]*KeyStore
+}
+
+// NewWallets returns a wallets manager of dir.
+func NewWallets(dir string) *Wallets {
+	return &Wallets{
+		dir:    dir,
+		stores: make(map[string]*KeyStore),
+	}
+}
+
+// Open opens the wallet of name with the chain params, the wallet is loaded
+// if its file exists, otherwise it can be created by the GenerateXXX methods.
+func (w *Wallets) Open(name string, chain *params.ChainParams) (*KeyStore, error) {
+	if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+		return nil, ErrInvalidName
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.stores[name]; ok {
+		return nil, ErrWalletOpened
+	}
+
+	walletDir := filepath.Join(w.dir, name)
+	if err := os.MkdirAll(walletDir, 0700); err != nil {
+		return nil, err
+	}
+
+	ks := NewKeyStore(filepath.Join(walletDir, walletFile), chain)
+	if err := ks.Load(); err != nil && err != ErrNoWalletFile {
+		return nil, err
+	}
+	w.stores[name] = ks
+	return ks, nil
+}
+
+// Get returns the opened wallet of name.
+func (w *Wallets) Get(name string) (*KeyStore, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	ks, ok := w.stores[name]
+	if !ok {
+		return nil, ErrWalletNotOpened
+	}
+	return ks, nil
+}
+
+// Close locks the wallet of name and removes it from the opened wallets.
+func (w *Wallets) Close(name string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ks, ok := w.stores[name]
+	if !ok {
+		return ErrWalletNotOpened
+	}
+	ks.Lock()
+	delete(w.stores, name)
+	return nil
+}
+
+// Opened returns the sorted names of the opened wallets.
+func (w *Wallets) Opened() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	names := make([]string, 0, len(w.stores))
+	for name := range w.stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// List returns the sorted names of the wallets in the directory.
+func (w *Wallets) List() ([]string, error) {
+	infos, err := ioutil.ReadDir(w.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var names []string
+	for _, info := range infos {
+		if info.IsDir() && !isEmptyFile(filepath.Join(w.dir, info.Name(), walletFile)) {
+			names = append(names, info.Name())
+		}
+	}
+	return names, nil
+}
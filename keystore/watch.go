@@ -0,0 +1,315 @@
+package keystore
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/maiiz/coinlib/crypto/bip32"
+	"github.com/maiiz/coinlib/params"
+	"github.com/maiiz/coinlib/utils"
+)
+
+var (
+	ErrNotPublicKey  = errors.New("watch wallet takes public extended keys only")
+	ErrWatchChain    = errors.New("watch wallet of another chain")
+	ErrInvalidWatch  = errors.New("invalid watch wallet file")
+	ErrAddressExists = errors.New("address already exists")
+)
+
+// WatchWallet holds addresses and account xpubs without private keys, it
+// answers whether an address is ours on an online machine while the signing
+// wallet stays offline. It is safe for concurrent use.
+type WatchWallet struct {
+	mu    sync.RWMutex
+	chain *params.ChainParams
+
+	addrs   map[utils.Address]*KeyInfo
+	entries []*KeyInfo
+	xpubs   []*watchAccount
+}
+
+// watchAccount is an account xpub with the number of derived addresses on the
+// receive and change chain.
+type watchAccount struct {
+	key             *bip32.ExtendedKey
+	receive, change uint32
+}
+
+// watchFile is the JSON file of a watch wallet.
+type watchFile struct {
+	Chain     string         `json:"chain"`
+	XPubs     []watchXPub    `json:"xpubs,omitempty"`
+	Addresses []watchAddress `json:"addresses"`
+}
+
+type watchXPub struct {
+	XPub    string `json:"xpub"`
+	Receive uint32 `json:"receive"`
+	Change  uint32 `json:"change"`
+}
+
+type watchAddress struct {
+	Address  string `json:"address"`
+	Label    string `json:"label,omitempty"`
+	Created  int64  `json:"created"`
+	Change   bool   `json:"change,omitempty"`
+	Index    uint32 `json:"index"`
+	Imported bool   `json:"imported,omitempty"`
+}
+
+// NewWatchWallet returns an empty watch wallet of chain.
+func NewWatchWallet(chain *params.ChainParams) *WatchWallet {
+	return &WatchWallet{
+		chain: chain,
+		addrs: make(map[utils.Address]*KeyInfo),
+	}
+}
+
+// AddAddress adds a single address to watch.
+func (w *WatchWallet) AddAddress(addr utils.Address, label string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.addrs[addr]; ok {
+		return ErrAddressExists
+	}
+	w.add(&KeyInfo{
+		Address:  addr,
+		Chain:    w.chain.Currency,
+		Created:  time.Now(),
+		Label:    label,
+		Imported: true,
+	})
+	return nil
+}
+
+// AddXPub adds the account xpub and watches its first receive and change
+// addresses, adding a known xpub derives more addresses if the numbers grow.
+func (w *WatchWallet) AddXPub(xpub string, receive, change uint32) error {
+	key, err := bip32.ParseExtendedKey(xpub, w.chain)
+	if err != nil {
+		return err
+	}
+	if key.IsPrivate() {
+		return ErrNotPublicKey
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	account := w.account(xpub)
+	if account == nil {
+		account = &watchAccount{key: key}
+		w.xpubs = append(w.xpubs, account)
+	}
+	if err := w.derive(account, false, receive); err != nil {
+		return err
+	}
+	return w.derive(account, true, change)
+}
+
+// account returns the watched account of xpub.
+func (w *WatchWallet) account(xpub string) *watchAccount {
+	for _, account := range w.xpubs {
+		if account.key.String() == xpub {
+			return account
+		}
+	}
+	return nil
+}
+
+// derive watches the addresses of the account up to num on the receive or change chain.
+func (w *WatchWallet) derive(account *watchAccount, change bool, num uint32) error {
+	var (
+		chain = uint32(bip32.ExternalChain)
+		next  = &account.receive
+	)
+	if change {
+		chain, next = bip32.InternalChain, &account.change
+	}
+
+	for i := *next; i < num; i++ {
+		key, err := account.key.Derive([]uint32{chain, i})
+		if err != nil {
+			return err
+		}
+		pub, err := key.PublicKey()
+		if err != nil {
+			return err
+		}
+		pubBytes := pub.Bytes()
+		if w.chain.IsCompressed {
+			pubBytes = pub.CompressedBytes()
+		}
+
+		addr := utils.BytesToAddress(w.chain.AddressHashFunc(pubBytes))
+		if _, ok := w.addrs[addr]; !ok {
+			w.add(&KeyInfo{
+				Address: addr,
+				Chain:   w.chain.Currency,
+				Created: time.Now(),
+				Change:  change,
+				Index:   i,
+			})
+		}
+		*next = i + 1
+	}
+	return nil
+}
+
+func (w *WatchWallet) add(info *KeyInfo) {
+	w.addrs[info.Address] = info
+	w.entries = append(w.entries, info)
+}
+
+// IsMine reports whether the address is watched.
+func (w *WatchWallet) IsMine(addr utils.Address) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	_, ok := w.addrs[addr]
+	return ok
+}
+
+// Lookup returns the metadata of the watched address.
+func (w *WatchWallet) Lookup(addr utils.Address) (KeyInfo, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	info, ok := w.addrs[addr]
+	if !ok {
+		return KeyInfo{}, false
+	}
+	return *info, true
+}
+
+// Addresses returns the metadata of all watched addresses in the order added.
+func (w *WatchWallet) Addresses() []KeyInfo {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	infos := make([]KeyInfo, len(w.entries))
+	for i, info := range w.entries {
+		infos[i] = *info
+	}
+	return infos
+}
+
+// XPubs returns the watched account xpubs.
+func (w *WatchWallet) XPubs() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	xpubs := make([]string, len(w.xpubs))
+	for i, account := range w.xpubs {
+		xpubs[i] = account.key.String()
+	}
+	return xpubs
+}
+
+// Save writes the watch wallet to path as JSON.
+func (w *WatchWallet) Save(path string) error {
+	w.mu.RLock()
+	file := watchFile{Chain: w.chain.Currency}
+	for _, account := range w.xpubs {
+		file.XPubs = append(file.XPubs, watchXPub{
+			XPub:    account.key.String(),
+			Receive: account.receive,
+			Change:  account.change,
+		})
+	}
+	for _, info := range w.entries {
+		file.Addresses = append(file.Addresses, watchAddress{
+			Address:  hex.EncodeToString(info.Address[:]),
+			Label:    info.Label,
+			Created:  info.Created.Unix(),
+			Change:   info.Change,
+			Index:    info.Index,
+			Imported: info.Imported,
+		})
+	}
+	w.mu.RUnlock()
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(data, path)
+}
+
+// LoadWatchWallet reads the watch wallet of chain from path.
+func LoadWatchWallet(path string, chain *params.ChainParams) (*WatchWallet, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file watchFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, ErrInvalidWatch
+	}
+	if file.Chain != chain.Currency {
+		return nil, ErrWatchChain
+	}
+
+	w := NewWatchWallet(chain)
+	for _, a := range file.Addresses {
+		b, err := hex.DecodeString(a.Address)
+		if err != nil || len(b) != utils.AddressSize {
+			return nil, ErrInvalidWatch
+		}
+		addr := utils.BytesToAddress(b)
+		if _, ok := w.addrs[addr]; ok {
+			continue
+		}
+		w.add(&KeyInfo{
+			Address:  addr,
+			Chain:    chain.Currency,
+			Created:  time.Unix(a.Created, 0),
+			Label:    a.Label,
+			Change:   a.Change,
+			Index:    a.Index,
+			Imported: a.Imported,
+		})
+	}
+	for _, x := range file.XPubs {
+		if err := w.AddXPub(x.XPub, x.Receive, x.Change); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// WatchWallet exports the addresses of the wallet with their metadata, and the
+// account xpub of a hd wallet which needs auth to derive.
+func (ks *KeyStore) WatchWallet(auth string) (*WatchWallet, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if err := ks.loadIfEmpty(); err != nil {
+		return nil, err
+	}
+
+	w := NewWatchWallet(ks.params())
+	for _, entry := range ks.entries {
+		info := entry.KeyInfo
+		w.add(&info)
+	}
+	if ks.seed == nil {
+		return w, nil
+	}
+
+	derivedKey, err := ks.derivedKey(auth)
+	if err != nil {
+		return nil, err
+	}
+	account, err := ks.accountKey(derivedKey)
+	if err != nil {
+		return nil, err
+	}
+	w.xpubs = append(w.xpubs, &watchAccount{
+		key:     account.Neuter(),
+		receive: ks.nextIndex(false),
+		change:  ks.nextIndex(true),
+	})
+	return w, nil
+}
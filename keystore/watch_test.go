@@ -0,0 +1,80 @@
+package keystore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maiiz/coinlib/params"
+	"github.com/maiiz/coinlib/utils"
+)
+
+func TestWatchWallet(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	chain := params.GetChain(params.BTC)
+	ks := NewKeyStore(filepath.Join(dir, walletFile), chain)
+	if err := ks.GenerateHDKeys(nil, 3, testAuth); err != nil {
+		t.Fatalf("GenerateHDKeys error %v", err)
+	}
+	addr := ks.Keys()[changeAddressNum].Address
+	if err := ks.SetLabel(addr, "first"); err != nil {
+		t.Fatalf("SetLabel error %v", err)
+	}
+
+	w, err := ks.WatchWallet(testAuth)
+	if err != nil {
+		t.Fatalf("WatchWallet error %v", err)
+	}
+	path := filepath.Join(dir, "watch.json")
+	if err := w.Save(path); err != nil {
+		t.Fatalf("Save error %v", err)
+	}
+	if w, err = LoadWatchWallet(path, chain); err != nil {
+		t.Fatalf("LoadWatchWallet error %v", err)
+	}
+	if _, err := LoadWatchWallet(path, params.GetChain(params.LTC)); err != ErrWatchChain {
+		t.Errorf("LoadWatchWallet of ltc error %v, except %v", err, ErrWatchChain)
+	}
+
+	for _, key := range ks.Keys() {
+		if !w.IsMine(key.Address) {
+			t.Errorf("watch wallet misses %x", key.Address)
+		}
+	}
+	if info, ok := w.Lookup(addr); !ok || info.Label != "first" || info.Change || info.Index != 0 {
+		t.Errorf("Lookup(%x) = %+v, %v", addr, info, ok)
+	}
+	if w.IsMine(utils.Address{}) {
+		t.Errorf("watch wallet owns the zero address")
+	}
+
+	// the xpub derives the addresses appended later to the signing wallet.
+	if err := ks.AppendKeys(2, testAuth); err != nil {
+		t.Fatalf("AppendKeys error %v", err)
+	}
+	xpubs := w.XPubs()
+	if len(xpubs) != 1 {
+		t.Fatalf("watch wallet has %d xpubs", len(xpubs))
+	}
+	if err := w.AddXPub(xpubs[0], 5, changeAddressNum); err != nil {
+		t.Fatalf("AddXPub error %v", err)
+	}
+	for _, key := range ks.Keys() {
+		if !w.IsMine(key.Address) {
+			t.Errorf("watch wallet misses appended %x", key.Address)
+		}
+	}
+	if n := len(w.Addresses()); n != int(changeAddressNum)+5 {
+		t.Errorf("watch wallet has %d addresses, except %d", n, int(changeAddressNum)+5)
+	}
+
+	account, err := ks.AccountKey(testAuth)
+	if err != nil {
+		t.Fatalf("AccountKey error %v", err)
+	}
+	if err := w.AddXPub(account.String(), 1, 1); err != ErrNotPublicKey {
+		t.Errorf("AddXPub of xprv error %v, except %v", err, ErrNotPublicKey)
+	}
+}
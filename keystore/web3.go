@@ -0,0 +1,235 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/maiiz/coinlib/crypto"
+	"github.com/maiiz/coinlib/crypto/aes"
+	"github.com/maiiz/coinlib/crypto/secp256k1"
+	"github.com/maiiz/coinlib/params"
+	"github.com/maiiz/coinlib/utils"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Web3 Secret Storage Definition, the key file format of geth and MetaMask.
+const (
+	web3Version = 3
+	web3Cipher  = "aes-128-ctr"
+	web3Scrypt  = "scrypt"
+	web3PBKDF2  = "pbkdf2"
+	web3PRF     = "hmac-sha256"
+	web3DKLen   = 32
+
+	// The kdf params come from the imported file, larger ones would take
+	// gigabytes of memory or minutes to derive the key.
+	web3MaxScryptN = 1 << 20
+	web3MaxScryptR = 8
+	web3MaxScryptP = 16
+	web3MaxPBKDF2C = 1 << 22
+)
+
+var (
+	ErrWeb3Version    = errors.New("unsupported web3 key version")
+	ErrWeb3Cipher     = errors.New("unsupported web3 key cipher")
+	ErrWeb3KDF        = errors.New("unsupported web3 key kdf")
+	ErrWeb3Address    = errors.New("web3 key address not match")
+	ErrNotEthereumKey = errors.New("not an ethereum key")
+	ErrInvalidWeb3Key = errors.New("invalid web3 key")
+	ErrWeb3Passphrase = errors.New("web3 key mac not match")
+)
+
+// ethereumCurrencies are the chains whose keys can be stored as web3 keys.
+var ethereumCurrencies = map[string]bool{params.ETH: true, params.ETC: true}
+
+type web3Key struct {
+	Address string     `json:"address"`
+	Crypto  web3Crypto `json:"crypto"`
+	ID      string     `json:"id"`
+	Version int        `json:"version"`
+}
+
+type web3Crypto struct {
+	Cipher       string           `json:"cipher"`
+	CipherText   string           `json:"ciphertext"`
+	CipherParams web3CipherParams `json:"cipherparams"`
+	KDF          string           `json:"kdf"`
+	KDFParams    web3KDFParams    `json:"kdfparams"`
+	MAC          string           `json:"mac"`
+}
+
+type web3CipherParams struct {
+	IV string `json:"iv"`
+}
+
+// web3KDFParams holds the params of both kdf, N, R and P are of scrypt,
+// C and PRF are of pbkdf2.
+type web3KDFParams struct {
+	DKLen int    `json:"dklen"`
+	Salt  string `json:"salt"`
+	N     int    `json:"n,omitempty"`
+	R     int    `json:"r,omitempty"`
+	P     int    `json:"p,omitempty"`
+	C     int    `json:"c,omitempty"`
+	PRF   string `json:"prf,omitempty"`
+}
+
+// ImportWeb3Key decrypts the web3 v3 JSON key with passphrase and adds it to
+// the ethereum wallet encrypted with auth.
+func (ks *KeyStore) ImportWeb3Key(keyJSON []byte, passphrase, auth string) (KeyInfo, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if !ethereumCurrencies[ks.params().Currency] {
+		return KeyInfo{}, ErrNotEthereumKey
+	}
+	if err := ks.loadIfEmpty(); err != nil {
+		return KeyInfo{}, err
+	}
+
+	priv, err := decryptWeb3Key(keyJSON, passphrase)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	defer utils.ZeroMemory(priv)
+
+	return ks.importKey(priv, auth)
+}
+
+// ExportWeb3Key returns the key of the ethereum address as a web3 v3 JSON key
+// encrypted with passphrase using scrypt params p.
+func (ks *KeyStore) ExportWeb3Key(addr utils.Address, auth, passphrase string, p aes.ScryptParams) ([]byte, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	entry, ok := ks.keys[addr]
+	if !ok {
+		return nil, ErrKeyNotFind
+	}
+	if !ethereumCurrencies[entry.Chain] {
+		return nil, ErrNotEthereumKey
+	}
+
+	_, priv, err := ks.privateKey(addr, auth)
+	if err != nil {
+		return nil, err
+	}
+	defer utils.ZeroMemory(priv)
+
+	return encryptWeb3Key(addr, priv, passphrase, p)
+}
+
+func encryptWeb3Key(addr utils.Address, priv []byte, passphrase string, p aes.ScryptParams) ([]byte, error) {
+	salt, iv := aes.GenEncryptInfo()
+	derivedKey, err := aes.DeriveKey(passphrase, salt, p)
+	if err != nil {
+		return nil, err
+	}
+	cipherText, err := aes.Encrypt(derivedKey, priv, iv)
+	if err != nil {
+		return nil, err
+	}
+
+	key := web3Key{
+		Address: hex.EncodeToString(addr[:]),
+		Crypto: web3Crypto{
+			Cipher:       web3Cipher,
+			CipherText:   hex.EncodeToString(cipherText),
+			CipherParams: web3CipherParams{IV: hex.EncodeToString(iv)},
+			KDF:          web3Scrypt,
+			KDFParams: web3KDFParams{
+				DKLen: web3DKLen,
+				Salt:  hex.EncodeToString(salt),
+				N:     p.N,
+				R:     p.R,
+				P:     p.P,
+			},
+			MAC: hex.EncodeToString(crypto.Keccak256(derivedKey[16:32], cipherText)),
+		},
+		ID:      newUUID(),
+		Version: web3Version,
+	}
+	return json.Marshal(key)
+}
+
+// decryptWeb3Key returns the private key of the web3 v3 JSON key.
+func decryptWeb3Key(keyJSON []byte, passphrase string) ([]byte, error) {
+	var key web3Key
+	if err := json.Unmarshal(keyJSON, &key); err != nil {
+		return nil, ErrInvalidWeb3Key
+	}
+	if key.Version != web3Version {
+		return nil, ErrWeb3Version
+	}
+	if key.Crypto.Cipher != web3Cipher {
+		return nil, ErrWeb3Cipher
+	}
+
+	mac, err1 := hex.DecodeString(key.Crypto.MAC)
+	iv, err2 := hex.DecodeString(key.Crypto.CipherParams.IV)
+	cipherText, err3 := hex.DecodeString(key.Crypto.CipherText)
+	if err1 != nil || err2 != nil || err3 != nil || len(iv) != 16 {
+		return nil, ErrInvalidWeb3Key
+	}
+
+	derivedKey, err := web3DerivedKey(passphrase, &key.Crypto.KDFParams, key.Crypto.KDF)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(crypto.Keccak256(derivedKey[16:32], cipherText), mac) {
+		return nil, ErrWeb3Passphrase
+	}
+	priv, err := aes.DecryptCTR(derivedKey, cipherText, iv)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(priv) != 32 {
+		return nil, ErrInvalidWeb3Key
+	}
+	if key.Address != "" {
+		addr := crypto.Keccak256(secp256k1.ToECDSA(priv).Public().Bytes()[1:])[12:]
+		if hex.EncodeToString(addr) != strings.ToLower(strings.TrimPrefix(key.Address, "0x")) {
+			utils.ZeroMemory(priv)
+			return nil, ErrWeb3Address
+		}
+	}
+	return priv, nil
+}
+
+func web3DerivedKey(passphrase string, p *web3KDFParams, kdf string) ([]byte, error) {
+	salt, err := hex.DecodeString(p.Salt)
+	if err != nil {
+		return nil, ErrInvalidWeb3Key
+	}
+	if p.DKLen != web3DKLen {
+		return nil, ErrWeb3KDF
+	}
+
+	switch kdf {
+	case web3Scrypt:
+		if p.N > web3MaxScryptN || p.R > web3MaxScryptR || p.P > web3MaxScryptP {
+			return nil, ErrWeb3KDF
+		}
+		return aes.DeriveKey(passphrase, salt, aes.ScryptParams{N: p.N, R: p.R, P: p.P})
+	case web3PBKDF2:
+		if p.PRF != web3PRF || p.C <= 0 || p.C > web3MaxPBKDF2C {
+			return nil, ErrWeb3KDF
+		}
+		return pbkdf2.Key([]byte(passphrase), salt, p.C, p.DKLen, sha256.New), nil
+	}
+	return nil, ErrWeb3KDF
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() string {
+	u := utils.GetRandomBytes(16)
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
@@ -0,0 +1,106 @@
+package keystore
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/maiiz/coinlib/crypto/aes"
+	"github.com/maiiz/coinlib/params"
+)
+
+// Test vectors of the Web3 Secret Storage Definition, the passphrase is "testpassword".
+var web3Tests = []struct {
+	kdf     string
+	keyJSON string
+}{
+	{
+		"pbkdf2",
+		`{"crypto":{"cipher":"aes-128-ctr","cipherparams":{"iv":"6087dab2f9fdbbfaddc31a909735c1e6"},"ciphertext":"5318b4d5bcd28de64ee5559e671353e16f075ecae9f99c7a79a38af5f869aa46","kdf":"pbkdf2","kdfparams":{"c":262144,"dklen":32,"prf":"hmac-sha256","salt":"ae3cd4e7013836a3df6bd7241b12db061dbe2c6785853cce422d148a624ce0bd"},"mac":"517ead924a9d0dc3124507e3393d175ce3ff7c1e96529c6c555ce9e51205e9b2"},"id":"3198bc9c-6672-5ab3-d995-4942343ae5b6","version":3}`,
+	},
+	{
+		"scrypt",
+		`{"crypto":{"cipher":"aes-128-ctr","cipherparams":{"iv":"83dbcc02d8ccb40e466191a123791e0e"},"ciphertext":"d172bf743a674da9cdad04534d56926ef8358534d458fffccd4e6ad2fbde479c","kdf":"scrypt","kdfparams":{"dklen":32,"n":262144,"p":8,"r":1,"salt":"ab0c7876052600dd703518d6fc3fe8984592145b591fc8fb5c6d43190334ba19"},"mac":"2103ac29920d71da29f15d75b4a16dbe95cfd7ff8faea1056c33131d846e3097"},"id":"3198bc9c-6672-5ab3-d995-4942343ae5b6","version":3}`,
+	},
+}
+
+const web3TestKey = "7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d"
+
+func TestDecryptWeb3Key(t *testing.T) {
+	for _, test := range web3Tests {
+		priv, err := decryptWeb3Key([]byte(test.keyJSON), "testpassword")
+		if err != nil || hex.EncodeToString(priv) != web3TestKey {
+			t.Errorf("decryptWeb3Key(%s) = %x, except %s, err %v", test.kdf, priv, web3TestKey, err)
+		}
+		if _, err := decryptWeb3Key([]byte(test.keyJSON), "wrong"); err != ErrWeb3Passphrase {
+			t.Errorf("decryptWeb3Key(%s) with wrong passphrase error %v", test.kdf, err)
+		}
+	}
+}
+
+func TestWeb3KDFLimits(t *testing.T) {
+	tests := []struct {
+		keyJSON, old, new string
+	}{
+		{web3Tests[0].keyJSON, `"c":262144`, `"c":8388608`},
+		{web3Tests[1].keyJSON, `"n":262144`, `"n":2097152`},
+		{web3Tests[1].keyJSON, `"r":1`, `"r":16`},
+		{web3Tests[1].keyJSON, `"p":8`, `"p":32`},
+	}
+	for _, test := range tests {
+		keyJSON := strings.Replace(test.keyJSON, test.old, test.new, 1)
+		if _, err := decryptWeb3Key([]byte(keyJSON), "testpassword"); err != ErrWeb3KDF {
+			t.Errorf("decryptWeb3Key with %s error %v, except %v", test.new, err, ErrWeb3KDF)
+		}
+	}
+}
+
+func TestImportExportWeb3Key(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	chain := params.GetChain(params.ETH)
+	ks := NewKeyStore(filepath.Join(dir, walletFile), chain)
+	if err := ks.GenerateKeys(1, testAuth); err != nil {
+		t.Fatalf("GenerateKeys error %v", err)
+	}
+	info, err := ks.ImportWeb3Key([]byte(web3Tests[0].keyJSON), "testpassword", testAuth)
+	if err != nil {
+		t.Fatalf("ImportWeb3Key error %v", err)
+	}
+	if !info.Imported || info.Chain != params.ETH {
+		t.Errorf("unexpected imported key %+v", info)
+	}
+	if _, err := ks.ImportWeb3Key([]byte(web3Tests[1].keyJSON), "testpassword", testAuth); err != ErrKeyExists {
+		t.Errorf("ImportWeb3Key twice error %v, except %v", err, ErrKeyExists)
+	}
+
+	// imported keys do not take an index.
+	if err := ks.AppendKeys(1, testAuth); err != nil {
+		t.Fatalf("AppendKeys error %v", err)
+	}
+	keys := ks.Keys()
+	if last := keys[len(keys)-1]; last.Index != 1 || last.Imported {
+		t.Errorf("unexpected appended key %+v", last)
+	}
+
+	ks = NewKeyStore(filepath.Join(dir, walletFile), chain)
+	if err := ks.Load(); err != nil {
+		t.Fatalf("Load error %v", err)
+	}
+	keyJSON, err := ks.ExportWeb3Key(info.Address, testAuth, "exported", aes.LightScryptParams)
+	if err != nil {
+		t.Fatalf("ExportWeb3Key error %v", err)
+	}
+	priv, err := decryptWeb3Key(keyJSON, "exported")
+	if err != nil || hex.EncodeToString(priv) != web3TestKey {
+		t.Errorf("decrypt exported key = %x, except %s, err %v", priv, web3TestKey, err)
+	}
+
+	btc := NewKeyStore(filepath.Join(dir, "btc.dat"), params.GetChain(params.BTC))
+	if _, err := btc.ImportWeb3Key(keyJSON, "exported", testAuth); err != ErrNotEthereumKey {
+		t.Errorf("ImportWeb3Key to btc wallet error %v, except %v", err, ErrNotEthereumKey)
+	}
+}
@@ -0,0 +1,115 @@
+package keystore
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+
+	"github.com/maiiz/coinlib/crypto"
+	"github.com/maiiz/coinlib/crypto/secp256k1"
+	"github.com/maiiz/coinlib/encoding/base58"
+	"github.com/maiiz/coinlib/params"
+	"github.com/maiiz/coinlib/utils"
+)
+
+const (
+	wifKeySize        = 32
+	wifCompressedFlag = 0x01
+)
+
+var (
+	ErrInvalidWIF     = errors.New("invalid wif")
+	ErrWIFChecksum    = errors.New("wif checksum not match")
+	ErrWIFNetwork     = errors.New("wif of another network")
+	ErrWIFCompression = errors.New("wif compression not match chain")
+	ErrWIFUnsupported = errors.New("chain does not support wif")
+)
+
+// EncodeWIF encodes the private key in Wallet Import Format of the chain:
+// base58(prefix || key || [0x01] || checksum), the 0x01 suffix marks the
+// key of a compressed public key.
+func EncodeWIF(priv []byte, chain *params.ChainParams, compressed bool) string {
+	b := make([]byte, 1+wifKeySize, 1+wifKeySize+1+4)
+	b[0] = chain.PrivateKeyPrefix
+	copy(b[1+wifKeySize-len(priv):], priv)
+	if compressed {
+		b = append(b, wifCompressedFlag)
+	}
+	checkSum := crypto.DoubleSha256(b)
+	return base58.StdEncoding.Encode(append(b, checkSum[:4]...))
+}
+
+// DecodeWIF decodes a WIF of the chain, and returns the private key and
+// whether it is of a compressed public key.
+func DecodeWIF(wif string, chain *params.ChainParams) (priv []byte, compressed bool, err error) {
+	b := base58.StdEncoding.Decode(wif)
+	switch len(b) {
+	case 1 + wifKeySize + 4:
+	case 1 + wifKeySize + 1 + 4:
+		if b[1+wifKeySize] != wifCompressedFlag {
+			return nil, false, ErrInvalidWIF
+		}
+		compressed = true
+	default:
+		return nil, false, ErrInvalidWIF
+	}
+
+	payload, sum := b[:len(b)-4], b[len(b)-4:]
+	checkSum := crypto.DoubleSha256(payload)
+	if !bytes.Equal(checkSum[:4], sum) {
+		return nil, false, ErrWIFChecksum
+	}
+	if payload[0] != chain.PrivateKeyPrefix {
+		return nil, false, ErrWIFNetwork
+	}
+
+	priv = append([]byte{}, payload[1:1+wifKeySize]...)
+	d := new(big.Int).SetBytes(priv)
+	if d.Sign() == 0 || d.Cmp(secp256k1.N) >= 0 {
+		return nil, false, ErrInvalidWIF
+	}
+	return priv, compressed, nil
+}
+
+// ImportWIF adds the WIF private key to the wallet encrypted with auth, the
+// compression flag of the WIF must match the chain.
+func (ks *KeyStore) ImportWIF(wif, auth string) (KeyInfo, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ethereumCurrencies[ks.params().Currency] {
+		return KeyInfo{}, ErrWIFUnsupported
+	}
+	if err := ks.loadIfEmpty(); err != nil {
+		return KeyInfo{}, err
+	}
+
+	priv, compressed, err := DecodeWIF(wif, ks.params())
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	defer utils.ZeroMemory(priv)
+	if compressed != ks.params().IsCompressed {
+		return KeyInfo{}, ErrWIFCompression
+	}
+
+	return ks.importKey(priv, auth)
+}
+
+// ExportWIF returns the key of address in Wallet Import Format.
+func (ks *KeyStore) ExportWIF(addr utils.Address, auth string) (string, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ethereumCurrencies[ks.params().Currency] {
+		return "", ErrWIFUnsupported
+	}
+
+	_, priv, err := ks.privateKey(addr, auth)
+	if err != nil {
+		return "", err
+	}
+	defer utils.ZeroMemory(priv)
+
+	return EncodeWIF(priv, ks.params(), ks.params().IsCompressed), nil
+}
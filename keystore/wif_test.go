@@ -0,0 +1,67 @@
+package keystore
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maiiz/coinlib/params"
+	"github.com/maiiz/coinlib/utils"
+)
+
+var wifTests = []struct {
+	key        string
+	compressed bool
+	wif        string
+}{
+	{"0c28fca386c7a227600b2fe50b7cae11ec86d3bf1fbe471be89827e19d72aa1d", false, "5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTJ"},
+	{"0c28fca386c7a227600b2fe50b7cae11ec86d3bf1fbe471be89827e19d72aa1d", true, "KwdMAjGmerYanjeui5SHS7JkmpZvVipYvB2LJGU1ZxJwYvP98617"},
+}
+
+func TestWIF(t *testing.T) {
+	chain := params.GetChain(params.BTC)
+	for _, test := range wifTests {
+		key := utils.HexToBytes(test.key)
+		if wif := EncodeWIF(key, chain, test.compressed); wif != test.wif {
+			t.Errorf("EncodeWIF(%s, %v) = %s, except %s", test.key, test.compressed, wif, test.wif)
+		}
+		priv, compressed, err := DecodeWIF(test.wif, chain)
+		if err != nil || !bytes.Equal(priv, key) || compressed != test.compressed {
+			t.Errorf("DecodeWIF(%s) = %x, %v, err %v", test.wif, priv, compressed, err)
+		}
+	}
+
+	wif := wifTests[1].wif
+	if _, _, err := DecodeWIF(wif[:len(wif)-1]+"1", chain); err != ErrWIFChecksum {
+		t.Errorf("DecodeWIF with bad checksum error %v, except %v", err, ErrWIFChecksum)
+	}
+	if _, _, err := DecodeWIF(wif, params.GetChain(params.LTC)); err != ErrWIFNetwork {
+		t.Errorf("DecodeWIF of ltc error %v, except %v", err, ErrWIFNetwork)
+	}
+}
+
+func TestImportExportWIF(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	ks := NewKeyStore(filepath.Join(dir, walletFile), params.GetChain(params.BTC))
+	if err := ks.GenerateKeys(1, testAuth); err != nil {
+		t.Fatalf("GenerateKeys error %v", err)
+	}
+	if _, err := ks.ImportWIF(wifTests[0].wif, testAuth); err != ErrWIFCompression {
+		t.Errorf("ImportWIF uncompressed error %v, except %v", err, ErrWIFCompression)
+	}
+	info, err := ks.ImportWIF(wifTests[1].wif, testAuth)
+	if err != nil {
+		t.Fatalf("ImportWIF error %v", err)
+	}
+	if addr := ks.params().ToAddress(info.Address[:]); addr != "1LoVGDgRs9hTfTNJNuXKSpywcbdvwRXpmK" {
+		t.Errorf("imported address %s", addr)
+	}
+
+	wif, err := ks.ExportWIF(info.Address, testAuth)
+	if err != nil || wif != wifTests[1].wif {
+		t.Errorf("ExportWIF = %s, except %s, err %v", wif, wifTests[1].wif, err)
+	}
+}
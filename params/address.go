@@ -0,0 +1,40 @@
+package params
+
+import (
+	"errors"
+
+	"github.com/maiiz/coinlib/crypto"
+	"github.com/maiiz/coinlib/encoding/base58"
+	"github.com/maiiz/coinlib/encoding/bech32"
+)
+
+// ErrNoSegwit is returned for the segwit address of a chain without segwit.
+var ErrNoSegwit = errors.New("chain has no segwit")
+
+// ToScriptAddress returns the p2sh address of the 20 bytes script hash.
+func (p *ChainParams) ToScriptAddress(hash []byte) string {
+	a := append([]byte{p.ScriptAddressPrefix}, hash...)
+	checkSum := crypto.DoubleSha256(a)
+	a = append(a, checkSum[:4]...)
+	return base58.StdEncoding.Encode(a)
+}
+
+// ToWitnessAddress returns the bech32 address of the witness program, bech32m
+// for the versions from 1, like the taproot outputs.
+func (p *ChainParams) ToWitnessAddress(version byte, program []byte) (string, error) {
+	if p.Bech32HRPSegwit == "" {
+		return "", ErrNoSegwit
+	}
+	return bech32.EncodeSegwitAddress(p.Bech32HRPSegwit, version, program)
+}
+
+// ScriptAddress returns the p2sh address of the redeem script.
+func (p *ChainParams) ScriptAddress(redeemScript []byte) string {
+	return p.ToScriptAddress(crypto.Hash160(redeemScript))
+}
+
+// WitnessScriptAddress returns the p2wsh address of the witness script.
+func (p *ChainParams) WitnessScriptAddress(witnessScript []byte) (string, error) {
+	h := crypto.Sha256(witnessScript)
+	return p.ToWitnessAddress(0, h[:])
+}
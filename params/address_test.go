@@ -0,0 +1,29 @@
+package params
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestScriptAddress(t *testing.T) {
+	btc := GetChain(BTC)
+
+	// BIP67 2-of-2
+	s, _ := hex.DecodeString("522102fe6f0a5a297eb38c391581c4413e084773ea23954d93f7753db7dc0adc188b2f2102ff12471208c14bd580709cb2358d98975247d8765f92bc25eab3b2763ed605f852ae")
+	if addr := btc.ScriptAddress(s); addr != "39bgKC7RFbpoCRbtD5KEdkYKtNyhpsNa3Z" {
+		t.Errorf("ScriptAddress = %s, except 39bgKC7RFbpoCRbtD5KEdkYKtNyhpsNa3Z", addr)
+	}
+
+	s, _ = hex.DecodeString("522102466d7fcae563e5cb09a0d1870bb580344804617879a14949cf22285f1bae3f272102d0de0aaeaefad02b8bdc8a01a1b8b11c696bd3d66a2c5f10780d95b7df42645c21034f355bdcb7cc0af728ef3cceb9615d90684bb5b2ca5f859ab0f0b704075871aa53ae")
+	if addr := btc.ScriptAddress(s); addr != "3Mi3fiXzA2AhKMsWdQJ2LqoV1JiCrtC8SA" {
+		t.Errorf("ScriptAddress = %s, except 3Mi3fiXzA2AhKMsWdQJ2LqoV1JiCrtC8SA", addr)
+	}
+	addr, err := btc.WitnessScriptAddress(s)
+	if except := "bc1q5m9v2tp0v3h05hnu72h2p7uqc8ze2yfm32z9kz66cpar30fy8muq2ppsrj"; err != nil || addr != except {
+		t.Errorf("WitnessScriptAddress = %s, except %s, err %v", addr, except, err)
+	}
+
+	if _, err := GetChain(BCC).WitnessScriptAddress(s); err != ErrNoSegwit {
+		t.Errorf("WitnessScriptAddress of bcc error %v, except %v", err, ErrNoSegwit)
+	}
+}
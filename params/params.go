@@ -19,9 +19,14 @@ type ChainParams struct {
 	// Segwit
 	WitnessPubkeyPrefix     byte
 	WitnessScriptAddrPrefix byte
+	// Bech32HRPSegwit is the human-readable part of the bech32 segwit
+	// addresses, it is empty if the chain has no segwit.
+	Bech32HRPSegwit string
 
 	HDPrivateKeyPrefix [4]byte
 	HDPublicKeyPrefix  [4]byte
+	// HDCoinType is the BIP44 coin type used in the derivation path.
+	HDCoinType uint32
 
 	// DNSSeeds                []string
 	// GenesisBlock            Block
@@ -60,9 +65,11 @@ var (
 
 		WitnessPubkeyPrefix:     0,
 		WitnessScriptAddrPrefix: 0,
+		Bech32HRPSegwit:         "bc",
 
 		HDPrivateKeyPrefix: [4]byte{0x04, 0x88, 0xad, 0xe4},
 		HDPublicKeyPrefix:  [4]byte{0x04, 0x88, 0xb2, 0x1e},
+		HDCoinType:         0,
 
 		DefaultPort: 8333,
 		RPCPort:     8332,
@@ -88,9 +95,11 @@ var (
 
 		WitnessPubkeyPrefix:     0,
 		WitnessScriptAddrPrefix: 0,
+		Bech32HRPSegwit:         "ltc",
 
 		HDPrivateKeyPrefix: [4]byte{0x04, 0x88, 0xad, 0xe4},
 		HDPublicKeyPrefix:  [4]byte{0x04, 0x88, 0xb2, 0x1e},
+		HDCoinType:         2,
 
 		DefaultPort: 9333,
 		RPCPort:     9332,
@@ -118,6 +127,7 @@ var (
 
 		HDPrivateKeyPrefix: [4]byte{0x04, 0x88, 0xad, 0xe4},
 		HDPublicKeyPrefix:  [4]byte{0x04, 0x88, 0xb2, 0x1e},
+		HDCoinType:         145,
 
 		DefaultPort: 8333,
 		RPCPort:     8332,
@@ -144,8 +154,9 @@ var (
 		// WitnessPubkeyPrefix:     0,
 		// WitnessScriptAddrPrefix: 0,
 
-		// HDPrivateKeyPrefix: [4]byte{0x00, 0x, 0xad, 0xe4},
-		// HDPublicKeyPrefix:  [4]byte{0x04, 0x88, 0xb2, 0x1e},
+		HDPrivateKeyPrefix: [4]byte{0x04, 0x88, 0xad, 0xe4},
+		HDPublicKeyPrefix:  [4]byte{0x04, 0x88, 0xb2, 0x1e},
+		HDCoinType:         60,
 
 		DefaultPort: 30303,
 		RPCPort:     8545,
@@ -168,8 +179,9 @@ var (
 		// WitnessPubkeyPrefix:     0,
 		// WitnessScriptAddrPrefix: 0,
 
-		// HDPrivateKeyPrefix: [4]byte{0x00, 0x, 0xad, 0xe4},
-		// HDPublicKeyPrefix:  [4]byte{0x04, 0x88, 0xb2, 0x1e},
+		HDPrivateKeyPrefix: [4]byte{0x04, 0x88, 0xad, 0xe4},
+		HDPublicKeyPrefix:  [4]byte{0x04, 0x88, 0xb2, 0x1e},
+		HDCoinType:         61,
 
 		DefaultPort: 30303,
 		RPCPort:     8545,
@@ -196,6 +208,7 @@ var (
 
 		HDPrivateKeyPrefix: [4]byte{0x04, 0x88, 0xad, 0xe4},
 		HDPublicKeyPrefix:  [4]byte{0x04, 0x88, 0xb2, 0x1e},
+		HDCoinType:         144,
 
 		DefaultPort: 8333,
 		RPCPort:     8332,
@@ -219,19 +232,28 @@ var (
 // name is one of 'mainnet', 'testnet', or 'regtest'
 // Default chain is 'mainnet'
 func SelectChain(ct string) *ChainParams {
+	if chain := GetChain(ct); chain != nil {
+		Params = chain
+	}
+	return Params
+}
+
+// GetChain returns the chain parameters of coin type ct without changing
+// the selected Params, it returns nil if ct is unknown.
+func GetChain(ct string) *ChainParams {
 	switch strings.ToLower(ct) {
 	case BTC:
-		Params = btcMainnetParams
+		return btcMainnetParams
 	case LTC:
-		Params = ltcMainnetParams
+		return ltcMainnetParams
 	case BCC:
-		Params = bccMainnetParams
+		return bccMainnetParams
 	case ETH:
-		Params = ethMainnetParams
+		return ethMainnetParams
 	case ETC:
-		Params = etcMainnetParams
+		return etcMainnetParams
 	case XRP:
-		Params = rippleMainnetParams
+		return rippleMainnetParams
 	}
-	return Params
+	return nil
 }
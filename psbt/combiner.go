@@ -0,0 +1,100 @@
+package psbt
+
+import (
+	"bytes"
+	"errors"
+)
+
+var ErrPacketNotMatch = errors.New("psbts of different transactions")
+
+// Combine merges the maps of the other psbts of the same transaction into p,
+// the fields already in p are kept.
+func (p *Packet) Combine(others ...*Packet) error {
+	for _, other := range others {
+		if other.UnsignedTx.TxHash() != p.UnsignedTx.TxHash() {
+			return ErrPacketNotMatch
+		}
+	}
+
+	for _, other := range others {
+		p.Unknowns = combineUnknowns(p.Unknowns, other.Unknowns)
+		for i, in := range p.Inputs {
+			in.combine(other.Inputs[i])
+		}
+		for i, out := range p.Outputs {
+			out.combine(other.Outputs[i])
+		}
+	}
+	return nil
+}
+
+func (in *Input) combine(other *Input) {
+	if in.NonWitnessUtxo == nil {
+		in.NonWitnessUtxo = other.NonWitnessUtxo
+	}
+	if in.WitnessUtxo == nil {
+		in.WitnessUtxo = other.WitnessUtxo
+	}
+	for _, ps := range other.PartialSigs {
+		if in.partialSig(ps.Pubkey) == nil {
+			in.PartialSigs = append(in.PartialSigs, ps)
+		}
+	}
+	if in.SigHashType == 0 {
+		in.SigHashType = other.SigHashType
+	}
+	if in.RedeemScript == nil {
+		in.RedeemScript = other.RedeemScript
+	}
+	if in.WitnessScript == nil {
+		in.WitnessScript = other.WitnessScript
+	}
+	in.Bip32Derivation = combineDerivations(in.Bip32Derivation, other.Bip32Derivation)
+	if in.FinalScriptSig == nil {
+		in.FinalScriptSig = other.FinalScriptSig
+	}
+	if in.FinalScriptWitness == nil {
+		in.FinalScriptWitness = other.FinalScriptWitness
+	}
+	in.Unknowns = combineUnknowns(in.Unknowns, other.Unknowns)
+}
+
+func (out *Output) combine(other *Output) {
+	if out.RedeemScript == nil {
+		out.RedeemScript = other.RedeemScript
+	}
+	if out.WitnessScript == nil {
+		out.WitnessScript = other.WitnessScript
+	}
+	out.Bip32Derivation = combineDerivations(out.Bip32Derivation, other.Bip32Derivation)
+	out.Unknowns = combineUnknowns(out.Unknowns, other.Unknowns)
+}
+
+// combineDerivations adds the derivations of others whose public key is not
+// in derivations.
+func combineDerivations(derivations, others []*Bip32Derivation) []*Bip32Derivation {
+	for _, d := range others {
+		found := false
+		for _, old := range derivations {
+			found = found || bytes.Equal(old.Pubkey, d.Pubkey)
+		}
+		if !found {
+			derivations = append(derivations, d)
+		}
+	}
+	return derivations
+}
+
+// combineUnknowns adds the pairs of others whose key is not in unknowns.
+func combineUnknowns(unknowns, others []*Unknown) []*Unknown {
+	for _, u := range others {
+		found := false
+		for _, old := range unknowns {
+			found = found || bytes.Equal(old.Key, u.Key)
+		}
+		if !found {
+			unknowns = append(unknowns, u)
+		}
+	}
+	return unknowns
+}
@@ -0,0 +1,193 @@
+package psbt
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/maiiz/coinlib/crypto"
+	"github.com/maiiz/coinlib/script"
+	"github.com/maiiz/coinlib/types"
+)
+
+var (
+	ErrUnsupportedScript = errors.New("unsupported scriptPubkey")
+	ErrMissingSigs       = errors.New("psbt input has not enough signatures")
+	ErrIncomplete        = errors.New("psbt has inputs not finalized")
+)
+
+// IsFinal reports whether the input has the final scriptSig or witness.
+func (in *Input) IsFinal() bool {
+	return in.FinalScriptSig != nil || in.FinalScriptWitness != nil
+}
+
+// IsComplete reports whether all inputs of the psbt are finalized.
+func (p *Packet) IsComplete() bool {
+	for _, in := range p.Inputs {
+		if !in.IsFinal() {
+			return false
+		}
+	}
+	return true
+}
+
+// Finalize finalizes all inputs of the psbt.
+func (p *Packet) Finalize() error {
+	for i := range p.Inputs {
+		if err := p.FinalizeInput(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FinalizeInput builds the final scriptSig and witness of the input i from the
+// partial signatures, the input spends a P2PKH, P2WPKH, P2SH-P2WPKH output or
+// a multisig by P2SH, P2WSH or P2SH-P2WSH. The fields other than the utxos
+// are cleared.
+func (p *Packet) FinalizeInput(i int) error {
+	utxo, err := p.Utxo(i)
+	if err != nil {
+		return err
+	}
+	in := p.Inputs[i]
+	if in.IsFinal() {
+		return nil
+	}
+
+	var (
+		pkScript  = utxo.ScriptPubkey
+		scriptSig script.Script
+		witness   types.TxWitness
+	)
+	switch {
+	case pkScript.IsP2PKH():
+		sig, pub := in.pubkeyHashSig(pkScript[3:23])
+		if sig == nil {
+			return ErrMissingSigs
+		}
+		scriptSig.PushData(sig)
+		scriptSig.PushData(pub)
+
+	case pkScript.IsP2WPKH():
+		sig, pub := in.pubkeyHashSig(pkScript[2:22])
+		if sig == nil {
+			return ErrMissingSigs
+		}
+		witness = types.TxWitness{sig, pub}
+
+	case pkScript.IsP2WSH():
+		if witness, err = in.multisigWitness(pkScript); err != nil {
+			return err
+		}
+
+	case pkScript.IsP2SH():
+		redeemScript := in.RedeemScript
+		if !bytes.Equal(crypto.Hash160(redeemScript), pkScript[2:22]) {
+			return ErrRedeemScript
+		}
+		switch {
+		case redeemScript.IsP2WPKH():
+			sig, pub := in.pubkeyHashSig(redeemScript[2:22])
+			if sig == nil {
+				return ErrMissingSigs
+			}
+			witness = types.TxWitness{sig, pub}
+
+		case redeemScript.IsP2WSH():
+			if witness, err = in.multisigWitness(redeemScript); err != nil {
+				return err
+			}
+
+		default:
+			sigs, err := in.multisigSigs(redeemScript)
+			if err != nil {
+				return err
+			}
+			scriptSig = script.Script{script.OP_0}
+			for _, sig := range sigs {
+				scriptSig.PushData(sig)
+			}
+		}
+		scriptSig.PushData(redeemScript)
+
+	default:
+		return ErrUnsupportedScript
+	}
+
+	*in = Input{
+		NonWitnessUtxo:     in.NonWitnessUtxo,
+		WitnessUtxo:        in.WitnessUtxo,
+		FinalScriptSig:     scriptSig,
+		FinalScriptWitness: witness,
+		Unknowns:           in.Unknowns,
+	}
+	return nil
+}
+
+// pubkeyHashSig returns the signature and the public key of the pubkey hash.
+func (in *Input) pubkeyHashSig(hash []byte) (sig, pub []byte) {
+	for _, ps := range in.PartialSigs {
+		if bytes.Equal(crypto.Hash160(ps.Pubkey), hash) {
+			return ps.Signature, ps.Pubkey
+		}
+	}
+	return nil, nil
+}
+
+// multisigWitness returns the witness of the p2wsh program: "" <sig>...
+// <witnessScript>.
+func (in *Input) multisigWitness(program script.Script) (types.TxWitness, error) {
+	if !bytes.Equal(program, in.WitnessScript.ToP2WSHScriptPubkey()) {
+		return nil, ErrWitnessScript
+	}
+	sigs, err := in.multisigSigs(in.WitnessScript)
+	if err != nil {
+		return nil, err
+	}
+	witness := types.TxWitness{{}}
+	witness = append(witness, sigs...)
+	return append(witness, in.WitnessScript), nil
+}
+
+// multisigSigs returns the first m signatures of the multisig script in the
+// order of the public keys.
+func (in *Input) multisigSigs(multisig script.Script) ([][]byte, error) {
+	m, pubkeys, ok := script.ParseMultisigScript(multisig)
+	if !ok {
+		return nil, ErrUnsupportedScript
+	}
+	sigs := make([][]byte, 0, m)
+	for _, pub := range pubkeys {
+		if sig := in.partialSig(pub); sig != nil && len(sigs) < m {
+			sigs = append(sigs, sig)
+		}
+	}
+	if len(sigs) < m {
+		return nil, ErrMissingSigs
+	}
+	return sigs, nil
+}
+
+// Extract returns the signed transaction of the finalized psbt.
+func (p *Packet) Extract() (*types.Transaction, error) {
+	if !p.IsComplete() {
+		return nil, ErrIncomplete
+	}
+
+	utx := p.UnsignedTx
+	tx := &types.Transaction{
+		Version:  utx.Version,
+		Vout:     utx.Vout,
+		LockTime: utx.LockTime,
+	}
+	for i, ti := range utx.Vin {
+		in := p.Inputs[i]
+		tx.AddTxIn(&types.TxIn{
+			Prevout:   ti.Prevout,
+			ScriptSig: in.FinalScriptSig,
+			Sequence:  ti.Sequence,
+			Witness:   in.FinalScriptWitness,
+		})
+	}
+	return tx, nil
+}
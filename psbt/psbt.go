@@ -0,0 +1,553 @@
+// Package psbt implements the partially signed bitcoin transactions of BIP174,
+// which carry an unsigned transaction with the information needed to sign it
+// between the creator, updaters, signers, combiner and finalizer.
+package psbt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"github.com/maiiz/coinlib/crypto"
+	"github.com/maiiz/coinlib/crypto/secp256k1"
+	"github.com/maiiz/coinlib/encoding/varint"
+	"github.com/maiiz/coinlib/script"
+	"github.com/maiiz/coinlib/types"
+)
+
+// key types of the global map.
+const (
+	globalUnsignedTx = 0x00
+)
+
+// key types of the input maps.
+const (
+	inNonWitnessUtxo     = 0x00
+	inWitnessUtxo        = 0x01
+	inPartialSig         = 0x02
+	inSigHashType        = 0x03
+	inRedeemScript       = 0x04
+	inWitnessScript      = 0x05
+	inBip32Derivation    = 0x06
+	inFinalScriptSig     = 0x07
+	inFinalScriptWitness = 0x08
+)
+
+// key types of the output maps.
+const (
+	outRedeemScript    = 0x00
+	outWitnessScript   = 0x01
+	outBip32Derivation = 0x02
+)
+
+// maxPsbtSize bounds the lengths of the keys and values read.
+const maxPsbtSize = 100000000
+
+// magic is the header of a psbt: "psbt" 0xff.
+var magic = []byte{0x70, 0x73, 0x62, 0x74, 0xff}
+
+var (
+	ErrInvalidMagic  = errors.New("invalid psbt magic")
+	ErrNoUnsignedTx  = errors.New("psbt without unsigned transaction")
+	ErrTxSigned      = errors.New("unsigned transaction has scriptSig or witness")
+	ErrDuplicateKey  = errors.New("duplicate psbt key")
+	ErrInvalidKey    = errors.New("invalid psbt key")
+	ErrInvalidValue  = errors.New("invalid psbt value")
+	ErrInvalidPubkey = errors.New("invalid psbt pubkey")
+	ErrDataTooLarge  = errors.New("psbt data too large")
+)
+
+// Packet is a partially signed bitcoin transaction, it has an input and an
+// output map for each input and output of the unsigned transaction.
+type Packet struct {
+	UnsignedTx *types.Transaction
+	Inputs     []*Input
+	Outputs    []*Output
+	// Unknowns are the global pairs of unknown types, which are kept as is.
+	Unknowns []*Unknown
+}
+
+// Input holds the information of a transaction input.
+type Input struct {
+	// NonWitnessUtxo is the transaction of the output spent by the input.
+	NonWitnessUtxo *types.Transaction
+	// WitnessUtxo is the output spent by a segwit input.
+	WitnessUtxo        *types.TxOut
+	PartialSigs        []*PartialSig
+	SigHashType        uint32
+	RedeemScript       script.Script
+	WitnessScript      script.Script
+	Bip32Derivation    []*Bip32Derivation
+	FinalScriptSig     script.Script
+	FinalScriptWitness types.TxWitness
+	Unknowns           []*Unknown
+}
+
+// Output holds the information of a transaction output, such as the keys of
+// a change output.
+type Output struct {
+	RedeemScript    script.Script
+	WitnessScript   script.Script
+	Bip32Derivation []*Bip32Derivation
+	Unknowns        []*Unknown
+}
+
+// PartialSig is the signature of a public key with the sighash type appended.
+type PartialSig struct {
+	Pubkey    []byte
+	Signature []byte
+}
+
+// Bip32Derivation is the derivation path of a public key from the master key
+// of fingerprint.
+type Bip32Derivation struct {
+	Pubkey      []byte
+	Fingerprint uint32
+	Path        []uint32
+}
+
+// Unknown is a key-value pair of an unknown type.
+type Unknown struct {
+	Key   []byte
+	Value []byte
+}
+
+// New returns a psbt of the unsigned transaction with empty maps, the inputs
+// must have no scriptSig and witness.
+func New(tx *types.Transaction) (*Packet, error) {
+	for _, ti := range tx.Vin {
+		if len(ti.ScriptSig) != 0 || len(ti.Witness) != 0 {
+			return nil, ErrTxSigned
+		}
+	}
+
+	p := &Packet{
+		UnsignedTx: tx,
+		Inputs:     make([]*Input, len(tx.Vin)),
+		Outputs:    make([]*Output, len(tx.Vout)),
+	}
+	for i := range p.Inputs {
+		p.Inputs[i] = new(Input)
+	}
+	for i := range p.Outputs {
+		p.Outputs[i] = new(Output)
+	}
+	return p, nil
+}
+
+// Parse decodes a binary psbt.
+func Parse(b []byte) (*Packet, error) {
+	p := new(Packet)
+	r := bytes.NewReader(b)
+	if err := p.Unmarshal(r); err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		return nil, ErrInvalidValue
+	}
+	return p, nil
+}
+
+// ParseBase64 decodes a base64 psbt.
+func ParseBase64(s string) (*Packet, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Bytes returns the binary psbt.
+func (p *Packet) Bytes() []byte {
+	buf := new(bytes.Buffer)
+	p.Marshal(buf)
+	return buf.Bytes()
+}
+
+// Base64 returns the base64 psbt.
+func (p *Packet) Base64() string {
+	return base64.StdEncoding.EncodeToString(p.Bytes())
+}
+
+// Marshal encodes the psbt to writer.
+func (p *Packet) Marshal(w io.Writer) {
+	w.Write(magic)
+
+	buf := new(bytes.Buffer)
+	p.UnsignedTx.MarshalNoWitness(buf)
+	writePair(w, globalUnsignedTx, nil, buf.Bytes())
+	writeUnknowns(w, p.Unknowns)
+	w.Write([]byte{0x00})
+
+	for _, in := range p.Inputs {
+		in.marshal(w)
+	}
+	for _, out := range p.Outputs {
+		out.marshal(w)
+	}
+}
+
+// Unmarshal decodes reader to the psbt.
+func (p *Packet) Unmarshal(r io.Reader) error {
+	header := make([]byte, len(magic))
+	if _, err := io.ReadFull(r, header); err != nil {
+		return err
+	}
+	if !bytes.Equal(header, magic) {
+		return ErrInvalidMagic
+	}
+
+	*p = Packet{}
+	keys := make(map[string]bool)
+	for {
+		key, value, err := readPair(r, keys)
+		if err != nil {
+			return err
+		}
+		if key == nil {
+			break
+		}
+
+		switch key[0] {
+		case globalUnsignedTx:
+			if len(key) != 1 {
+				return ErrInvalidKey
+			}
+			tx := new(types.Transaction)
+			if err := decode(value, tx.UnmarshalNoWitness); err != nil {
+				return err
+			}
+			p.UnsignedTx = tx
+		default:
+			p.Unknowns = append(p.Unknowns, &Unknown{Key: key, Value: value})
+		}
+	}
+	if p.UnsignedTx == nil {
+		return ErrNoUnsignedTx
+	}
+	for _, ti := range p.UnsignedTx.Vin {
+		if len(ti.ScriptSig) != 0 || len(ti.Witness) != 0 {
+			return ErrTxSigned
+		}
+	}
+
+	p.Inputs = make([]*Input, len(p.UnsignedTx.Vin))
+	for i := range p.Inputs {
+		in := new(Input)
+		if err := in.unmarshal(r); err != nil {
+			return err
+		}
+		p.Inputs[i] = in
+	}
+	p.Outputs = make([]*Output, len(p.UnsignedTx.Vout))
+	for i := range p.Outputs {
+		out := new(Output)
+		if err := out.unmarshal(r); err != nil {
+			return err
+		}
+		p.Outputs[i] = out
+	}
+	return nil
+}
+
+func (in *Input) marshal(w io.Writer) {
+	if in.NonWitnessUtxo != nil {
+		buf := new(bytes.Buffer)
+		in.NonWitnessUtxo.Marshal(buf)
+		writePair(w, inNonWitnessUtxo, nil, buf.Bytes())
+	}
+	if in.WitnessUtxo != nil {
+		buf := new(bytes.Buffer)
+		in.WitnessUtxo.Marshal(buf)
+		writePair(w, inWitnessUtxo, nil, buf.Bytes())
+	}
+
+	// sorted by the key id as bitcoin core, a copy is sorted to keep the
+	// order of the caller.
+	sigs := append([]*PartialSig(nil), in.PartialSigs...)
+	sort.Slice(sigs, func(i, j int) bool {
+		return bytes.Compare(crypto.Hash160(sigs[i].Pubkey), crypto.Hash160(sigs[j].Pubkey)) < 0
+	})
+	for _, ps := range sigs {
+		writePair(w, inPartialSig, ps.Pubkey, ps.Signature)
+	}
+	if in.SigHashType != 0 {
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, in.SigHashType)
+		writePair(w, inSigHashType, nil, b)
+	}
+	if in.RedeemScript != nil {
+		writePair(w, inRedeemScript, nil, in.RedeemScript)
+	}
+	if in.WitnessScript != nil {
+		writePair(w, inWitnessScript, nil, in.WitnessScript)
+	}
+	writeDerivations(w, inBip32Derivation, in.Bip32Derivation)
+
+	if in.FinalScriptSig != nil {
+		writePair(w, inFinalScriptSig, nil, in.FinalScriptSig)
+	}
+	if in.FinalScriptWitness != nil {
+		buf := new(bytes.Buffer)
+		in.FinalScriptWitness.Marshal(buf)
+		writePair(w, inFinalScriptWitness, nil, buf.Bytes())
+	}
+	writeUnknowns(w, in.Unknowns)
+	w.Write([]byte{0x00})
+}
+
+func (in *Input) unmarshal(r io.Reader) error {
+	keys := make(map[string]bool)
+	for {
+		key, value, err := readPair(r, keys)
+		if err != nil {
+			return err
+		}
+		if key == nil {
+			return nil
+		}
+
+		switch key[0] {
+		case inNonWitnessUtxo:
+			if len(key) != 1 {
+				return ErrInvalidKey
+			}
+			tx := new(types.Transaction)
+			if err := decode(value, tx.Unmarshal); err != nil {
+				return err
+			}
+			in.NonWitnessUtxo = tx
+
+		case inWitnessUtxo:
+			if len(key) != 1 {
+				return ErrInvalidKey
+			}
+			out := new(types.TxOut)
+			if err := decode(value, out.Unmarshal); err != nil {
+				return err
+			}
+			in.WitnessUtxo = out
+
+		case inPartialSig:
+			if !validPubkey(key[1:]) {
+				return ErrInvalidPubkey
+			}
+			in.PartialSigs = append(in.PartialSigs, &PartialSig{Pubkey: key[1:], Signature: value})
+
+		case inSigHashType:
+			if len(key) != 1 {
+				return ErrInvalidKey
+			}
+			if len(value) != 4 {
+				return ErrInvalidValue
+			}
+			in.SigHashType = binary.LittleEndian.Uint32(value)
+
+		case inRedeemScript:
+			if len(key) != 1 {
+				return ErrInvalidKey
+			}
+			in.RedeemScript = value
+
+		case inWitnessScript:
+			if len(key) != 1 {
+				return ErrInvalidKey
+			}
+			in.WitnessScript = value
+
+		case inBip32Derivation:
+			d, err := readDerivation(key, value)
+			if err != nil {
+				return err
+			}
+			in.Bip32Derivation = append(in.Bip32Derivation, d)
+
+		case inFinalScriptSig:
+			if len(key) != 1 {
+				return ErrInvalidKey
+			}
+			in.FinalScriptSig = value
+
+		case inFinalScriptWitness:
+			if len(key) != 1 {
+				return ErrInvalidKey
+			}
+			var witness types.TxWitness
+			if err := decode(value, witness.Unmarshal); err != nil {
+				return err
+			}
+			in.FinalScriptWitness = witness
+
+		default:
+			in.Unknowns = append(in.Unknowns, &Unknown{Key: key, Value: value})
+		}
+	}
+}
+
+func (out *Output) marshal(w io.Writer) {
+	if out.RedeemScript != nil {
+		writePair(w, outRedeemScript, nil, out.RedeemScript)
+	}
+	if out.WitnessScript != nil {
+		writePair(w, outWitnessScript, nil, out.WitnessScript)
+	}
+	writeDerivations(w, outBip32Derivation, out.Bip32Derivation)
+	writeUnknowns(w, out.Unknowns)
+	w.Write([]byte{0x00})
+}
+
+func (out *Output) unmarshal(r io.Reader) error {
+	keys := make(map[string]bool)
+	for {
+		key, value, err := readPair(r, keys)
+		if err != nil {
+			return err
+		}
+		if key == nil {
+			return nil
+		}
+
+		switch key[0] {
+		case outRedeemScript:
+			if len(key) != 1 {
+				return ErrInvalidKey
+			}
+			out.RedeemScript = value
+
+		case outWitnessScript:
+			if len(key) != 1 {
+				return ErrInvalidKey
+			}
+			out.WitnessScript = value
+
+		case outBip32Derivation:
+			d, err := readDerivation(key, value)
+			if err != nil {
+				return err
+			}
+			out.Bip32Derivation = append(out.Bip32Derivation, d)
+
+		default:
+			out.Unknowns = append(out.Unknowns, &Unknown{Key: key, Value: value})
+		}
+	}
+}
+
+// writePair writes the key of type typ with data and the value.
+func writePair(w io.Writer, typ byte, keyData, value []byte) {
+	varint.WriteVarInt(w, uint64(1+len(keyData)))
+	w.Write([]byte{typ})
+	w.Write(keyData)
+	varint.WriteVarInt(w, uint64(len(value)))
+	w.Write(value)
+}
+
+func writeUnknowns(w io.Writer, unknowns []*Unknown) {
+	for _, u := range unknowns {
+		writePair(w, u.Key[0], u.Key[1:], u.Value)
+	}
+}
+
+// writeDerivations writes the derivations sorted by public key, the value is
+// the fingerprint followed by the little endian path.
+func writeDerivations(w io.Writer, typ byte, derivations []*Bip32Derivation) {
+	derivations = append([]*Bip32Derivation(nil), derivations...)
+	sort.Slice(derivations, func(i, j int) bool {
+		return bytes.Compare(derivations[i].Pubkey, derivations[j].Pubkey) < 0
+	})
+	for _, d := range derivations {
+		value := make([]byte, 4+4*len(d.Path))
+		binary.BigEndian.PutUint32(value, d.Fingerprint)
+		for i, index := range d.Path {
+			binary.LittleEndian.PutUint32(value[4+4*i:], index)
+		}
+		writePair(w, typ, d.Pubkey, value)
+	}
+}
+
+// readPair reads a key-value pair of a map, the key is nil at the separator
+// of the map. The keys read are recorded to reject duplicates.
+func readPair(r io.Reader, keys map[string]bool) (key, value []byte, err error) {
+	if key, err = readBytes(r); err != nil || len(key) == 0 {
+		return nil, nil, err
+	}
+	if keys[string(key)] {
+		return nil, nil, ErrDuplicateKey
+	}
+	keys[string(key)] = true
+
+	if value, err = readBytes(r); err != nil {
+		return nil, nil, err
+	}
+	return key, value, nil
+}
+
+func readDerivation(key, value []byte) (*Bip32Derivation, error) {
+	if !validPubkey(key[1:]) {
+		return nil, ErrInvalidPubkey
+	}
+	if len(value) < 4 || len(value)%4 != 0 {
+		return nil, ErrInvalidValue
+	}
+
+	d := &Bip32Derivation{
+		Pubkey:      key[1:],
+		Fingerprint: binary.BigEndian.Uint32(value),
+		Path:        make([]uint32, len(value)/4-1),
+	}
+	for i := range d.Path {
+		d.Path[i] = binary.LittleEndian.Uint32(value[4+4*i:])
+	}
+	return d, nil
+}
+
+// readBytes reads varint length prefixed bytes, the buffer grows with the
+// bytes read so a forged length does not allocate beyond the input.
+func readBytes(r io.Reader) ([]byte, error) {
+	n, err := varint.ReadVarInt(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxPsbtSize {
+		return nil, ErrDataTooLarge
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(r, int64(n)))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(b)) != n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return b, nil
+}
+
+// decode decodes the value with unmarshal, which must consume all of it.
+func decode(value []byte, unmarshal func(io.Reader) error) error {
+	r := bytes.NewReader(value)
+	if err := unmarshal(r); err != nil {
+		return err
+	}
+	if r.Len() != 0 {
+		return ErrInvalidValue
+	}
+	return nil
+}
+
+// validPubkey reports whether b is a compressed or uncompressed public key on
+// the curve.
+func validPubkey(b []byte) bool {
+	switch {
+	case len(b) == 33 && (b[0] == 0x02 || b[0] == 0x03):
+		_, err := secp256k1.DecompressPubkey(b)
+		return err == nil
+	case len(b) == 65 && b[0] == 0x04:
+		pub := secp256k1.ToECDSAPub(b)
+		return pub.X != nil && secp256k1.S256().IsOnCurve(pub.X, pub.Y)
+	}
+	return false
+}
@@ -0,0 +1,235 @@
+package psbt
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/maiiz/coinlib/script"
+	"github.com/maiiz/coinlib/types"
+)
+
+var (
+	ErrInputIndex    = errors.New("psbt input index out of range")
+	ErrOutputIndex   = errors.New("psbt output index out of range")
+	ErrNoUtxo        = errors.New("psbt input without utxo")
+	ErrUtxoNotMatch  = errors.New("utxo not match the outpoint of input")
+	ErrRedeemScript  = errors.New("redeem script not match scriptPubkey")
+	ErrWitnessScript = errors.New("witness script not match scriptPubkey")
+	ErrSigHashType   = errors.New("signature not match sighash type of input")
+	ErrInputFinal    = errors.New("psbt input is finalized")
+)
+
+// Utxo returns the output spent by the input i, which is the witness utxo or
+// the output of the non-witness utxo.
+func (p *Packet) Utxo(i int) (*types.TxOut, error) {
+	if i < 0 || i >= len(p.Inputs) {
+		return nil, ErrInputIndex
+	}
+	in := p.Inputs[i]
+	if in.WitnessUtxo != nil {
+		return in.WitnessUtxo, nil
+	}
+	if in.NonWitnessUtxo == nil {
+		return nil, ErrNoUtxo
+	}
+
+	prevout := p.UnsignedTx.Vin[i].Prevout
+	if in.NonWitnessUtxo.TxHash() != prevout.Hash || int(prevout.Index) >= len(in.NonWitnessUtxo.Vout) {
+		return nil, ErrUtxoNotMatch
+	}
+	return in.NonWitnessUtxo.Vout[prevout.Index], nil
+}
+
+func (p *Packet) input(i int) (*Input, error) {
+	if i < 0 || i >= len(p.Inputs) {
+		return nil, ErrInputIndex
+	}
+	return p.Inputs[i], nil
+}
+
+func (p *Packet) output(i int) (*Output, error) {
+	if i < 0 || i >= len(p.Outputs) {
+		return nil, ErrOutputIndex
+	}
+	return p.Outputs[i], nil
+}
+
+// AddInNonWitnessUtxo sets the transaction spent by the input i, it is needed
+// to sign a non-segwit input.
+func (p *Packet) AddInNonWitnessUtxo(i int, tx *types.Transaction) error {
+	in, err := p.input(i)
+	if err != nil {
+		return err
+	}
+	prevout := p.UnsignedTx.Vin[i].Prevout
+	if tx.TxHash() != prevout.Hash || int(prevout.Index) >= len(tx.Vout) {
+		return ErrUtxoNotMatch
+	}
+	in.NonWitnessUtxo = tx
+	return nil
+}
+
+// AddInWitnessUtxo sets the output spent by the segwit input i.
+func (p *Packet) AddInWitnessUtxo(i int, out *types.TxOut) error {
+	in, err := p.input(i)
+	if err != nil {
+		return err
+	}
+	in.WitnessUtxo = out
+	return nil
+}
+
+// AddInSigHashType sets the sighash type the input i must be signed with.
+func (p *Packet) AddInSigHashType(i int, hashType uint32) error {
+	in, err := p.input(i)
+	if err != nil {
+		return err
+	}
+	in.SigHashType = hashType
+	return nil
+}
+
+// AddInRedeemScript sets the redeem script of the p2sh input i, it must match
+// the scriptPubkey if the utxo is known.
+func (p *Packet) AddInRedeemScript(i int, redeemScript script.Script) error {
+	in, err := p.input(i)
+	if err != nil {
+		return err
+	}
+	if utxo, err := p.Utxo(i); err == nil && !bytes.Equal(utxo.ScriptPubkey, redeemScript.ToP2SHScriptPubkey()) {
+		return ErrRedeemScript
+	}
+	in.RedeemScript = redeemScript
+	return nil
+}
+
+// AddInWitnessScript sets the witness script of the p2wsh or p2sh-p2wsh input
+// i, it must match the witness program if the utxo or redeem script is known.
+func (p *Packet) AddInWitnessScript(i int, witnessScript script.Script) error {
+	in, err := p.input(i)
+	if err != nil {
+		return err
+	}
+	program := in.RedeemScript
+	if utxo, err := p.Utxo(i); err == nil && utxo.ScriptPubkey.IsP2WSH() {
+		program = utxo.ScriptPubkey
+	}
+	if program != nil && !bytes.Equal(program, witnessScript.ToP2WSHScriptPubkey()) {
+		return ErrWitnessScript
+	}
+	in.WitnessScript = witnessScript
+	return nil
+}
+
+// AddInBip32Derivation sets the derivation path of a public key of the input
+// i from the master key of fingerprint.
+func (p *Packet) AddInBip32Derivation(i int, pubkey []byte, fingerprint uint32, path []uint32) error {
+	in, err := p.input(i)
+	if err != nil {
+		return err
+	}
+	derivations, err := addDerivation(in.Bip32Derivation, pubkey, fingerprint, path)
+	if err != nil {
+		return err
+	}
+	in.Bip32Derivation = derivations
+	return nil
+}
+
+// AddPartialSig adds the signature of a public key to the input i, the
+// signature must end with the sighash type of the input if it is set.
+func (p *Packet) AddPartialSig(i int, pubkey, sig []byte) error {
+	in, err := p.input(i)
+	if err != nil {
+		return err
+	}
+	if in.IsFinal() {
+		return ErrInputFinal
+	}
+	if !validPubkey(pubkey) {
+		return ErrInvalidPubkey
+	}
+	if len(sig) == 0 || (in.SigHashType != 0 && uint32(sig[len(sig)-1]) != in.SigHashType) {
+		return ErrSigHashType
+	}
+
+	if old := in.partialSig(pubkey); old != nil {
+		if !bytes.Equal(old, sig) {
+			return ErrDuplicateKey
+		}
+		return nil
+	}
+	in.PartialSigs = append(in.PartialSigs, &PartialSig{Pubkey: pubkey, Signature: sig})
+	return nil
+}
+
+// partialSig returns the signature of the public key in the input.
+func (in *Input) partialSig(pubkey []byte) []byte {
+	for _, ps := range in.PartialSigs {
+		if bytes.Equal(ps.Pubkey, pubkey) {
+			return ps.Signature
+		}
+	}
+	return nil
+}
+
+// AddOutRedeemScript sets the redeem script of the p2sh output i.
+func (p *Packet) AddOutRedeemScript(i int, redeemScript script.Script) error {
+	out, err := p.output(i)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(p.UnsignedTx.Vout[i].ScriptPubkey, redeemScript.ToP2SHScriptPubkey()) {
+		return ErrRedeemScript
+	}
+	out.RedeemScript = redeemScript
+	return nil
+}
+
+// AddOutWitnessScript sets the witness script of the p2wsh or p2sh-p2wsh
+// output i.
+func (p *Packet) AddOutWitnessScript(i int, witnessScript script.Script) error {
+	out, err := p.output(i)
+	if err != nil {
+		return err
+	}
+	program := out.RedeemScript
+	if pkScript := p.UnsignedTx.Vout[i].ScriptPubkey; pkScript.IsP2WSH() {
+		program = pkScript
+	}
+	if !bytes.Equal(program, witnessScript.ToP2WSHScriptPubkey()) {
+		return ErrWitnessScript
+	}
+	out.WitnessScript = witnessScript
+	return nil
+}
+
+// AddOutBip32Derivation sets the derivation path of a public key of the
+// output i, it lets the signer verify a change output.
+func (p *Packet) AddOutBip32Derivation(i int, pubkey []byte, fingerprint uint32, path []uint32) error {
+	out, err := p.output(i)
+	if err != nil {
+		return err
+	}
+	derivations, err := addDerivation(out.Bip32Derivation, pubkey, fingerprint, path)
+	if err != nil {
+		return err
+	}
+	out.Bip32Derivation = derivations
+	return nil
+}
+
+// addDerivation adds the derivation of pubkey or replaces the existing one.
+func addDerivation(derivations []*Bip32Derivation, pubkey []byte, fingerprint uint32, path []uint32) ([]*Bip32Derivation, error) {
+	if !validPubkey(pubkey) {
+		return nil, ErrInvalidPubkey
+	}
+	d := &Bip32Derivation{Pubkey: pubkey, Fingerprint: fingerprint, Path: path}
+	for i, old := range derivations {
+		if bytes.Equal(old.Pubkey, pubkey) {
+			derivations[i] = d
+			return derivations, nil
+		}
+	}
+	return append(derivations, d), nil
+}
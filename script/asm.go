@@ -0,0 +1,149 @@
+package script
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// opCodeNames maps the names of the opcodes to the opcodes, the names are
+// with the OP_ prefix and the small integers are OP_0 ... OP_16.
+var opCodeNames = func() map[string]int {
+	m := map[string]int{
+		"OP_0":       OP_0,
+		"OP_FALSE":   OP_FALSE,
+		"OP_1NEGATE": OP_1NEGATE,
+		"OP_TRUE":    OP_TRUE,
+		"OP_NOP2":    OP_NOP2,
+		"OP_NOP3":    OP_NOP3,
+	}
+	for n := 1; n <= 16; n++ {
+		m[fmt.Sprintf("OP_%d", n)] = EncodeOPN(n)
+	}
+	for op := 0; op <= 0xff; op++ {
+		if name := GetOpName(op); strings.HasPrefix(name, "OP_") && name != "OP_UNKNOWN" {
+			m[name] = op
+		}
+	}
+	return m
+}()
+
+// opName returns the asm name of the opcode, the small integers are named
+// OP_0 ... OP_16 unlike GetOpName.
+func opName(opCode int) string {
+	switch {
+	case opCode == OP_0:
+		return "OP_0"
+	case opCode == OP_1NEGATE:
+		return "OP_1NEGATE"
+	case OP_1 <= opCode && opCode <= OP_16:
+		return fmt.Sprintf("OP_%d", DecodeOPN(opCode))
+	}
+	return GetOpName(opCode)
+}
+
+// isCanonicalPush reports whether the data is pushed by the opcode PushData
+// uses for it.
+func isCanonicalPush(opCode int, data []byte) bool {
+	switch n := len(data); {
+	case n == 0:
+		return false
+	case n < OP_PUSHDATA1:
+		return opCode == n
+	case n <= 0xff:
+		return opCode == OP_PUSHDATA1
+	case n <= 0xffff:
+		return opCode == OP_PUSHDATA2
+	}
+	return opCode == OP_PUSHDATA4
+}
+
+// Disasm returns the assembly of the script: the opcodes are named as
+// OP_DUP, the data pushes are in hex, and the pushes not encoded as PushData
+// does, unknown opcodes or a malformed push at the end are the raw bytes in
+// 0x prefixed hex. ParseAsm parses it back to the script.
+func (s Script) Disasm() string {
+	var words []string
+	for i := 0; i < len(s); {
+		opCode, data, next, ok := s.GetOp(i)
+		switch {
+		case !ok:
+			next = len(s)
+			fallthrough
+		case opCode > OP_16 && opName(opCode) == "OP_UNKNOWN",
+			opCode <= OP_PUSHDATA4 && opCode != OP_0 && !isCanonicalPush(opCode, data):
+			words = append(words, "0x"+hex.EncodeToString(s[i:next]))
+		case opCode <= OP_PUSHDATA4 && opCode != OP_0:
+			words = append(words, hex.EncodeToString(data))
+		default:
+			words = append(words, opName(opCode))
+		}
+		i = next
+	}
+	return strings.Join(words, " ")
+}
+
+// ParseAsm parses the script assembly of Disasm: the opcodes are named with
+// the OP_ prefix, hex is pushed as data and 0x prefixed hex is inserted as
+// raw bytes, e.g. "OP_DUP OP_HASH160 <20 bytes hex> OP_EQUALVERIFY OP_CHECKSIG".
+func ParseAsm(asm string) (Script, error) {
+	var result Script
+	for _, w := range strings.Fields(asm) {
+		if op, ok := opCodeNames[w]; ok {
+			result.AddOpCode(op)
+			continue
+		}
+		raw := strings.HasPrefix(w, "0x")
+		b, err := hex.DecodeString(strings.TrimPrefix(w, "0x"))
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("invalid script token %q", w)
+		}
+		if raw {
+			result.AddBytes(b)
+		} else {
+			result.PushData(b)
+		}
+	}
+	return result, nil
+}
+
+// ParseShortForm parses the script assembly of the Bitcoin Core test vectors:
+// decimal numbers are pushed as script numbers, 0x prefixed hex is inserted
+// as raw bytes, 'quoted' strings are pushed and the others are opcodes with
+// or without the OP_ prefix, e.g. "DUP HASH160 0x14 0x<20 bytes> EQUALVERIFY".
+func ParseShortForm(asm string) (Script, error) {
+	var result Script
+	for _, w := range strings.Fields(asm) {
+		if n, err := strconv.ParseInt(w, 10, 64); err == nil {
+			result.AddInt64(n)
+		} else if strings.HasPrefix(w, "0x") && len(w) > 2 {
+			b, err := hex.DecodeString(w[2:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid script token %q", w)
+			}
+			result.AddBytes(b)
+		} else if len(w) >= 2 && w[0] == '\'' && w[len(w)-1] == '\'' {
+			result.PushData([]byte(w[1 : len(w)-1]))
+		} else if op, ok := shortFormOpCode(w); ok {
+			result.AddOpCode(op)
+		} else {
+			return nil, fmt.Errorf("invalid script token %q", w)
+		}
+	}
+	return result, nil
+}
+
+// shortFormOpCode returns the opcode of the name in the test vectors, the
+// push values are written as numbers so only OP_RESERVED and the opcodes from
+// OP_NOP are named.
+func shortFormOpCode(name string) (int, bool) {
+	if !strings.HasPrefix(name, "OP_") {
+		name = "OP_" + name
+	}
+	op, ok := opCodeNames[name]
+	if !ok || (op < OP_NOP && op != OP_RESERVED) {
+		return 0, false
+	}
+	return op, true
+}
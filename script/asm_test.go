@@ -0,0 +1,106 @@
+package script
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+var asmTests = []struct {
+	script string
+	asm    string
+}{
+	// P2PKH
+	{"76a91489abcdefabbaabbaabbaabbaabbaabbaabbaabba88ac", "OP_DUP OP_HASH160 89abcdefabbaabbaabbaabbaabbaabbaabbaabba OP_EQUALVERIFY OP_CHECKSIG"},
+	// small integers and an empty script
+	{"004f515f60", "OP_0 OP_1NEGATE OP_1 OP_15 OP_16"},
+	{"", ""},
+	// nulldata
+	{"6a0568656c6c6f", "OP_RETURN 68656c6c6f"},
+	// push opcodes PushData does not use are raw
+	{"4c0105", "0x4c0105"},
+	{"4c00", "0x4c00"},
+	{"0105", "05"},
+	// unknown opcodes
+	{"bb51", "0xbb OP_1"},
+	// OP_NOP2 and OP_NOP3
+	{"b1b2", "OP_CHECKLOCKTIMEVERIFY OP_CHECKSEQUENCEVERIFY"},
+	// malformed pushes at the end
+	{"51030102", "OP_1 0x030102"},
+	{"514d01", "OP_1 0x4d01"},
+}
+
+func TestDisasm(t *testing.T) {
+	for i, test := range asmTests {
+		s, _ := hex.DecodeString(test.script)
+		if asm := Script(s).Disasm(); asm != test.asm {
+			t.Errorf("#%d Disasm %q, except %q", i, asm, test.asm)
+		}
+		parsed, err := ParseAsm(test.asm)
+		if err != nil {
+			t.Errorf("#%d ParseAsm error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(parsed, s) {
+			t.Errorf("#%d ParseAsm %x, except %s", i, []byte(parsed), test.script)
+		}
+	}
+}
+
+func TestParseAsm(t *testing.T) {
+	tests := []struct {
+		asm    string
+		script string
+	}{
+		{"OP_TRUE OP_FALSE OP_NOP2 OP_NOP3", "5100b1b2"},
+		{" OP_DUP\n\tOP_DROP ", "7675"},
+		{"0x0101", "0101"},
+		// the data size picks the push opcode
+		{"00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000", "4c4c00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000"},
+	}
+	for i, test := range tests {
+		s, err := ParseAsm(test.asm)
+		if err != nil {
+			t.Errorf("#%d ParseAsm error %v", i, err)
+			continue
+		}
+		if hex.EncodeToString(s) != test.script {
+			t.Errorf("#%d ParseAsm %x, except %s", i, []byte(s), test.script)
+		}
+	}
+
+	for _, asm := range []string{"OP_FOO", "DUP", "1", "abc", "0x", "0xzz"} {
+		if _, err := ParseAsm(asm); err == nil {
+			t.Errorf("ParseAsm %q error nil, except invalid", asm)
+		}
+	}
+}
+
+func TestParseShortForm(t *testing.T) {
+	tests := []struct {
+		asm    string
+		script string
+	}{
+		{"0 -1 1 16 17 1000", "004f5160011102e803"},
+		{"DUP OP_HASH160 0x14 0x89abcdefabbaabbaabbaabbaabbaabbaabbaabba EQUALVERIFY CHECKSIG", "76a91489abcdefabbaabbaabbaabbaabbaabbaabbaabba88ac"},
+		{"'abc' ''", "0361626300"},
+		{"NOP2 CHECKSEQUENCEVERIFY RESERVED", "b1b250"},
+	}
+	for i, test := range tests {
+		s, err := ParseShortForm(test.asm)
+		if err != nil {
+			t.Errorf("#%d ParseShortForm error %v", i, err)
+			continue
+		}
+		if hex.EncodeToString(s) != test.script {
+			t.Errorf("#%d ParseShortForm %x, except %s", i, []byte(s), test.script)
+		}
+	}
+
+	// the push values are numbers
+	for _, asm := range []string{"OP_1", "PUSHDATA1", "FOO", "0xzz"} {
+		if _, err := ParseShortForm(asm); err == nil {
+			t.Errorf("ParseShortForm %q error nil, except invalid", asm)
+		}
+	}
+}
@@ -1,18 +1,20 @@
 package script
 
-import "fmt"
-
 // BigNumber represents the scriptnum in bitcoin.
 type BigNumber int64
 
 const (
 	defaultMaxNumSize = 4
+	// lockTimeMaxNumSize is the size of the operands of CHECKLOCKTIMEVERIFY
+	// and CHECKSEQUENCEVERIFY, 5 bytes are good until 2**39-1.
+	lockTimeMaxNumSize = 5
 )
 
-// BytesToBigNumber decodes bytes to uint64.
+// BytesToBigNumber decodes the little-endian sign-magnitude bytes of a script
+// number of at most maxNumberSize bytes.
 func BytesToBigNumber(d []byte, isRequireMinimal bool, maxNumberSize int) (BigNumber, error) {
 	if len(d) > maxNumberSize {
-		return 0, fmt.Errorf("script number overflow")
+		return 0, ErrNumberOverflow
 	}
 	if isRequireMinimal && len(d) > 0 {
 		// Check that the number is encoded with the minimum possible
@@ -28,13 +30,17 @@ func BytesToBigNumber(d []byte, isRequireMinimal bool, maxNumberSize int) (BigNu
 			// is +-255, which encode to 0xff00 and 0xff80 respectively.
 			// (big-endian).
 			if len(d) <= 1 || (d[len(d)-2]&0x80) == 0 {
-				return 0, fmt.Errorf("non-minimally encoded script number")
+				return 0, ErrNumberNotMinimal
 			}
 		}
 	}
 
+	if len(d) == 0 {
+		return 0, nil
+	}
+
 	var bn BigNumber
-	for i := 1; i != len(d); i++ {
+	for i := 0; i != len(d); i++ {
 		bn |= (BigNumber)(d[i]) << uint8(8*i)
 	}
 
@@ -44,10 +50,9 @@ func BytesToBigNumber(d []byte, isRequireMinimal bool, maxNumberSize int) (BigNu
 		return -(bn & ^(0x80 << uint8(8*(len(d)-1)))), nil
 	}
 	return bn, nil
-
 }
 
-// Bytes encodes int64 to bytes.
+// Bytes encodes the script number, zero is empty.
 func (bn BigNumber) Bytes() []byte {
 	n := int64(bn)
 	if n == 0 {
@@ -60,10 +65,10 @@ func (bn BigNumber) Bytes() []byte {
 		absValue int64
 	)
 
+	absValue = n
 	if isNeg = n < 0; isNeg {
 		absValue = -n
 	}
-	absValue = n
 
 	for absValue > 0 {
 		result = append(result, byte(absValue&0xff))
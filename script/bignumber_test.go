@@ -0,0 +1,69 @@
+package script
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+var bigNumberTests = []struct {
+	n     BigNumber
+	bytes string
+}{
+	{0, ""},
+	{1, "01"},
+	{-1, "81"},
+	{127, "7f"},
+	{-127, "ff"},
+	{128, "8000"},
+	{-128, "8080"},
+	{255, "ff00"},
+	{-255, "ff80"},
+	{256, "0001"},
+	{32767, "ff7f"},
+	{-32768, "008080"},
+	{2147483647, "ffffff7f"},
+	{-2147483647, "ffffffff"},
+	// 5 bytes numbers of CHECKLOCKTIMEVERIFY and CHECKSEQUENCEVERIFY
+	{2147483648, "0000008000"},
+	{4294967295, "ffffffff00"},
+	{549755813887, "ffffffff7f"},
+	{-549755813887, "ffffffffff"},
+}
+
+func TestBigNumber(t *testing.T) {
+	for _, test := range bigNumberTests {
+		if b := hex.EncodeToString(test.n.Bytes()); b != test.bytes {
+			t.Errorf("%d Bytes %s, except %s", test.n, b, test.bytes)
+		}
+		b, _ := hex.DecodeString(test.bytes)
+		n, err := BytesToBigNumber(b, true, lockTimeMaxNumSize)
+		if err != nil || n != test.n {
+			t.Errorf("BytesToBigNumber(%s) = %d, except %d, err %v", test.bytes, n, test.n, err)
+		}
+		if _, err := BytesToBigNumber(b, true, defaultMaxNumSize); len(b) > defaultMaxNumSize && err != ErrNumberOverflow {
+			t.Errorf("BytesToBigNumber(%s) of 4 bytes error %v, except %v", test.bytes, err, ErrNumberOverflow)
+		}
+	}
+
+	tests := []struct {
+		bytes string
+		n     BigNumber
+		err   error
+	}{
+		{"00", 0, ErrNumberNotMinimal},
+		{"80", 0, ErrNumberNotMinimal},
+		{"0100", 1, ErrNumberNotMinimal},
+		{"0180", -1, ErrNumberNotMinimal},
+		{"ff0000", 255, ErrNumberNotMinimal},
+	}
+	for _, test := range tests {
+		b, _ := hex.DecodeString(test.bytes)
+		if _, err := BytesToBigNumber(b, true, defaultMaxNumSize); err != test.err {
+			t.Errorf("BytesToBigNumber(%s) error %v, except %v", test.bytes, err, test.err)
+		}
+		// allowed without minimal encoding
+		if n, err := BytesToBigNumber(b, false, defaultMaxNumSize); err != nil || n != test.n {
+			t.Errorf("BytesToBigNumber(%s) = %d, except %d, err %v", test.bytes, n, test.n, err)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package script
+
+import (
+	"github.com/maiiz/coinlib/crypto"
+	"github.com/maiiz/coinlib/crypto/secp256k1"
+)
+
+// ExecData is the data of a taproot spend committed by its signatures besides
+// the transaction (BIP341, BIP342).
+type ExecData struct {
+	// TapleafHash is the leaf hash of the tapscript of a script path spend.
+	TapleafHash crypto.Hash
+	// CodeSeparatorPos is the opcode position of the last executed
+	// OP_CODESEPARATOR in the tapscript, 0xffffffff if none.
+	CodeSeparatorPos uint32
+	// AnnexHash is the sha256 of the annex of the witness if AnnexPresent.
+	AnnexPresent bool
+	AnnexHash    crypto.Hash
+
+	// validationWeightLeft is the budget of the signatures checked by the
+	// tapscript, it is paid by the size of the witness.
+	validationWeightLeft int64
+}
+
+// newExecData returns the exec data of a script without OP_CODESEPARATOR.
+func newExecData() *ExecData {
+	return &ExecData{CodeSeparatorPos: 0xffffffff}
+}
+
+// SignatureChecker checks the signatures of the transaction input a script
+// is evaluated for.
+type SignatureChecker interface {
+	// CheckSig reports whether the signature, ending with the sighash type, is
+	// signed by the public key for the scriptCode.
+	CheckSig(sig, pubkey []byte, scriptCode Script, sigVersion int) bool
+	// CheckSchnorrSig checks the BIP340 signature, with an optional sighash
+	// type byte, of the x-only public key for the taproot key path spend
+	// (SigVersionTaproot) or a tapscript (SigVersionTapscript).
+	CheckSchnorrSig(sig, pubkey []byte, sigVersion int, execData *ExecData) error
+	// CheckLockTime reports whether the lock time of the transaction
+	// satisfies the CHECKLOCKTIMEVERIFY of lockTime.
+	CheckLockTime(lockTime BigNumber) bool
+	// CheckSequence reports whether the sequence of the input satisfies the
+	// CHECKSEQUENCEVERIFY of sequence.
+	CheckSequence(sequence BigNumber) bool
+}
+
+// BaseSignatureChecker fails all checks, it is used to evaluate scripts out
+// of a transaction.
+type BaseSignatureChecker struct{}
+
+// CheckSig returns false.
+func (BaseSignatureChecker) CheckSig(sig, pubkey []byte, scriptCode Script, sigVersion int) bool {
+	return false
+}
+
+// CheckSchnorrSig returns ErrSchnorrSig.
+func (BaseSignatureChecker) CheckSchnorrSig(sig, pubkey []byte, sigVersion int, execData *ExecData) error {
+	return ErrSchnorrSig
+}
+
+// CheckLockTime returns false.
+func (BaseSignatureChecker) CheckLockTime(lockTime BigNumber) bool {
+	return false
+}
+
+// CheckSequence returns false.
+func (BaseSignatureChecker) CheckSequence(sequence BigNumber) bool {
+	return false
+}
+
+// IsValidSignatureEncoding reports whether the signature with the sighash
+// type is in strict DER (BIP66):
+// 0x30 <len> 0x02 <len R> <R> 0x02 <len S> <S> <hashtype>.
+func IsValidSignatureEncoding(sig []byte) bool {
+	// Minimum and maximum size constraints.
+	if len(sig) < 9 || len(sig) > 73 {
+		return false
+	}
+	// A signature is of type 0x30 (compound) and the length covers the
+	// entire signature but the sighash type.
+	if sig[0] != 0x30 || int(sig[1]) != len(sig)-3 {
+		return false
+	}
+
+	lenR := int(sig[3])
+	if 5+lenR >= len(sig) {
+		return false
+	}
+	lenS := int(sig[5+lenR])
+	if lenR+lenS+7 != len(sig) {
+		return false
+	}
+
+	// R is an integer, not empty, not negative and without padding.
+	if sig[2] != 0x02 || lenR == 0 || sig[4]&0x80 != 0 {
+		return false
+	}
+	if lenR > 1 && sig[4] == 0x00 && sig[5]&0x80 == 0 {
+		return false
+	}
+
+	// The same for S.
+	if sig[lenR+4] != 0x02 || lenS == 0 || sig[lenR+6]&0x80 != 0 {
+		return false
+	}
+	if lenS > 1 && sig[lenR+6] == 0x00 && sig[lenR+7]&0x80 == 0 {
+		return false
+	}
+	return true
+}
+
+// isDefinedHashtypeSignature reports whether the sighash type of the
+// signature is ALL, NONE or SINGLE, with ANYONECANPAY or not.
+func isDefinedHashtypeSignature(sig []byte) bool {
+	if len(sig) == 0 {
+		return false
+	}
+	hashType := sig[len(sig)-1] &^ SighashAnyOneCanPay
+	return hashType >= SigHashAll && hashType <= SigHashSingle
+}
+
+// CheckSignatureEncoding checks the signature against the DERSIG, LOW_S and
+// STRICTENC flags, an empty signature is allowed to fail CHECK(MULTI)SIG.
+func CheckSignatureEncoding(sig []byte, flags int) error {
+	if len(sig) == 0 {
+		return nil
+	}
+	if flags&(ScriptVerifyDERSig|ScriptVerifyLowS|ScriptVerifyStrictenc) != 0 && !IsValidSignatureEncoding(sig) {
+		return ErrSigDER
+	}
+	if flags&ScriptVerifyLowS != 0 && !secp256k1.IsLowS(sig[:len(sig)-1]) {
+		return ErrSigHighS
+	}
+	if flags&ScriptVerifyStrictenc != 0 && !isDefinedHashtypeSignature(sig) {
+		return ErrSigHashType
+	}
+	return nil
+}
+
+// CheckPubkeyEncoding checks the public key against the STRICTENC and
+// WITNESS_PUBKEYTYPE flags.
+func CheckPubkeyEncoding(pubkey []byte, flags, sigVersion int) error {
+	if flags&ScriptVerifyStrictenc != 0 && !isCompressedOrUncompressedPubkey(pubkey) {
+		return ErrPubkeyType
+	}
+	if flags&ScriptVerifyWitnessPubkeyType != 0 && sigVersion == SigVersionWitnessV0 && !isCompressedPubkey(pubkey) {
+		return ErrWitnessPubkeyType
+	}
+	return nil
+}
+
+func isCompressedOrUncompressedPubkey(pubkey []byte) bool {
+	switch {
+	case len(pubkey) == 65:
+		return pubkey[0] == 0x04
+	case len(pubkey) == 33:
+		return pubkey[0] == 0x02 || pubkey[0] == 0x03
+	}
+	return false
+}
+
+func isCompressedPubkey(pubkey []byte) bool {
+	return len(pubkey) == 33 && (pubkey[0] == 0x02 || pubkey[0] == 0x03)
+}
@@ -0,0 +1,100 @@
+package script
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+var signatureEncodingTests = []struct {
+	sig   string
+	flags int
+	err   error
+}{
+	// empty signature
+	{"", ScriptVerifyStrictenc | ScriptVerifyLowS, nil},
+	// low s
+	{"30450221008874d0b033de01686e160b7a7dbecedac01506eac7112db86558f50c9ef951de022051d4413c39c9edd46bf6a0473b1aac500fdf627608a5f14a4f5a3e42c070003a01", ScriptVerifyStrictenc | ScriptVerifyLowS, nil},
+	// high s
+	{"30460221008874d0b033de01686e160b7a7dbecedac01506eac7112db86558f50c9ef951de022100ae2bbec3c636122b94095fb8c4e553aeaacf7a70a6a2aef17078204a0fc6410701", ScriptVerifyDERSig, nil},
+	{"30460221008874d0b033de01686e160b7a7dbecedac01506eac7112db86558f50c9ef951de022100ae2bbec3c636122b94095fb8c4e553aeaacf7a70a6a2aef17078204a0fc6410701", ScriptVerifyLowS, ErrSigHighS},
+	// undefined sighash type
+	{"30450221008874d0b033de01686e160b7a7dbecedac01506eac7112db86558f50c9ef951de022051d4413c39c9edd46bf6a0473b1aac500fdf627608a5f14a4f5a3e42c070003a00", ScriptVerifyDERSig, nil},
+	{"30450221008874d0b033de01686e160b7a7dbecedac01506eac7112db86558f50c9ef951de022051d4413c39c9edd46bf6a0473b1aac500fdf627608a5f14a4f5a3e42c070003a00", ScriptVerifyStrictenc, ErrSigHashType},
+	{"30450221008874d0b033de01686e160b7a7dbecedac01506eac7112db86558f50c9ef951de022051d4413c39c9edd46bf6a0473b1aac500fdf627608a5f14a4f5a3e42c070003a83", ScriptVerifyStrictenc, nil},
+	// R with a padding zero
+	{"3046022200008874d0b033de01686e160b7a7dbecedac01506eac7112db86558f50c9ef951de022051d4413c39c9edd46bf6a0473b1aac500fdf627608a5f14a4f5a3e42c070003a01", ScriptVerifyNone, nil},
+	{"3046022200008874d0b033de01686e160b7a7dbecedac01506eac7112db86558f50c9ef951de022051d4413c39c9edd46bf6a0473b1aac500fdf627608a5f14a4f5a3e42c070003a01", ScriptVerifyDERSig, ErrSigDER},
+	// wrong length
+	{"30440221008874d0b033de01686e160b7a7dbecedac01506eac7112db86558f50c9ef951de022051d4413c39c9edd46bf6a0473b1aac500fdf627608a5f14a4f5a3e42c070003a01", ScriptVerifyDERSig, ErrSigDER},
+}
+
+func TestCheckSignatureEncoding(t *testing.T) {
+	for i, test := range signatureEncodingTests {
+		sig, _ := hex.DecodeString(test.sig)
+		if err := CheckSignatureEncoding(sig, test.flags); err != test.err {
+			t.Errorf("#%d CheckSignatureEncoding error %v, except %v", i, err, test.err)
+		}
+	}
+}
+
+func TestCheckPubkeyEncoding(t *testing.T) {
+	compressed, _ := hex.DecodeString("02d0de0aaeaefad02b8bdc8a01a1b8b11c696bd3d66a2c5f10780d95b7df42645c")
+	uncompressed := append([]byte{0x04}, make([]byte, 64)...)
+	hybrid := append([]byte{0x06}, make([]byte, 64)...)
+
+	tests := []struct {
+		pubkey     []byte
+		flags      int
+		sigVersion int
+		err        error
+	}{
+		{compressed, ScriptVerifyStrictenc | ScriptVerifyWitnessPubkeyType, SigVersionWitnessV0, nil},
+		{uncompressed, ScriptVerifyStrictenc | ScriptVerifyWitnessPubkeyType, SigVersionBase, nil},
+		{uncompressed, ScriptVerifyWitnessPubkeyType, SigVersionWitnessV0, ErrWitnessPubkeyType},
+		{hybrid, ScriptVerifyNone, SigVersionBase, nil},
+		{hybrid, ScriptVerifyStrictenc, SigVersionBase, ErrPubkeyType},
+		{nil, ScriptVerifyStrictenc, SigVersionBase, ErrPubkeyType},
+	}
+	for i, test := range tests {
+		if err := CheckPubkeyEncoding(test.pubkey, test.flags, test.sigVersion); err != test.err {
+			t.Errorf("#%d CheckPubkeyEncoding error %v, except %v", i, err, test.err)
+		}
+	}
+}
+
+// Test vectors of FindAndDelete from Bitcoin Core.
+func TestFindAndDelete(t *testing.T) {
+	tests := []struct {
+		s, d, except string
+		found        int
+	}{
+		{"0302ff030302ff03", "0302ff03", "", 2},
+		{"0302ff030302ff03", "02", "0302ff030302ff03", 0},
+		{"0302ff030302ff03", "ff", "0302ff030302ff03", 0},
+		// the push-three-bytes prefix is stripped, leaving a push-two-bytes.
+		{"0302ff030302ff03", "03", "02ff0302ff03", 2},
+		{"0003feed", "03feed", "00", 1},
+		{"0003feed", "00", "03feed", 1},
+		{"00030000000000", "0000", "0003000000", 1},
+		{"5151", "51", "", 2},
+	}
+	for i, test := range tests {
+		s, _ := hex.DecodeString(test.s)
+		d, _ := hex.DecodeString(test.d)
+		result, found := Script(s).FindAndDelete(d)
+		if hex.EncodeToString(result) != test.except || found != test.found {
+			t.Errorf("#%d FindAndDelete = %x, %d, except %s, %d", i, result, found, test.except, test.found)
+		}
+	}
+
+	// the signature pushes are removed from the scriptCode.
+	sig := bytes.Repeat([]byte{0xaa}, 71)
+	var s, d Script
+	s.PushData(sig)
+	s.AddOpCode(OP_CHECKSIG)
+	d.PushData(sig)
+	if result, _ := s.FindAndDelete(d); !bytes.Equal(result, Script{OP_CHECKSIG}) {
+		t.Errorf("FindAndDelete = %x, except %x", result, Script{OP_CHECKSIG})
+	}
+}
@@ -30,6 +30,10 @@ var (
 	ErrInvalidAltstackOperation = errors.New("Operation not valid with the current altstack size")
 	ErrUnbalancedConditional    = errors.New("Invalid OP_IF construction")
 
+	/* Script numbers */
+	ErrNumberOverflow   = errors.New("Script number overflow")
+	ErrNumberNotMinimal = errors.New("Non-minimally encoded script number")
+
 	/* CHECKLOCKTIMEVERIFY and CHECKSEQUENCEVERIFY */
 	ErrNagativeLocktime    = errors.New("Negative locktime")
 	ErrUnsatisfiedLocktime = errors.New("Locktime requirement not satisfied")
@@ -42,13 +46,16 @@ var (
 	ErrSigHighS     = errors.New("Non-canonical signature: S value is unnessarily high")
 	ErrSigNullDummy = errors.New("Dummy CHECKMULTISIG argument must be zero")
 	ErrPubkeyType   = errors.New("Public key is neither compressed or uncompressed")
-	// ErrCleanStack = error.New("")
-	ErrMinimalIf   = errors.New("OP_IF/NOTIF argument must be minimal")
-	ErrSigNullFail = errors.New("Signature must be zero for failed CHECK(MULTI)SIG operation")
+	ErrCleanStack   = errors.New("Extra items left on stack after execution")
+	ErrMinimalIf    = errors.New("OP_IF/NOTIF argument must be minimal")
+	ErrSigNullFail  = errors.New("Signature must be zero for failed CHECK(MULTI)SIG operation")
 
 	/* softfork safeness */
 	ErrDiscourageUpgradableNops           = errors.New("NOPx reserved for soft-fork upgrades")
 	ErrDiscourageUpgradableWitnessProgram = errors.New("Witness version reserved for soft-fork upgrades")
+	ErrDiscourageUpgradableTaprootVersion = errors.New("Taproot version reserved for soft-fork upgrades")
+	ErrDiscourageOpSuccess                = errors.New("OP_SUCCESSx reserved for soft-fork upgrades")
+	ErrDiscourageUpgradablePubkeyType     = errors.New("Public key version reserved for soft-fork upgrades")
 
 	/* segregated witness */
 	ErrWitnessProgramWrongLength  = errors.New("Witness program has incorrect length")
@@ -59,5 +66,14 @@ var (
 	ErrWitnessUnexpected          = errors.New("Witness provided for non-witness script")
 	ErrWitnessPubkeyType          = errors.New("Using non-compressed keys in segwit")
 
+	/* Taproot */
+	ErrSchnorrSigSize            = errors.New("Invalid Schnorr signature size")
+	ErrSchnorrSigHashType        = errors.New("Invalid Schnorr signature hash type")
+	ErrSchnorrSig                = errors.New("Invalid Schnorr signature")
+	ErrTaprootWrongControlSize   = errors.New("Invalid Taproot control block size")
+	ErrTapscriptValidationWeight = errors.New("Too much signature validation relative to witness weight")
+	ErrTapscriptCheckMultiSig    = errors.New("OP_CHECKMULTISIG(VERIFY) is not available in tapscript")
+	ErrTapscriptMinimalIf        = errors.New("OP_IF/NOTIF argument must be minimal in tapscript")
+
 	ErrCount = errors.New("unknown error")
 )
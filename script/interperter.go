@@ -1,9 +1,17 @@
 package script
 
-import "fmt"
+import (
+	"bytes"
+	"crypto/sha1"
+
+	"github.com/maiiz/coinlib/crypto"
+)
 
 // Signature hash types/flags.
 const (
+	// SigHashDefault signs as SigHashAll without the sighash type byte in the
+	// signature, it is only valid for taproot (BIP341).
+	SigHashDefault      = 0
 	SigHashAll          = 1
 	SigHashNone         = 2
 	SigHashSingle       = 3
@@ -88,15 +96,83 @@ const (
 	// Public keys in segregated witness scripts must be compressed
 	//
 	ScriptVerifyWitnessPubkeyType = (1 << 15)
+
+	// Taproot/Tapscript validation (BIP341 & BIP342)
+	//
+	ScriptVerifyTaproot = (1 << 16)
+
+	// Making unknown Taproot leaf versions non-standard
+	//
+	ScriptVerifyDiscourageUpgradableTaprootVersion = (1 << 17)
+
+	// Making unknown OP_SUCCESS non-standard
+	//
+	ScriptVerifyDiscourageOpSuccess = (1 << 18)
+
+	// Making unknown public key versions (in BIP342 scripts) non-standard
+	//
+	ScriptVerifyDiscourageUpgradablePubkeyType = (1 << 19)
+
+	// StandardScriptVerifyFlags are the flags the original client applies to
+	// the scripts of transactions relayed to its mempool.
+	StandardScriptVerifyFlags = ScriptVeryP2SH |
+		ScriptVerifyDERSig |
+		ScriptVerifyStrictenc |
+		ScriptVerifyMinimalData |
+		ScriptVerifyNullDummy |
+		ScriptVerifyDiscourageUpgradableNops |
+		ScriptVerifyCleanStack |
+		ScriptVerifyMinimalIf |
+		ScriptVerifyNullFail |
+		ScriptVerifyCheckLockTimeVerify |
+		ScriptVerifyCheckSequenceVerify |
+		ScriptVerifyLowS |
+		ScriptVerifyWitness |
+		ScriptVerifyDiscourageUpgradableWitnessProgram |
+		ScriptVerifyWitnessPubkeyType |
+		ScriptVerifyTaproot |
+		ScriptVerifyDiscourageUpgradableTaprootVersion |
+		ScriptVerifyDiscourageOpSuccess |
+		ScriptVerifyDiscourageUpgradablePubkeyType
 )
 
+// sequenceLocktimeDisableFlag disables the relative lock-time of an input
+// sequence (BIP68) and makes CHECKSEQUENCEVERIFY a NOP.
+const sequenceLocktimeDisableFlag = 1 << 31
+
 // SigVersion
 const (
 	SigVersionBase      = 0
 	SigVersionWitnessV0 = 1
+	// SigVersionTaproot is the key path spend of taproot (BIP341), no script
+	// is evaluated for it.
+	SigVersionTaproot = 2
+	// SigVersionTapscript is the script path spend of taproot with leaf
+	// version 0xc0 (BIP342).
+	SigVersionTapscript = 3
+)
+
+const (
+	// validationWeightPerSigOp is the validation weight paid by each
+	// signature checked in tapscript.
+	validationWeightPerSigOp = 50
+	// validationWeightOffset is the validation weight of a tapscript besides
+	// the size of its witness.
+	validationWeightOffset = 50
 )
 
-func checkExec(data []bool) bool {
+var (
+	vchFalse = []byte{}
+	vchTrue  = []byte{1}
+)
+
+// checkExec reports whether all the enclosing IF branches are executed.
+func checkExec(vfExec []bool) bool {
+	for _, v := range vfExec {
+		if !v {
+			return false
+		}
+	}
 	return true
 }
 
@@ -140,790 +216,1022 @@ func CheckMinimalPush(data []byte, opCode int) bool {
 	return true
 }
 
+// Interpreter evaluates a script one opcode at a time, it keeps the state of
+// the evaluation between the steps.
+type Interpreter struct {
+	stack      *Stack
+	altstack   Stack
+	script     Script
+	sigVersion int
+	flags      int
+	checker    SignatureChecker
+
+	// vfExec are the conditions of the enclosing IF branches.
+	vfExec  []bool
+	opCount int
+	// pc is the index of the next opcode, opPC is the index of the last one.
+	pc, opPC int
+	isExec   bool
+	// the scriptCode of signatures starts after the last OP_CODESEPARATOR
+	pbegincodehash int
+	// opPos is the position of the next opcode, counted in opcodes.
+	opPos    uint32
+	execData *ExecData
+	err      error
+}
+
+// NewInterpreter returns the interpreter of the script evaluated on the stack.
+// sigVersion - SigVersion* of the script
+// flags      - SCRIPT_VERIFY_* flags to apply
+// checker    - checks the signatures against the transaction input
+// A tapscript evaluated out of VerifyScript has no signature budget, it
+// fails at the first signature.
+func NewInterpreter(stack *Stack, script Script, sigVersion, flags int, checker SignatureChecker) (*Interpreter, error) {
+	return newInterpreter(stack, script, sigVersion, flags, checker, newExecData())
+}
+
+func newInterpreter(stack *Stack, script Script, sigVersion, flags int, checker SignatureChecker, execData *ExecData) (*Interpreter, error) {
+	// tapscript has no script size limit
+	if (sigVersion == SigVersionBase || sigVersion == SigVersionWitnessV0) && script.Size() > MaxScriptSize {
+		return nil, ErrScriptSize
+	}
+	return &Interpreter{
+		stack:      stack,
+		script:     script,
+		sigVersion: sigVersion,
+		flags:      flags,
+		checker:    checker,
+		execData:   execData,
+	}, nil
+}
+
+// Done reports whether the evaluation is finished, all opcodes are executed
+// or a step failed.
+func (vm *Interpreter) Done() bool {
+	return vm.err != nil || vm.pc >= len(vm.script)
+}
+
+// Err returns the error of the failed step.
+func (vm *Interpreter) Err() error {
+	return vm.err
+}
+
+// Stack returns the main stack of the evaluation.
+func (vm *Interpreter) Stack() *Stack {
+	return vm.stack
+}
+
+// AltStack returns the altstack of the evaluation.
+func (vm *Interpreter) AltStack() *Stack {
+	return &vm.altstack
+}
+
+// Step executes the next opcode, the error of a step ends the evaluation.
+// The last step also fails if an IF is not closed.
+func (vm *Interpreter) Step() error {
+	if vm.Done() {
+		return vm.err
+	}
+	vm.err = vm.step()
+	if vm.err == nil && vm.pc >= len(vm.script) && len(vm.vfExec) != 0 {
+		vm.err = ErrUnbalancedConditional
+	}
+	return vm.err
+}
+
 // EvalScript Evaluate a script
-// stack    - Initial stack
-// scriptIn - Script
-// txTo     - Transaction the script is a part of
-// inIdx    - txin index of the scriptSig
-// flags    - SCRIPT_VERIFY_* flags to apply
-func EvalScript(stack Stack, script Script, sigVersion, flags int) error {
-	if script.Size() > MaxScriptSize {
-		return ErrScriptSize
+// stack      - Initial stack
+// script     - Script
+// sigVersion - SigVersion* of the script
+// flags      - SCRIPT_VERIFY_* flags to apply
+// checker    - checks the signatures against the transaction input
+func EvalScript(stack *Stack, script Script, sigVersion, flags int, checker SignatureChecker) error {
+	return evalScript(stack, script, sigVersion, flags, checker, newExecData(), nil, "")
+}
+
+// evalScript evaluates the script as EvalScript with the exec data of a
+// tapscript, the steps are recorded as the script name in the trace if it is
+// not nil.
+func evalScript(stack *Stack, script Script, sigVersion, flags int, checker SignatureChecker, execData *ExecData, trace *Trace, name string) error {
+	vm, err := newInterpreter(stack, script, sigVersion, flags, checker, execData)
+	if err != nil {
+		return err
 	}
+	var st *ScriptTrace
+	if trace != nil {
+		st = &ScriptTrace{Name: name, Asm: script.Disasm()}
+		trace.Scripts = append(trace.Scripts, st)
+	}
+	for !vm.Done() {
+		err := vm.Step()
+		if st != nil {
+			st.Steps = append(st.Steps, vm.State())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+func (vm *Interpreter) step() error {
 	var (
-		vfExec  []bool
-		opCode  int
-		data    []byte
-		i       int
-		ok      bool
-		opCount int
+		stack      = vm.stack
+		script     = vm.script
+		sigVersion = vm.sigVersion
+		flags      = vm.flags
+		checker    = vm.checker
+
+		isRequireMinimal = (flags & ScriptVerifyMinimalData) != 0
 	)
-	isRequireMinimal := (flags & ScriptVerifyMinimalData) != 0
 
-	for i = 0; i < script.Size(); i++ {
-		if opCode, data, i, ok = script.GetOp(i); !ok {
-			return ErrBadOPCode
-		}
-		isExec := checkExec(vfExec)
-
-		// check Disabled opcodes.
-		if opCode == OP_CAT ||
-			opCode == OP_SUBSTR ||
-			opCode == OP_LEFT ||
-			opCode == OP_RIGHT ||
-			opCode == OP_INVERT ||
-			opCode == OP_AND ||
-			opCode == OP_OR ||
-			opCode == OP_XOR ||
-			opCode == OP_2MUL ||
-			opCode == OP_2DIV ||
-			opCode == OP_MUL ||
-			opCode == OP_DIV ||
-			opCode == OP_MOD ||
-			opCode == OP_LSHIFT ||
-			opCode == OP_RSHIFT {
-			return fmt.Errorf("opcode %s is disabled", GetOpName(opCode))
-		}
+	vm.opPC = vm.pc
+	opCode, data, next, ok := script.GetOp(vm.pc)
+	if !ok {
+		vm.pc = len(script)
+		return ErrBadOPCode
+	}
+	vm.pc = next
+	opPos := vm.opPos
+	vm.opPos++
+	isExec := checkExec(vm.vfExec)
+	vm.isExec = isExec
+
+	if len(data) > MaxScriptElementSize {
+		return ErrorPushSize
+	}
 
-		if opCode > OP_16 && (opCount+1 > MaxOpsPerScript) {
-			return fmt.Errorf("script opCode too large, got %d, maximum %d", opCount, MaxOpsPerScript)
+	// Note how OP_RESERVED does not count towards the opcode limit, and
+	// tapscript has no opcode limit.
+	if (sigVersion == SigVersionBase || sigVersion == SigVersionWitnessV0) && opCode > OP_16 {
+		if vm.opCount++; vm.opCount > MaxOpsPerScript {
+			return ErrOPCount
 		}
+	}
+
+	// check Disabled opcodes.
+	if opCode == OP_CAT ||
+		opCode == OP_SUBSTR ||
+		opCode == OP_LEFT ||
+		opCode == OP_RIGHT ||
+		opCode == OP_INVERT ||
+		opCode == OP_AND ||
+		opCode == OP_OR ||
+		opCode == OP_XOR ||
+		opCode == OP_2MUL ||
+		opCode == OP_2DIV ||
+		opCode == OP_MUL ||
+		opCode == OP_DIV ||
+		opCode == OP_MOD ||
+		opCode == OP_LSHIFT ||
+		opCode == OP_RSHIFT {
+		return ErrDisabledOPCode
+	}
 
-		if isExec && 0 <= opCode && opCode <= OP_PUSHDATA4 {
-			if isRequireMinimal && !CheckMinimalPush(data, opCode) {
-				return fmt.Errorf("script minimal data error")
-			}
-			stack.Push(data)
-		} else if isExec || (OP_IF <= opCode && opCode <= OP_ENDIF) {
-			switch opCode {
-			//
-			// Push value
-			//
-			case OP_1NEGATE:
-			case OP_1:
-			case OP_2:
-			case OP_3:
-			case OP_4:
-			case OP_5:
-			case OP_6:
-			case OP_7:
-			case OP_8:
-			case OP_9:
-			case OP_10:
-			case OP_11:
-			case OP_12:
-			case OP_13:
-			case OP_14:
-			case OP_15:
-			case OP_16:
-				{
-					// The result of these opcodes should always be the minimal way to push the data
-					// they push, so no need for a CheckMinimalPush here.
-					bn := BigNumber(int(opCode) - (int)(OP_1-1))
-					stack.Push(bn.Bytes())
+	if isExec && 0 <= opCode && opCode <= OP_PUSHDATA4 {
+		if isRequireMinimal && !CheckMinimalPush(data, opCode) {
+			return ErrMinimalData
+		}
+		stack.Push(data)
+	} else if isExec || (OP_IF <= opCode && opCode <= OP_ENDIF) {
+		switch opCode {
+		//
+		// Push value
+		//
+		case OP_1NEGATE, OP_1, OP_2, OP_3, OP_4, OP_5, OP_6, OP_7, OP_8, OP_9, OP_10, OP_11,
+			OP_12, OP_13, OP_14, OP_15, OP_16:
+			{
+				// The result of these opcodes should always be the minimal way to push the data
+				// they push, so no need for a CheckMinimalPush here.
+				bn := BigNumber(int(opCode) - (int)(OP_1-1))
+				stack.PushNumber(bn)
+				break
+			}
+		//
+		// Control
+		//
+		case OP_NOP:
+			break
+
+		case OP_CHECKLOCKTIMEVERIFY:
+			{
+				if (flags & ScriptVerifyCheckLockTimeVerify) == 0 {
+					// not enabled; treat as a NOP2
+					if (flags & ScriptVerifyDiscourageUpgradableNops) != 0 {
+						return ErrDiscourageUpgradableNops
+					}
 					break
 				}
-			//
-			// Control
-			//
-			case OP_NOP:
-				break
 
-			case OP_CHECKLOCKTIMEVERIFY:
-				{
-					if (flags & ScriptVerifyCheckLockTimeVerify) == 0 {
-						// not enabled; treat as a NOP2
-						if (flags & ScriptVerifyDiscourageUpgradableNops) != 0 {
-							return ErrDiscourageUpgradableNops
-						}
-						break
-					}
+				if stack.Size() < 1 {
+					return ErrInvalidStackOperation
+				}
+				// Note that elsewhere numeric opcodes are limited to
+				// operands in the range -2**31+1 to 2**31-1, however it is
+				// legal for opcodes to produce results exceeding that
+				// range. This limitation is implemented by CScriptNum's
+				// default 4-byte limit.
+				//
+				// If we kept to that limit we'd have a year 2038 problem,
+				// even though the nLockTime field in transactions
+				// themselves is uint32 which only becomes meaningless
+				// after the year 2106.
+				//
+				// Thus as a special case we tell CScriptNum to accept up
+				// to 5-byte bignums, which are good until 2**39-1, well
+				// beyond the 2**32-1 limit of the nLockTime field itself.
+				lockTime, err := stack.TopNumber(-1, isRequireMinimal, lockTimeMaxNumSize)
+				if err != nil {
+					return err
+				}
+				// In the rare event that the argument may be < 0 due to
+				// some arithmetic being done first, you can always use
+				// 0 MAX CHECKLOCKTIMEVERIFY.
+				if lockTime < 0 {
+					return ErrNagativeLocktime
+				}
 
-					if stack.Size() < 1 {
-						return ErrInvalidStackOperation
+				// Actually compare the specified lock time with the transaction.
+				if !checker.CheckLockTime(lockTime) {
+					return ErrUnsatisfiedLocktime
+				}
+				break
+			}
+		case OP_CHECKSEQUENCEVERIFY:
+			{
+				if (flags & ScriptVerifyCheckSequenceVerify) == 0 {
+					// not enabled; treat as a NOP3
+					if (flags & ScriptVerifyDiscourageUpgradableNops) != 0 {
+						return ErrDiscourageUpgradableNops
 					}
-					// Note that elsewhere numeric opcodes are limited to
-					// operands in the range -2**31+1 to 2**31-1, however it is
-					// legal for opcodes to produce results exceeding that
-					// range. This limitation is implemented by CScriptNum's
-					// default 4-byte limit.
-					//
-					// If we kept to that limit we'd have a year 2038 problem,
-					// even though the nLockTime field in transactions
-					// themselves is uint32 which only becomes meaningless
-					// after the year 2106.
-					//
-					// Thus as a special case we tell CScriptNum to accept up
-					// to 5-byte bignums, which are good until 2**39-1, well
-					// beyond the 2**32-1 limit of the nLockTime field itself.
-
-					// TODO: BigNumber Support
-					// lockTime := BigNumber(stack.Top(-1), isRequireMinimal, 5)
-					// // In the rare event that the argument may be < 0 due to
-					// // some arithmetic being done first, you can always use
-					// // 0 MAX CHECKLOCKTIMEVERIFY.
-					// if lockTime < 0 {
-					// 	return ErrNegativeLocktime
-					// }
-
-					// // Actually compare the specified lock time with the transaction.
-					// TODO: add checker
-					// if (!checker.CheckLockTime(nLockTime)){
-					// 	return set_error(serror, SCRIPT_ERR_UNSATISFIED_LOCKTIME);
-					// }
 					break
 				}
-			case OP_CHECKSEQUENCEVERIFY:
-				{
-					// if (flags & ScriptVerifyCheckSequenceVerify) == 0 {
-					// 	// not enabled; treat as a NOP3
-					// 	if (flags & ScriptVerifyDiscourageUpgradableNops) == 0 {
-					// 		return ErrDiscourageUpgradableNops
-					// 	}
-					// 	break
-					// }
-
-					// if stack.Size() < 1 {
-					// 	return ErrInvalidStackOperation
-					// }
-
-					// // nSequence, like nLockTime, is a 32-bit unsigned integer
-					// // field. See the comment in CHECKLOCKTIMEVERIFY regarding
-					// // 5-byte numeric operands.
-					// sequence := BigNumber(stack.Top(-1), isRequireMinimal, 5)
-					// // In the rare event that the argument may be < 0 due to
-					// // some arithmetic being done first, you can always use
-					// // 0 MAX CHECKSEQUENCEVERIFY.
-					// if sequence < 0 {
-					// 	return ErrNegativeLocktime
-					// }
-
-					// // To provide for future soft-fork extensibility, if the
-					// // operand has the disabled lock-time flag set,
-					// // CHECKSEQUENCEVERIFY behaves as a NOP.
-					// //
-					// if (sequence & types.SequenceLocktimeDisableFlag) != 0 {
-					// 	break
-					// }
-					// Compare the specified sequence number with the input.
-					// TODO: add checker
-					// if !checker.CheckSequence(sequence) {
-					// 	return ErrUnsatisfiedLocktime
-					// }
+
+				if stack.Size() < 1 {
+					return ErrInvalidStackOperation
+				}
+
+				// nSequence, like nLockTime, is a 32-bit unsigned integer
+				// field. See the comment in CHECKLOCKTIMEVERIFY regarding
+				// 5-byte numeric operands.
+				sequence, err := stack.TopNumber(-1, isRequireMinimal, lockTimeMaxNumSize)
+				if err != nil {
+					return err
+				}
+				// In the rare event that the argument may be < 0 due to
+				// some arithmetic being done first, you can always use
+				// 0 MAX CHECKSEQUENCEVERIFY.
+				if sequence < 0 {
+					return ErrNagativeLocktime
+				}
+
+				// To provide for future soft-fork extensibility, if the
+				// operand has the disabled lock-time flag set,
+				// CHECKSEQUENCEVERIFY behaves as a NOP.
+				if (sequence & sequenceLocktimeDisableFlag) != 0 {
 					break
 				}
-			case OP_NOP1:
-			case OP_NOP4:
-			case OP_NOP5:
-			case OP_NOP6:
-			case OP_NOP7:
-			case OP_NOP8:
-			case OP_NOP9:
-			case OP_NOP10:
-				{
-					if (flags & ScriptVerifyDiscourageUpgradableNops) != 0 {
-						return ErrDiscourageUpgradableNops
-					}
+				// Compare the specified sequence number with the input.
+				if !checker.CheckSequence(sequence) {
+					return ErrUnsatisfiedLocktime
 				}
 				break
+			}
+		case OP_NOP1, OP_NOP4, OP_NOP5, OP_NOP6, OP_NOP7, OP_NOP8, OP_NOP9, OP_NOP10:
+			{
+				if (flags & ScriptVerifyDiscourageUpgradableNops) != 0 {
+					return ErrDiscourageUpgradableNops
+				}
+			}
+			break
 
-			case OP_IF:
-			case OP_NOTIF:
-				{
-					// // <expression> if [statements] [else [statements]] endif
-					isValue := false
-					if isExec {
-						if stack.Size() < 1 {
-							return ErrUnbalancedConditional
-						}
-						v := stack.Top(-1)
-						if (sigVersion == SigVersionWitnessV0) && (flags&ScriptVerifyMinimalIf) != 0 {
-							if len(v) > 1 {
-								return ErrMinimalIf
-							}
-							if len(v) == 1 && v[0] != 1 {
-								return ErrMinimalIf
-							}
+		case OP_IF, OP_NOTIF:
+			{
+				// <expression> if [statements] [else [statements]] endif
+				isValue := false
+				if isExec {
+					if stack.Size() < 1 {
+						return ErrUnbalancedConditional
+					}
+					v := stack.top(-1)
+					// The argument must be exactly empty or 0x01 in tapscript.
+					if sigVersion == SigVersionTapscript && (len(v) > 1 || (len(v) == 1 && v[0] != 1)) {
+						return ErrTapscriptMinimalIf
+					}
+					// It is only a policy rule for witness v0.
+					if (sigVersion == SigVersionWitnessV0) && (flags&ScriptVerifyMinimalIf) != 0 {
+						if len(v) > 1 {
+							return ErrMinimalIf
 						}
-						isValue = CastToBool(v)
-						if opCode == OP_NOTIF {
-							isValue = !isValue
+						if len(v) == 1 && v[0] != 1 {
+							return ErrMinimalIf
 						}
-						stack.Pop()
 					}
-					vfExec = append(vfExec, isValue)
-					break
-				}
-			case OP_ELSE:
-				{
-					if len(vfExec) == 0 {
-						return ErrUnbalancedConditional
+					isValue = CastToBool(v)
+					if opCode == OP_NOTIF {
+						isValue = !isValue
 					}
+					stack.pop()
+				}
+				vm.vfExec = append(vm.vfExec, isValue)
+				break
+			}
+		case OP_ELSE:
+			{
+				if len(vm.vfExec) == 0 {
+					return ErrUnbalancedConditional
+				}
 
-					vfExec[len(vfExec)-1] = !vfExec[len(vfExec)-1]
-					break
+				vm.vfExec[len(vm.vfExec)-1] = !vm.vfExec[len(vm.vfExec)-1]
+				break
+			}
+		case OP_ENDIF:
+			{
+				if len(vm.vfExec) == 0 {
+					return ErrUnbalancedConditional
 				}
-			case OP_ENDIF:
-				{
-					if len(vfExec) == 0 {
-						return ErrUnbalancedConditional
-					}
-					vfExec = vfExec[:len(vfExec)-1]
-					break
+				vm.vfExec = vm.vfExec[:len(vm.vfExec)-1]
+				break
+			}
+		case OP_VERIFY:
+			{
+				// (true -- ) or
+				// (false -- false) and return
+				if stack.Size() < 1 {
+					return ErrInvalidStackOperation
 				}
-			case OP_VERIFY:
-				{
-					// (true -- ) or
-					// (false -- false) and return
-					if stack.Size() < 1 {
-						return ErrInvalidStackOperation
-					}
-					isValue := CastToBool(stack.Top(-1))
-					if isValue {
-						stack.Pop()
-					} else {
-						return ErrVerify
-					}
-					break
+				isValue := CastToBool(stack.top(-1))
+				if isValue {
+					stack.pop()
+				} else {
+					return ErrVerify
 				}
+				break
+			}
 
-			case OP_RETURN:
-				{
-					return ErrOPReturn
+		case OP_RETURN:
+			{
+				return ErrOPReturn
+			}
+
+		//
+		// Stack ops
+		//
+		case OP_TOALTSTACK:
+			{
+				if stack.Size() < 1 {
+					return ErrInvalidStackOperation
 				}
+				vm.altstack.Push(stack.pop())
+				break
+			}
 
-			//
-			// Stack ops
-			//
-			case OP_TOALTSTACK:
-				{
-					// if (stack.size() < 1)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-					// altstack.push_back(stacktop(-1));
-					// popstack(stack);
+		case OP_FROMALTSTACK:
+			{
+				if vm.altstack.Size() < 1 {
+					return ErrInvalidAltstackOperation
+				}
+				stack.Push(vm.altstack.pop())
+				break
+			}
 
-					break
+		case OP_2DROP:
+			{
+				// (x1 x2 -- )
+				if stack.Size() < 2 {
+					return ErrInvalidStackOperation
 				}
+				stack.pop()
+				stack.pop()
+				break
+			}
 
-			case OP_FROMALTSTACK:
-				{
-					// if (altstack.size() < 1)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_ALTSTACK_OPERATION);
-					// stack.push_back(altstacktop(-1));
-					// popstack(altstack);
-					break
+		case OP_2DUP:
+			{
+				// (x1 x2 -- x1 x2 x1 x2)
+				if stack.Size() < 2 {
+					return ErrInvalidStackOperation
 				}
+				vch1 := stack.top(-2)
+				vch2 := stack.top(-1)
+				stack.Push(vch1)
+				stack.Push(vch2)
+				break
+			}
 
-			case OP_2DROP:
-				{
-					// (x1 x2 -- )
-					// if (stack.size() < 2)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-					// popstack(stack);
-					// popstack(stack);
-					break
+		case OP_3DUP:
+			{
+				// (x1 x2 x3 -- x1 x2 x3 x1 x2 x3)
+				if stack.Size() < 3 {
+					return ErrInvalidStackOperation
 				}
+				vch1 := stack.top(-3)
+				vch2 := stack.top(-2)
+				vch3 := stack.top(-1)
+				stack.Push(vch1)
+				stack.Push(vch2)
+				stack.Push(vch3)
+				break
+			}
 
-			case OP_2DUP:
-				{
-					// (x1 x2 -- x1 x2 x1 x2)
-					// if (stack.size() < 2)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-					// valtype vch1 = stacktop(-2);
-					// valtype vch2 = stacktop(-1);
-					// stack.push_back(vch1);
-					// stack.push_back(vch2);
-					break
+		case OP_2OVER:
+			{
+				// (x1 x2 x3 x4 -- x1 x2 x3 x4 x1 x2)
+				if stack.Size() < 4 {
+					return ErrInvalidStackOperation
 				}
+				vch1 := stack.top(-4)
+				vch2 := stack.top(-3)
+				stack.Push(vch1)
+				stack.Push(vch2)
+				break
+			}
 
-			case OP_3DUP:
-				{
-					// (x1 x2 x3 -- x1 x2 x3 x1 x2 x3)
-					// if (stack.size() < 3)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-					// valtype vch1 = stacktop(-3);
-					// valtype vch2 = stacktop(-2);
-					// valtype vch3 = stacktop(-1);
-					// stack.push_back(vch1);
-					// stack.push_back(vch2);
-					// stack.push_back(vch3);
-					//
-					break
+		case OP_2ROT:
+			{
+				// (x1 x2 x3 x4 x5 x6 -- x3 x4 x5 x6 x1 x2)
+				if stack.Size() < 6 {
+					return ErrInvalidStackOperation
 				}
+				vch1 := stack.remove(-6)
+				vch2 := stack.remove(-5)
+				stack.Push(vch1)
+				stack.Push(vch2)
+				break
+			}
 
-			case OP_2OVER:
-				{
-					// (x1 x2 x3 x4 -- x1 x2 x3 x4 x1 x2)
-					// if (stack.size() < 4)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-					// valtype vch1 = stacktop(-4);
-					// valtype vch2 = stacktop(-3);
-					// stack.push_back(vch1);
-					// stack.push_back(vch2);
-					break
+		case OP_2SWAP:
+			{
+				// (x1 x2 x3 x4 -- x3 x4 x1 x2)
+				if stack.Size() < 4 {
+					return ErrInvalidStackOperation
 				}
+				stack.swap(-4, -2)
+				stack.swap(-3, -1)
+				break
+			}
 
-			case OP_2ROT:
-				{
-					// (x1 x2 x3 x4 x5 x6 -- x3 x4 x5 x6 x1 x2)
-					// if (stack.size() < 6)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-					// valtype vch1 = stacktop(-6);
-					// valtype vch2 = stacktop(-5);
-					// stack.erase(stack.end()-6, stack.end()-4);
-					// stack.push_back(vch1);
-					// stack.push_back(vch2);
-					break
+		case OP_IFDUP:
+			{
+				// (x - 0 | x x)
+				if stack.Size() < 1 {
+					return ErrInvalidStackOperation
+				}
+				vch := stack.top(-1)
+				if CastToBool(vch) {
+					stack.Push(vch)
 				}
+				break
+			}
 
-			case OP_2SWAP:
-				{
-					// (x1 x2 x3 x4 -- x3 x4 x1 x2)
-					// if (stack.size() < 4)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-					// swap(stacktop(-4), stacktop(-2));
-					// swap(stacktop(-3), stacktop(-1));
-					break
+		case OP_DEPTH:
+			{
+				// -- stacksize
+				bn := BigNumber(stack.Size())
+				stack.PushNumber(bn)
+				break
+			}
+
+		case OP_DROP:
+			{
+				// (x -- )
+				if stack.Size() < 1 {
+					return ErrInvalidStackOperation
 				}
+				stack.pop()
+				break
+			}
 
-			case OP_IFDUP:
-				{
-					// (x - 0 | x x)
-					// if (stack.size() < 1)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-					// valtype vch = stacktop(-1);
-					// if (CastToBool(vch))
-					// 	stack.push_back(vch);
-					break
+		case OP_DUP:
+			{
+				// (x -- x x)
+				if stack.Size() < 1 {
+					return ErrInvalidStackOperation
 				}
+				stack.Push(stack.top(-1))
+				break
+			}
 
-			case OP_DEPTH:
-				{
-					// -- stacksize
-					// CScriptNum bn(stack.size());
-					// stack.push_back(bn.getvch())
-					break
+		case OP_NIP:
+			{
+				// (x1 x2 -- x2)
+				if stack.Size() < 2 {
+					return ErrInvalidStackOperation
 				}
+				stack.remove(-2)
+				break
+			}
 
-			case OP_DROP:
-				{
-					// (x -- )
-					// if (stack.size() < 1)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-					// popstack(stack);
-					break
+		case OP_OVER:
+			{
+				// (x1 x2 -- x1 x2 x1)
+				if stack.Size() < 2 {
+					return ErrInvalidStackOperation
 				}
+				stack.Push(stack.top(-2))
+				break
+			}
 
-			case OP_DUP:
-				{
-					// (x -- x x)
-					// if (stack.size() < 1)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-					// valtype vch = stacktop(-1);
-					// stack.push_back(vch);
-					break
+		case OP_PICK, OP_ROLL:
+			{
+				// (xn ... x2 x1 x0 n - xn ... x2 x1 x0 xn)
+				// (xn ... x2 x1 x0 n - ... x2 x1 x0 xn)
+				if stack.Size() < 2 {
+					return ErrInvalidStackOperation
 				}
+				bn, err := stack.TopNumber(-1, isRequireMinimal, defaultMaxNumSize)
+				if err != nil {
+					return err
+				}
+				n := int(bn)
+				stack.pop()
+				if n < 0 || n >= stack.Size() {
+					return ErrInvalidStackOperation
+				}
+				vch := stack.top(-n - 1)
+				if opCode == OP_ROLL {
+					stack.remove(-n - 1)
+				}
+				stack.Push(vch)
+				break
+			}
 
-			case OP_NIP:
-				{
-					// (x1 x2 -- x2)
-					// if (stack.size() < 2)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-					// stack.erase(stack.end() - 2);
-					break
+		case OP_ROT:
+			{
+				// (x1 x2 x3 -- x2 x3 x1)
+				//  x2 x1 x3  after first swap
+				//  x2 x3 x1  after second swap
+				if stack.Size() < 3 {
+					return ErrInvalidStackOperation
 				}
+				stack.swap(-3, -2)
+				stack.swap(-2, -1)
+				break
+			}
 
-			case OP_OVER:
-				{
-					// (x1 x2 -- x1 x2 x1)
-					// if (stack.size() < 2)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-					// valtype vch = stacktop(-2);
-					// stack.push_back(vch);
-					break
+		case OP_SWAP:
+			{
+				// (x1 x2 -- x2 x1)
+				if stack.Size() < 2 {
+					return ErrInvalidStackOperation
 				}
+				stack.swap(-2, -1)
+				break
+			}
 
-			case OP_PICK:
-			case OP_ROLL:
-				{
-					// (xn ... x2 x1 x0 n - xn ... x2 x1 x0 xn)
-					// (xn ... x2 x1 x0 n - ... x2 x1 x0 xn)
-					// if (stack.size() < 2)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-					// int n = CScriptNum(stacktop(-1), fRequireMinimal).getint();
-					// popstack(stack);
-					// if (n < 0 || n >= (int)stack.size())
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-					// valtype vch = stacktop(-n-1);
-					// if (opcode == OP_ROLL)
-					//     stack.erase(stack.end()-n-1);
-					// stack.push_back(vch);
-					break
+		case OP_TUCK:
+			{
+				// (x1 x2 -- x2 x1 x2)
+				if stack.Size() < 2 {
+					return ErrInvalidStackOperation
 				}
+				stack.insert(-2, stack.top(-1))
+				break
+			}
 
-			case OP_ROT:
-				{
-					// (x1 x2 x3 -- x2 x3 x1)
-					//  x2 x1 x3  after first swap
-					//  x2 x3 x1  after second swap
-					// if (stack.size() < 3)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-					// swap(stacktop(-3), stacktop(-2));
-					// swap(stacktop(-2), stacktop(-1));
-					break
+		case OP_SIZE:
+			{
+				// (in -- in size)
+				if stack.Size() < 1 {
+					return ErrInvalidStackOperation
 				}
+				bn := BigNumber(len(stack.top(-1)))
+				stack.PushNumber(bn)
+				break
+			}
 
-			case OP_SWAP:
-				{
-					// (x1 x2 -- x2 x1)
-					// if (stack.size() < 2)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-					// swap(stacktop(-2), stacktop(-1));
-					break
+		//
+		// Bitwise logic
+		//
+		case OP_EQUAL, OP_EQUALVERIFY:
+			//case OP_NOTEQUAL: // use OP_NUMNOTEQUAL
+			{
+				// (x1 x2 - bool)
+				if stack.Size() < 2 {
+					return ErrInvalidStackOperation
+				}
+				isEqual := bytes.Equal(stack.top(-2), stack.top(-1))
+				// OP_NOTEQUAL is disabled because it would be too easy to say
+				// something like n != 1 and have some wiseguy pass in 1 with extra
+				// zero bytes after it (numerically, 0x01 == 0x0001 == 0x000001)
+				stack.pop()
+				stack.pop()
+				stack.PushBool(isEqual)
+				if opCode == OP_EQUALVERIFY {
+					if !isEqual {
+						return ErrEqualVerify
+					}
+					stack.pop()
 				}
+				break
+			}
 
-			case OP_TUCK:
-				{
-					// (x1 x2 -- x2 x1 x2)
-					// if (stack.size() < 2)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-					// valtype vch = stacktop(-1);
-					// stack.insert(stack.end()-2, vch);
-					break
+		//
+		// Numeric
+		//
+		case OP_1ADD, OP_1SUB, OP_NEGATE, OP_ABS, OP_NOT, OP_0NOTEQUAL:
+			{
+				// (in -- out)
+				bn, err := stack.TopNumber(-1, isRequireMinimal, defaultMaxNumSize)
+				if err != nil {
+					return err
 				}
+				switch opCode {
+				case OP_1ADD:
+					bn++
+				case OP_1SUB:
+					bn--
+				case OP_NEGATE:
+					bn = -bn
+				case OP_ABS:
+					if bn < 0 {
+						bn = -bn
+					}
+				case OP_NOT:
+					bn = boolToBigNumber(bn == 0)
+				case OP_0NOTEQUAL:
+					bn = boolToBigNumber(bn != 0)
+				}
+				stack.pop()
+				stack.PushNumber(bn)
+				break
+			}
 
-			case OP_SIZE:
-				{
-					// (in -- in size)
-					// if (stack.size() < 1)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-					// CScriptNum bn(stacktop(-1).size());
-					// stack.push_back(bn.getvch());
-					break
+		case OP_ADD, OP_SUB, OP_BOOLAND, OP_BOOLOR, OP_NUMEQUAL, OP_NUMEQUALVERIFY,
+			OP_NUMNOTEQUAL, OP_LESSTHAN, OP_GREATERTHAN, OP_LESSTHANOREQUAL,
+			OP_GREATERTHANOREQUAL, OP_MIN, OP_MAX:
+			{
+				// (x1 x2 -- out)
+				bn1, err := stack.TopNumber(-2, isRequireMinimal, defaultMaxNumSize)
+				if err != nil {
+					return err
+				}
+				bn2, err := stack.TopNumber(-1, isRequireMinimal, defaultMaxNumSize)
+				if err != nil {
+					return err
+				}
+				var bn BigNumber
+				switch opCode {
+				case OP_ADD:
+					bn = bn1 + bn2
+				case OP_SUB:
+					bn = bn1 - bn2
+				case OP_BOOLAND:
+					bn = boolToBigNumber(bn1 != 0 && bn2 != 0)
+				case OP_BOOLOR:
+					bn = boolToBigNumber(bn1 != 0 || bn2 != 0)
+				case OP_NUMEQUAL, OP_NUMEQUALVERIFY:
+					bn = boolToBigNumber(bn1 == bn2)
+				case OP_NUMNOTEQUAL:
+					bn = boolToBigNumber(bn1 != bn2)
+				case OP_LESSTHAN:
+					bn = boolToBigNumber(bn1 < bn2)
+				case OP_GREATERTHAN:
+					bn = boolToBigNumber(bn1 > bn2)
+				case OP_LESSTHANOREQUAL:
+					bn = boolToBigNumber(bn1 <= bn2)
+				case OP_GREATERTHANOREQUAL:
+					bn = boolToBigNumber(bn1 >= bn2)
+				case OP_MIN:
+					bn = bn1
+					if bn2 < bn1 {
+						bn = bn2
+					}
+				case OP_MAX:
+					bn = bn1
+					if bn2 > bn1 {
+						bn = bn2
+					}
 				}
+				stack.pop()
+				stack.pop()
+				stack.PushNumber(bn)
 
-			//
-			// Bitwise logic
-			//
-			case OP_EQUAL:
-			case OP_EQUALVERIFY:
-				//case OP_NOTEQUAL: // use OP_NUMNOTEQUAL
-				{
-					// (x1 x2 - bool)
-					// if (stack.size() < 2)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-					// valtype& vch1 = stacktop(-2);
-					// valtype& vch2 = stacktop(-1);
-					// bool fEqual = (vch1 == vch2);
-					// // OP_NOTEQUAL is disabled because it would be too easy to say
-					// // something like n != 1 and have some wiseguy pass in 1 with extra
-					// // zero bytes after it (numerically, 0x01 == 0x0001 == 0x000001)
-					// //if (opcode == OP_NOTEQUAL)
-					// //    fEqual = !fEqual;
-					// popstack(stack);
-					// popstack(stack);
-					// stack.push_back(fEqual ? vchTrue : vchFalse);
-					// if (opcode == OP_EQUALVERIFY)
-					// {
-					//     if (fEqual)
-					//         popstack(stack);
-					//     else
-					//         return set_error(serror, SCRIPT_ERR_EQUALVERIFY);
-					// }
-					break
+				if opCode == OP_NUMEQUALVERIFY {
+					if !CastToBool(stack.top(-1)) {
+						return ErrNumEqualVerify
+					}
+					stack.pop()
 				}
+				break
+			}
 
-			//
-			// Numeric
-			//
-			case OP_1ADD:
-			case OP_1SUB:
-			case OP_NEGATE:
-			case OP_ABS:
-			case OP_NOT:
-			case OP_0NOTEQUAL:
-				{
-					// // (in -- out)
-					// if (stack.size() < 1)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-					// CScriptNum bn(stacktop(-1), fRequireMinimal);
-					// switch (opcode)
-					// {
-					// case OP_1ADD:       bn += bnOne; break;
-					// case OP_1SUB:       bn -= bnOne; break;
-					// case OP_NEGATE:     bn = -bn; break;
-					// case OP_ABS:        if (bn < bnZero) bn = -bn; break;
-					// case OP_NOT:        bn = (bn == bnZero); break;
-					// case OP_0NOTEQUAL:  bn = (bn != bnZero); break;
-					// default:            assert(!"invalid opcode"); break;
-					// }
-					// popstack(stack);
-					// stack.push_back(bn.getvch());
-					break
+		case OP_WITHIN:
+			{
+				// (x min max -- out)
+				bn1, err := stack.TopNumber(-3, isRequireMinimal, defaultMaxNumSize)
+				if err != nil {
+					return err
+				}
+				bn2, err := stack.TopNumber(-2, isRequireMinimal, defaultMaxNumSize)
+				if err != nil {
+					return err
+				}
+				bn3, err := stack.TopNumber(-1, isRequireMinimal, defaultMaxNumSize)
+				if err != nil {
+					return err
 				}
+				stack.pop()
+				stack.pop()
+				stack.pop()
+				stack.PushBool(bn2 <= bn1 && bn1 < bn3)
+				break
+			}
 
-			case OP_ADD:
-			case OP_SUB:
-			case OP_BOOLAND:
-			case OP_BOOLOR:
-			case OP_NUMEQUAL:
-			case OP_NUMEQUALVERIFY:
-			case OP_NUMNOTEQUAL:
-			case OP_LESSTHAN:
-			case OP_GREATERTHAN:
-			case OP_LESSTHANOREQUAL:
-			case OP_GREATERTHANOREQUAL:
-			case OP_MIN:
-			case OP_MAX:
-				{
-					// (x1 x2 -- out)
-					// if (stack.size() < 2)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-					// CScriptNum bn1(stacktop(-2), fRequireMinimal);
-					// CScriptNum bn2(stacktop(-1), fRequireMinimal);
-					// CScriptNum bn(0);
-					// switch (opcode)
-					// {
-					// case OP_ADD:
-					//     bn = bn1 + bn2;
-					//     break;
-
-					// case OP_SUB:
-					//     bn = bn1 - bn2;
-					//     break;
-
-					// case OP_BOOLAND:             bn = (bn1 != bnZero && bn2 != bnZero); break;
-					// case OP_BOOLOR:              bn = (bn1 != bnZero || bn2 != bnZero); break;
-					// case OP_NUMEQUAL:            bn = (bn1 == bn2); break;
-					// case OP_NUMEQUALVERIFY:      bn = (bn1 == bn2); break;
-					// case OP_NUMNOTEQUAL:         bn = (bn1 != bn2); break;
-					// case OP_LESSTHAN:            bn = (bn1 < bn2); break;
-					// case OP_GREATERTHAN:         bn = (bn1 > bn2); break;
-					// case OP_LESSTHANOREQUAL:     bn = (bn1 <= bn2); break;
-					// case OP_GREATERTHANOREQUAL:  bn = (bn1 >= bn2); break;
-					// case OP_MIN:                 bn = (bn1 < bn2 ? bn1 : bn2); break;
-					// case OP_MAX:                 bn = (bn1 > bn2 ? bn1 : bn2); break;
-					// default:                     assert(!"invalid opcode"); break;
-					// }
-					// popstack(stack);
-					// popstack(stack);
-					// stack.push_back(bn.getvch());
-
-					// if (opcode == OP_NUMEQUALVERIFY)
-					// {
-					//     if (CastToBool(stacktop(-1)))
-					//         popstack(stack);
-					//     else
-					//         return set_error(serror, SCRIPT_ERR_NUMEQUALVERIFY);
-					// }
-					break
+		//
+		// Crypto
+		//
+		case OP_RIPEMD160, OP_SHA1, OP_SHA256, OP_HASH160, OP_HASH256:
+			{
+				// (in -- hash)
+				if stack.Size() < 1 {
+					return ErrInvalidStackOperation
 				}
+				vch := stack.pop()
+				var vchHash []byte
+				switch opCode {
+				case OP_RIPEMD160:
+					vchHash = crypto.Ripemd160(vch)
+				case OP_SHA1:
+					h := sha1.Sum(vch)
+					vchHash = h[:]
+				case OP_SHA256:
+					vchHash = crypto.Sha256(vch).Bytes()
+				case OP_HASH160:
+					vchHash = crypto.Hash160(vch)
+				case OP_HASH256:
+					vchHash = crypto.DoubleSha256(vch).Bytes()
+				}
+				stack.Push(vchHash)
+				break
+			}
 
-			case OP_WITHIN:
-				{
-					// (x min max -- out)
-					// if (stack.size() < 3)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-					// CScriptNum bn1(stacktop(-3), fRequireMinimal);
-					// CScriptNum bn2(stacktop(-2), fRequireMinimal);
-					// CScriptNum bn3(stacktop(-1), fRequireMinimal);
-					// bool fValue = (bn2 <= bn1 && bn1 < bn3);
-					// popstack(stack);
-					// popstack(stack);
-					// popstack(stack);
-					// stack.push_back(fValue ? vchTrue : vchFalse);
-					break
+		case OP_CODESEPARATOR:
+			{
+				// Hash starts after the code separator
+				vm.pbegincodehash = vm.pc
+				vm.execData.CodeSeparatorPos = opPos
+				break
+			}
+
+		case OP_CHECKSIG, OP_CHECKSIGVERIFY:
+			{
+				// (sig pubkey -- bool)
+				if stack.Size() < 2 {
+					return ErrInvalidStackOperation
 				}
 
-			//
-			// Crypto
-			//
-			case OP_RIPEMD160:
-			case OP_SHA1:
-			case OP_SHA256:
-			case OP_HASH160:
-			case OP_HASH256:
-				{
-					// (in -- hash)
-					// if (stack.size() < 1)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-					// valtype& vch = stacktop(-1);
-					// valtype vchHash((opcode == OP_RIPEMD160 || opcode == OP_SHA1 || opcode == OP_HASH160) ? 20 : 32);
-					// if (opcode == OP_RIPEMD160)
-					//     CRIPEMD160().Write(vch.data(), vch.size()).Finalize(vchHash.data());
-					// else if (opcode == OP_SHA1)
-					//     CSHA1().Write(vch.data(), vch.size()).Finalize(vchHash.data());
-					// else if (opcode == OP_SHA256)
-					//     CSHA256().Write(vch.data(), vch.size()).Finalize(vchHash.data());
-					// else if (opcode == OP_HASH160)
-					//     CHash160().Write(vch.data(), vch.size()).Finalize(vchHash.data());
-					// else if (opcode == OP_HASH256)
-					//     CHash256().Write(vch.data(), vch.size()).Finalize(vchHash.data());
-					// popstack(stack);
-					// stack.push_back(vchHash);
-					break
+				vchSig := stack.top(-2)
+				vchPubkey := stack.top(-1)
+
+				isSuccess, err := vm.evalChecksig(vchSig, vchPubkey)
+				if err != nil {
+					return err
 				}
 
-			case OP_CODESEPARATOR:
-				{
-					// Hash starts after the code separator
-					// pbegincodehash = pc;
-					break
+				stack.pop()
+				stack.pop()
+				stack.PushBool(isSuccess)
+				if opCode == OP_CHECKSIGVERIFY {
+					if !isSuccess {
+						return ErrCheckSigVerify
+					}
+					stack.pop()
 				}
+				break
+			}
 
-			case OP_CHECKSIG:
-			case OP_CHECKSIGVERIFY:
-				{
-					// (sig pubkey -- bool)
-					// if (stack.size() < 2)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-
-					// valtype& vchSig    = stacktop(-2);
-					// valtype& vchPubKey = stacktop(-1);
-
-					// // Subset of script starting at the most recent codeseparator
-					// CScript scriptCode(pbegincodehash, pend);
-
-					// // Drop the signature in pre-segwit scripts but not segwit scripts
-					// if (sigversion == SIGVERSION_BASE) {
-					//     scriptCode.FindAndDelete(CScript(vchSig));
-					// }
-
-					// if (!CheckSignatureEncoding(vchSig, flags, serror) || !CheckPubKeyEncoding(vchPubKey, flags, sigversion, serror)) {
-					//     //serror is set
-					//     return false;
-					// }
-					// bool fSuccess = checker.CheckSig(vchSig, vchPubKey, scriptCode, sigversion);
-
-					// if (!fSuccess && (flags & SCRIPT_VERIFY_NULLFAIL) && vchSig.size())
-					//     return set_error(serror, SCRIPT_ERR_SIG_NULLFAIL);
-
-					// popstack(stack);
-					// popstack(stack);
-					// stack.push_back(fSuccess ? vchTrue : vchFalse);
-					// if (opcode == OP_CHECKSIGVERIFY)
-					// {
-					//     if (fSuccess)
-					//         popstack(stack);
-					//     else
-					//         return set_error(serror, SCRIPT_ERR_CHECKSIGVERIFY);
-					// }
-					break
+		case OP_CHECKMULTISIG, OP_CHECKMULTISIGVERIFY:
+			{
+				// ([sig ...] num_of_signatures [pubkey ...] num_of_pubkeys -- bool)
+				if sigVersion == SigVersionTapscript {
+					return ErrTapscriptCheckMultiSig
 				}
 
-			case OP_CHECKMULTISIG:
-			case OP_CHECKMULTISIGVERIFY:
-				{
-					// ([sig ...] num_of_signatures [pubkey ...] num_of_pubkeys -- bool)
-
-					// int i = 1;
-					// if ((int)stack.size() < i)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-
-					// int nKeysCount = CScriptNum(stacktop(-i), fRequireMinimal).getint();
-					// if (nKeysCount < 0 || nKeysCount > MAX_PUBKEYS_PER_MULTISIG)
-					//     return set_error(serror, SCRIPT_ERR_PUBKEY_COUNT);
-					// nOpCount += nKeysCount;
-					// if (nOpCount > MAX_OPS_PER_SCRIPT)
-					//     return set_error(serror, SCRIPT_ERR_OP_COUNT);
-					// int ikey = ++i;
-					// // ikey2 is the position of last non-signature item in the stack. Top stack item = 1.
-					// // With SCRIPT_VERIFY_NULLFAIL, this is used for cleanup if operation fails.
-					// int ikey2 = nKeysCount + 2;
-					// i += nKeysCount;
-					// if ((int)stack.size() < i)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-
-					// int nSigsCount = CScriptNum(stacktop(-i), fRequireMinimal).getint();
-					// if (nSigsCount < 0 || nSigsCount > nKeysCount)
-					//     return set_error(serror, SCRIPT_ERR_SIG_COUNT);
-					// int isig = ++i;
-					// i += nSigsCount;
-					// if ((int)stack.size() < i)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-
-					// // Subset of script starting at the most recent codeseparator
-					// CScript scriptCode(pbegincodehash, pend);
-
-					// // Drop the signature in pre-segwit scripts but not segwit scripts
-					// for (int k = 0; k < nSigsCount; k++)
-					// {
-					//     valtype& vchSig = stacktop(-isig-k);
-					//     if (sigversion == SIGVERSION_BASE) {
-					//         scriptCode.FindAndDelete(CScript(vchSig));
-					//     }
-					// }
-
-					// bool fSuccess = true;
-					// while (fSuccess && nSigsCount > 0)
-					// {
-					//     valtype& vchSig    = stacktop(-isig);
-					//     valtype& vchPubKey = stacktop(-ikey);
-
-					//     // Note how this makes the exact order of pubkey/signature evaluation
-					//     // distinguishable by CHECKMULTISIG NOT if the STRICTENC flag is set.
-					//     // See the script_(in)valid tests for details.
-					//     if (!CheckSignatureEncoding(vchSig, flags, serror) || !CheckPubKeyEncoding(vchPubKey, flags, sigversion, serror)) {
-					//         // serror is set
-					//         return false;
-					//     }
-
-					//     // Check signature
-					//     bool fOk = checker.CheckSig(vchSig, vchPubKey, scriptCode, sigversion);
-
-					//     if (fOk) {
-					//         isig++;
-					//         nSigsCount--;
-					//     }
-					//     ikey++;
-					//     nKeysCount--;
-
-					//     // If there are more signatures left than keys left,
-					//     // then too many signatures have failed. Exit early,
-					//     // without checking any further signatures.
-					//     if (nSigsCount > nKeysCount)
-					//         fSuccess = false;
-					// }
-
-					// // Clean up stack of actual arguments
-					// while (i-- > 1) {
-					//     // If the operation failed, we require that all signatures must be empty vector
-					//     if (!fSuccess && (flags & SCRIPT_VERIFY_NULLFAIL) && !ikey2 && stacktop(-1).size())
-					//         return set_error(serror, SCRIPT_ERR_SIG_NULLFAIL);
-					//     if (ikey2 > 0)
-					//         ikey2--;
-					//     popstack(stack);
-					// }
-
-					// // A bug causes CHECKMULTISIG to consume one extra argument
-					// // whose contents were not checked in any way.
-					// //
-					// // Unfortunately this is a potential source of mutability,
-					// // so optionally verify it is exactly equal to zero prior
-					// // to removing it from the stack.
-					// if (stack.size() < 1)
-					//     return set_error(serror, SCRIPT_ERR_INVALID_STACK_OPERATION);
-					// if ((flags & SCRIPT_VERIFY_NULLDUMMY) && stacktop(-1).size())
-					//     return set_error(serror, SCRIPT_ERR_SIG_NULLDUMMY);
-					// popstack(stack);
-
-					// stack.push_back(fSuccess ? vchTrue : vchFalse);
-
-					// if (opcode == OP_CHECKMULTISIGVERIFY)
-					// {
-					//     if (fSuccess)
-					//         popstack(stack);
-					//     else
-					//         return set_error(serror, SCRIPT_ERR_CHECKMULTISIGVERIFY);
-					// }
-					break
+				pos := 1
+				if stack.Size() < pos {
+					return ErrInvalidStackOperation
+				}
+
+				keysCount, err := stack.TopNumber(-pos, isRequireMinimal, defaultMaxNumSize)
+				if err != nil {
+					return err
+				}
+				if keysCount < 0 || keysCount > MaxPubkeysPerMultisig {
+					return ErrPubkeyCount
+				}
+				vm.opCount += int(keysCount)
+				if vm.opCount > MaxOpsPerScript {
+					return ErrOPCount
+				}
+				ikey := pos + 1
+				// ikey2 is the position of last non-signature item in the stack. Top stack item = 1.
+				// With SCRIPT_VERIFY_NULLFAIL, this is used for cleanup if operation fails.
+				ikey2 := int(keysCount) + 2
+				pos += int(keysCount) + 1
+				if stack.Size() < pos {
+					return ErrInvalidStackOperation
+				}
+
+				sigsCount, err := stack.TopNumber(-pos, isRequireMinimal, defaultMaxNumSize)
+				if err != nil {
+					return err
+				}
+				if sigsCount < 0 || sigsCount > keysCount {
+					return ErrSigCount
+				}
+				isig := pos + 1
+				pos += int(sigsCount) + 1
+				if stack.Size() < pos {
+					return ErrInvalidStackOperation
+				}
+
+				// Subset of script starting at the most recent codeseparator
+				scriptCode := script[vm.pbegincodehash:]
+
+				// Drop the signature in pre-segwit scripts but not segwit scripts
+				for k := 0; k < int(sigsCount); k++ {
+					if sigVersion == SigVersionBase {
+						var sigScript Script
+						sigScript.PushData(stack.top(-isig - k))
+						scriptCode, _ = scriptCode.FindAndDelete(sigScript)
+					}
+				}
+
+				isSuccess := true
+				for isSuccess && sigsCount > 0 {
+					vchSig := stack.top(-isig)
+					vchPubkey := stack.top(-ikey)
+
+					// Note how this makes the exact order of pubkey/signature evaluation
+					// distinguishable by CHECKMULTISIG NOT if the STRICTENC flag is set.
+					if err := CheckSignatureEncoding(vchSig, flags); err != nil {
+						return err
+					}
+					if err := CheckPubkeyEncoding(vchPubkey, flags, sigVersion); err != nil {
+						return err
+					}
+
+					// Check signature
+					if checker.CheckSig(vchSig, vchPubkey, scriptCode, sigVersion) {
+						isig++
+						sigsCount--
+					}
+					ikey++
+					keysCount--
+
+					// If there are more signatures left than keys left,
+					// then too many signatures have failed. Exit early,
+					// without checking any further signatures.
+					if sigsCount > keysCount {
+						isSuccess = false
+					}
+				}
+
+				// Clean up stack of actual arguments
+				for ; pos > 1; pos-- {
+					// If the operation failed, we require that all signatures must be empty vector
+					if !isSuccess && (flags&ScriptVerifyNullFail) != 0 && ikey2 == 0 && len(stack.top(-1)) > 0 {
+						return ErrSigNullFail
+					}
+					if ikey2 > 0 {
+						ikey2--
+					}
+					stack.pop()
+				}
+
+				// A bug causes CHECKMULTISIG to consume one extra argument
+				// whose contents were not checked in any way.
+				//
+				// Unfortunately this is a potential source of mutability,
+				// so optionally verify it is exactly equal to zero prior
+				// to removing it from the stack.
+				if stack.Size() < 1 {
+					return ErrInvalidStackOperation
+				}
+				if (flags&ScriptVerifyNullDummy) != 0 && len(stack.top(-1)) > 0 {
+					return ErrSigNullDummy
+				}
+				stack.pop()
+
+				stack.PushBool(isSuccess)
+				if opCode == OP_CHECKMULTISIGVERIFY {
+					if !isSuccess {
+						return ErrCheckMultiSigVerify
+					}
+					stack.pop()
+				}
+				break
+			}
+
+		case OP_CHECKSIGADD:
+			{
+				// (sig num pubkey -- num)
+				if sigVersion == SigVersionBase || sigVersion == SigVersionWitnessV0 {
+					return ErrBadOPCode
+				}
+				if stack.Size() < 3 {
+					return ErrInvalidStackOperation
 				}
 
-			default:
-				return fmt.Errorf("")
+				vchSig := stack.top(-3)
+				num, err := stack.TopNumber(-2, isRequireMinimal, defaultMaxNumSize)
+				if err != nil {
+					return err
+				}
+				vchPubkey := stack.top(-1)
+
+				isSuccess, err := vm.evalChecksig(vchSig, vchPubkey)
+				if err != nil {
+					return err
+				}
+				if isSuccess {
+					num++
+				}
+
+				stack.pop()
+				stack.pop()
+				stack.pop()
+				stack.PushNumber(num)
+				break
 			}
+
+		default:
+			return ErrBadOPCode
 		}
 	}
-	return nil
+
+	// Size limits
+	return checkStackSize(stack, &vm.altstack)
+}
+
+// boolToBigNumber returns 1 for true and 0 for false.
+func boolToBigNumber(v bool) BigNumber {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// evalChecksig checks the signature of OP_CHECKSIG, OP_CHECKSIGVERIFY and
+// OP_CHECKSIGADD, the failed signature is an error of the evaluation unless
+// the rules of the sigVersion let it push false.
+func (vm *Interpreter) evalChecksig(sig, pubkey []byte) (bool, error) {
+	if vm.sigVersion == SigVersionTapscript {
+		return vm.evalChecksigTapscript(sig, pubkey)
+	}
+
+	// Subset of script starting at the most recent codeseparator
+	scriptCode := vm.script[vm.pbegincodehash:]
+
+	// Drop the signature in pre-segwit scripts but not segwit scripts
+	if vm.sigVersion == SigVersionBase {
+		var sigScript Script
+		sigScript.PushData(sig)
+		scriptCode, _ = scriptCode.FindAndDelete(sigScript)
+	}
+
+	if err := CheckSignatureEncoding(sig, vm.flags); err != nil {
+		return false, err
+	}
+	if err := CheckPubkeyEncoding(pubkey, vm.flags, vm.sigVersion); err != nil {
+		return false, err
+	}
+	isSuccess := vm.checker.CheckSig(sig, pubkey, scriptCode, vm.sigVersion)
+
+	if !isSuccess && (vm.flags&ScriptVerifyNullFail) != 0 && len(sig) > 0 {
+		return false, ErrSigNullFail
+	}
+	return isSuccess, nil
+}
+
+// evalChecksigTapscript checks the signature by the BIP342 rules: an empty
+// signature is false, any other signature must be valid and is paid by the
+// validation weight of the tapscript.
+func (vm *Interpreter) evalChecksigTapscript(sig, pubkey []byte) (bool, error) {
+	isSuccess := len(sig) > 0
+	if isSuccess {
+		vm.execData.validationWeightLeft -= validationWeightPerSigOp
+		if vm.execData.validationWeightLeft < 0 {
+			return false, ErrTapscriptValidationWeight
+		}
+	}
+
+	switch len(pubkey) {
+	case 0:
+		return false, ErrPubkeyType
+	case 32:
+		if isSuccess {
+			if err := vm.checker.CheckSchnorrSig(sig, pubkey, SigVersionTapscript, vm.execData); err != nil {
+				return false, err
+			}
+		}
+	default:
+		// the public keys of unknown types are left for future softforks,
+		// their signatures are valid.
+		if (vm.flags & ScriptVerifyDiscourageUpgradablePubkeyType) != 0 {
+			return false, ErrDiscourageUpgradablePubkeyType
+		}
+	}
+	return isSuccess, nil
 }
@@ -138,6 +138,9 @@ const (
 	OP_NOP9                = 0xb8
 	OP_NOP10               = 0xb9
 
+	// Opcode added by BIP342 (tapscript)
+	OP_CHECKSIGADD = 0xba
+
 	// template matching params
 	OP_SMALLINTEGER = 0xfa
 	OP_PUBKEYS      = 0xfb
@@ -387,6 +390,8 @@ func GetOpName(opCode int) string {
 		return "OP_NOP9"
 	case OP_NOP10:
 		return "OP_NOP10"
+	case OP_CHECKSIGADD:
+		return "OP_CHECKSIGADD"
 
 	case OP_INVALIDOPCODE:
 		return "OP_INVALIDOPCODE"
@@ -407,7 +412,7 @@ func DecodeOPN(opCode int) int {
 		return 0
 	}
 
-	if !(opCode == OP_0) || (OP_1 <= opCode && opCode <= OP_16) {
+	if !(OP_1 <= opCode && opCode <= OP_16) {
 		panic(fmt.Errorf("op %d is not an OP_N", opCode))
 	}
 
@@ -425,3 +430,15 @@ func EncodeOPN(n int) int {
 	}
 	return OP_1 + n - 1
 }
+
+// IsOpSuccess reports whether the opcode is an OP_SUCCESSx of tapscript, which
+// makes the script succeed unconditionally (BIP342).
+func IsOpSuccess(opCode int) bool {
+	return opCode == 80 || opCode == 98 ||
+		(opCode >= 126 && opCode <= 129) ||
+		(opCode >= 131 && opCode <= 134) ||
+		(opCode >= 137 && opCode <= 138) ||
+		(opCode >= 141 && opCode <= 142) ||
+		(opCode >= 149 && opCode <= 153) ||
+		(opCode >= 187 && opCode <= 254)
+}
@@ -0,0 +1,299 @@
+package script_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/maiiz/coinlib/crypto"
+	"github.com/maiiz/coinlib/script"
+	"github.com/maiiz/coinlib/types"
+)
+
+// The test vectors of Bitcoin Core in testdata, they need the transactions of
+// types so the tests are out of package script.
+
+// scriptErrors maps the error names of the vectors to the errors.
+var scriptErrors = map[string]error{
+	"OK":                                    nil,
+	"UNKNOWN_ERROR":                         script.ErrUnknown,
+	"EVAL_FALSE":                            script.ErrEvalFalse,
+	"OP_RETURN":                             script.ErrOPReturn,
+	"SCRIPT_SIZE":                           script.ErrScriptSize,
+	"PUSH_SIZE":                             script.ErrorPushSize,
+	"OP_COUNT":                              script.ErrOPCount,
+	"STACK_SIZE":                            script.ErrStackSize,
+	"SIG_COUNT":                             script.ErrSigCount,
+	"PUBKEY_COUNT":                          script.ErrPubkeyCount,
+	"VERIFY":                                script.ErrVerify,
+	"EQUALVERIFY":                           script.ErrEqualVerify,
+	"CHECKMULTISIGVERIFY":                   script.ErrCheckMultiSigVerify,
+	"CHECKSIGVERIFY":                        script.ErrCheckSigVerify,
+	"NUMEQUALVERIFY":                        script.ErrNumEqualVerify,
+	"BAD_OPCODE":                            script.ErrBadOPCode,
+	"DISABLED_OPCODE":                       script.ErrDisabledOPCode,
+	"INVALID_STACK_OPERATION":               script.ErrInvalidStackOperation,
+	"INVALID_ALTSTACK_OPERATION":            script.ErrInvalidAltstackOperation,
+	"UNBALANCED_CONDITIONAL":                script.ErrUnbalancedConditional,
+	"NEGATIVE_LOCKTIME":                     script.ErrNagativeLocktime,
+	"UNSATISFIED_LOCKTIME":                  script.ErrUnsatisfiedLocktime,
+	"SIG_HASHTYPE":                          script.ErrSigHashType,
+	"SIG_DER":                               script.ErrSigDER,
+	"MINIMALDATA":                           script.ErrMinimalData,
+	"SIG_PUSHONLY":                          script.ErrSigPushOnly,
+	"SIG_HIGH_S":                            script.ErrSigHighS,
+	"SIG_NULLDUMMY":                         script.ErrSigNullDummy,
+	"PUBKEYTYPE":                            script.ErrPubkeyType,
+	"CLEANSTACK":                            script.ErrCleanStack,
+	"MINIMALIF":                             script.ErrMinimalIf,
+	"NULLFAIL":                              script.ErrSigNullFail,
+	"DISCOURAGE_UPGRADABLE_NOPS":            script.ErrDiscourageUpgradableNops,
+	"DISCOURAGE_UPGRADABLE_WITNESS_PROGRAM": script.ErrDiscourageUpgradableWitnessProgram,
+	"WITNESS_PROGRAM_WRONG_LENGTH":          script.ErrWitnessProgramWrongLength,
+	"WITNESS_PROGRAM_WITNESS_EMPTY":         script.ErrWitnessProgramWitnessEmpty,
+	"WITNESS_PROGRAM_MISMATCH":              script.ErrWitnessProgramMimatch,
+	"WITNESS_MALLEATED":                     script.ErrWitnessMalleated,
+	"WITNESS_MALLEATED_P2SH":                script.ErrWitnessMalleatedP2SH,
+	"WITNESS_UNEXPECTED":                    script.ErrWitnessUnexpected,
+	"WITNESS_PUBKEYTYPE":                    script.ErrWitnessPubkeyType,
+}
+
+// scriptErrorName returns the vector name of the error, the errors without a
+// name, like a script number overflow, are UNKNOWN_ERROR.
+func scriptErrorName(err error) string {
+	for name, e := range scriptErrors {
+		if e == err {
+			return name
+		}
+	}
+	return "UNKNOWN_ERROR"
+}
+
+var scriptFlags = map[string]int{
+	"":                                      script.ScriptVerifyNone,
+	"NONE":                                  script.ScriptVerifyNone,
+	"P2SH":                                  script.ScriptVeryP2SH,
+	"STRICTENC":                             script.ScriptVerifyStrictenc,
+	"DERSIG":                                script.ScriptVerifyDERSig,
+	"LOW_S":                                 script.ScriptVerifyLowS,
+	"NULLDUMMY":                             script.ScriptVerifyNullDummy,
+	"SIGPUSHONLY":                           script.ScriptVerivySigPushOnly,
+	"MINIMALDATA":                           script.ScriptVerifyMinimalData,
+	"DISCOURAGE_UPGRADABLE_NOPS":            script.ScriptVerifyDiscourageUpgradableNops,
+	"CLEANSTACK":                            script.ScriptVerifyCleanStack,
+	"CHECKLOCKTIMEVERIFY":                   script.ScriptVerifyCheckLockTimeVerify,
+	"CHECKSEQUENCEVERIFY":                   script.ScriptVerifyCheckSequenceVerify,
+	"WITNESS":                               script.ScriptVerifyWitness,
+	"DISCOURAGE_UPGRADABLE_WITNESS_PROGRAM": script.ScriptVerifyDiscourageUpgradableWitnessProgram,
+	"MINIMALIF":                             script.ScriptVerifyMinimalIf,
+	"NULLFAIL":                              script.ScriptVerifyNullFail,
+	"WITNESS_PUBKEYTYPE":                    script.ScriptVerifyWitnessPubkeyType,
+}
+
+func parseFlags(s string) (int, error) {
+	flags := 0
+	for _, name := range strings.Split(s, ",") {
+		flag, ok := scriptFlags[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown flag %s", name)
+		}
+		flags |= flag
+	}
+	return flags, nil
+}
+
+// creditingTx returns the transaction of the output spent by the vectors.
+func creditingTx(pkScript script.Script, amount int64) *types.Transaction {
+	tx := &types.Transaction{Version: 1}
+	tx.AddTxIn(types.NewTxIn(crypto.Hash{}, math.MaxUint32, script.Script{script.OP_0, script.OP_0}))
+	tx.Vin[0].Sequence = types.SequenceFinal
+	tx.AddTxOut(types.NewTxOut(pkScript, amount))
+	return tx
+}
+
+// spendingTx returns the transaction spending the crediting transaction.
+func spendingTx(scriptSig script.Script, witness types.TxWitness, credit *types.Transaction) *types.Transaction {
+	tx := &types.Transaction{Version: 1}
+	tx.AddTxIn(types.NewTxIn(credit.TxHash(), 0, scriptSig))
+	tx.Vin[0].Sequence = types.SequenceFinal
+	tx.Vin[0].Witness = witness
+	tx.AddTxOut(types.NewTxOut(nil, credit.Vout[0].Value))
+	return tx
+}
+
+func readVectors(t *testing.T, name string) [][]interface{} {
+	data, err := ioutil.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("read %s error %v", name, err)
+	}
+	var tests [][]interface{}
+	if err := json.Unmarshal(data, &tests); err != nil {
+		t.Fatalf("parse %s error %v", name, err)
+	}
+	return tests
+}
+
+// TestScripts runs the script_tests.json vectors: [[wit..., amount]?,
+// scriptSig, scriptPubKey, flags, expected_scripterror, ... comments].
+func TestScripts(t *testing.T) {
+	for i, test := range readVectors(t, "script_tests.json") {
+		var (
+			witness types.TxWitness
+			amount  int64
+		)
+		if w, ok := test[0].([]interface{}); ok {
+			for _, item := range w[:len(w)-1] {
+				b, err := hex.DecodeString(item.(string))
+				if err != nil {
+					t.Fatalf("#%d witness error %v", i, err)
+				}
+				witness = append(witness, b)
+			}
+			amount = int64(math.Round(w[len(w)-1].(float64) * 1e8))
+			test = test[1:]
+		}
+		if len(test) < 4 {
+			// comment
+			continue
+		}
+
+		scriptSig, err := script.ParseShortForm(test[0].(string))
+		if err != nil {
+			t.Fatalf("#%d scriptSig error %v", i, err)
+		}
+		pkScript, err := script.ParseShortForm(test[1].(string))
+		if err != nil {
+			t.Fatalf("#%d scriptPubKey error %v", i, err)
+		}
+		flags, err := parseFlags(test[2].(string))
+		if err != nil {
+			t.Fatalf("#%d %v", i, err)
+		}
+
+		tx := spendingTx(scriptSig, witness, creditingTx(pkScript, amount))
+		err = types.VerifyInput(tx, 0, pkScript, amount, flags)
+		if name := scriptErrorName(err); name != test[3].(string) {
+			t.Errorf("#%d %v: VerifyScript error %s (%v), except %s", i, test, name, err, test[3])
+		}
+	}
+}
+
+// checkTransaction checks the transaction without its context like
+// CheckTransaction of Bitcoin Core.
+func checkTransaction(tx *types.Transaction) error {
+	const maxMoney = 21000000 * 1e8
+	if len(tx.Vin) == 0 || len(tx.Vout) == 0 {
+		return fmt.Errorf("empty vin or vout")
+	}
+	var total int64
+	for _, to := range tx.Vout {
+		if to.Value < 0 || to.Value > maxMoney {
+			return fmt.Errorf("bad output value")
+		}
+		if total += to.Value; total > maxMoney {
+			return fmt.Errorf("bad total output value")
+		}
+	}
+	seen := make(map[types.OutPoint]bool)
+	for _, ti := range tx.Vin {
+		if seen[*ti.Prevout] {
+			return fmt.Errorf("duplicate inputs")
+		}
+		seen[*ti.Prevout] = true
+	}
+
+	isNull := func(op *types.OutPoint) bool {
+		return op.Hash == crypto.Hash{} && op.Index == math.MaxUint32
+	}
+	if len(tx.Vin) == 1 && isNull(tx.Vin[0].Prevout) {
+		if n := len(tx.Vin[0].ScriptSig); n < 2 || n > 100 {
+			return fmt.Errorf("bad coinbase script size")
+		}
+		return nil
+	}
+	for _, ti := range tx.Vin {
+		if isNull(ti.Prevout) {
+			return fmt.Errorf("null prevout")
+		}
+	}
+	return nil
+}
+
+// verifyTx parses a vector of tx_valid.json or tx_invalid.json and verifies
+// all inputs of the transaction:
+// [[[prevout hash, prevout index, prevout scriptPubKey, amount?], ...],
+// serializedTransaction, verifyFlags].
+func verifyTx(test []interface{}) error {
+	type prevOut struct {
+		pkScript script.Script
+		amount   int64
+	}
+	prevOuts := make(map[types.OutPoint]prevOut)
+	for _, v := range test[0].([]interface{}) {
+		in := v.([]interface{})
+		h := crypto.HexToHash(in[0].(string))
+		h.Reverse()
+		op := types.OutPoint{Hash: h, Index: uint32(int32(in[1].(float64)))}
+		pkScript, err := script.ParseShortForm(in[2].(string))
+		if err != nil {
+			panic(err)
+		}
+		var amount int64
+		if len(in) > 3 {
+			amount = int64(in[3].(float64))
+		}
+		prevOuts[op] = prevOut{pkScript, amount}
+	}
+
+	raw, _ := hex.DecodeString(test[1].(string))
+	flags, err := parseFlags(test[2].(string))
+	if err != nil {
+		panic(err)
+	}
+	tx := new(types.Transaction)
+	if err := tx.Unmarshal(bytes.NewReader(raw)); err != nil {
+		return err
+	}
+	if err := checkTransaction(tx); err != nil {
+		return err
+	}
+	for i, ti := range tx.Vin {
+		prev, ok := prevOuts[*ti.Prevout]
+		if !ok {
+			return fmt.Errorf("input %d without prevout", i)
+		}
+		if err := types.VerifyInput(tx, i, prev.pkScript, prev.amount, flags); err != nil {
+			return fmt.Errorf("input %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func TestTxValid(t *testing.T) {
+	for i, test := range readVectors(t, "tx_valid.json") {
+		if _, ok := test[0].([]interface{}); !ok {
+			// comment
+			continue
+		}
+		if err := verifyTx(test); err != nil {
+			t.Errorf("#%d %s: error %v", i, test[1], err)
+		}
+	}
+}
+
+func TestTxInvalid(t *testing.T) {
+	for i, test := range readVectors(t, "tx_invalid.json") {
+		if _, ok := test[0].([]interface{}); !ok {
+			// comment
+			continue
+		}
+		if err := verifyTx(test); err == nil {
+			t.Errorf("#%d %s: error nil, except invalid", i, test[1])
+		}
+	}
+}
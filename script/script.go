@@ -5,6 +5,9 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+
+	"github.com/maiiz/coinlib/crypto"
+	"github.com/maiiz/coinlib/encoding/varint"
 )
 
 const (
@@ -28,7 +31,7 @@ const (
 	LocktimeThreshold = 500000000 // Tue Nov  5 00:53:20 1985 UTC
 
 	// MaxOpCode defines Maximum value that an opcode can be.
-	MaxOpCode = OP_NOP10
+	MaxOpCode = OP_CHECKSIGADD
 )
 
 // Script represents the Serialized script, used inside transaction inputs and outputs.
@@ -46,31 +49,33 @@ func (s Script) Bytes() []byte {
 	return s[:]
 }
 
-func (s Script) Marshal(w io.Writer) []byte {
-	return nil
+// Marshal writes the varint length prefixed script to writer.
+func (s Script) Marshal(w io.Writer) {
+	varint.WriteVarInt(w, uint64(len(s)))
+	w.Write(s)
 }
 
 // AddBytes appends bytes to scripts.
-func (s Script) AddBytes(data []byte) {
-	s = append(s, data...)
+func (s *Script) AddBytes(data []byte) {
+	*s = append(*s, data...)
 }
 
 // AddOpCode adds byte to script.
-func (s Script) AddOpCode(opCode int) {
+func (s *Script) AddOpCode(opCode int) {
 	if opCode < 0 || opCode > 0xff {
 		panic(fmt.Errorf("Script AddOpCode error: invalid opcode %d", opCode))
 	}
-	s = append(s, byte(opCode))
+	*s = append(*s, byte(opCode))
 }
 
 // AddInt64 adds int64 to script.
-func (s Script) AddInt64(n int64) {
+func (s *Script) AddInt64(n int64) {
 	if n == -1 || (n >= 1 && n <= 16) {
-		s = append(s, byte(n+(OP_1-1)))
+		*s = append(*s, byte(n+(OP_1-1)))
 	} else if n == 0 {
-		s = append(s, byte(OP_0))
+		*s = append(*s, byte(OP_0))
 	} else {
-		s.AddBytes(BigNumber(n).Bytes())
+		s.PushData(BigNumber(n).Bytes())
 	}
 }
 
@@ -93,7 +98,8 @@ func (s *Script) PushData(data []byte) {
 	s.AddBytes(bb.Bytes())
 }
 
-// GetOp returns the sigOps in the script.
+// GetOp returns the op at index i of the script, with its push data and the
+// index of the next op.
 func (s Script) GetOp(i int) (opCodeRet int, data []byte, idx int, ok bool) {
 	var (
 		dataSize   int
@@ -137,8 +143,15 @@ func (s Script) GetOp(i int) (opCodeRet int, data []byte, idx int, ok bool) {
 		}
 	}
 
+	if !ok {
+		return opCode, nil, i, false
+	}
+	if scriptSize-i < dataSize {
+		return opCode, nil, scriptSize, false
+	}
 	if dataSize > 0 {
-		data = s[i:dataSize]
+		data = s[i : i+dataSize]
+		i += dataSize
 	}
 
 	return opCode, data, i, ok
@@ -153,7 +166,7 @@ func (s Script) GetSigOpCount(fAccurate bool) int {
 		ok         bool
 	)
 
-	for i := 0; i < s.Size(); i++ {
+	for i := 0; i < s.Size(); {
 		if opCode, _, i, ok = s.GetOp(i); !ok {
 			break
 		}
@@ -181,6 +194,23 @@ func (s Script) IsP2SH() bool {
 		s[22] == OP_EQUAL)
 }
 
+// IsP2PKH returns if the script is a p2pkh scriptPubKey.
+func (s Script) IsP2PKH() bool {
+	return (len(s) == 25 &&
+		s[0] == OP_DUP &&
+		s[1] == OP_HASH160 &&
+		s[2] == 0x14 &&
+		s[23] == OP_EQUALVERIFY &&
+		s[24] == OP_CHECKSIG)
+}
+
+// IsP2WPKH returns if the script is a version 0 witness program of a pubkey hash.
+func (s Script) IsP2WPKH() bool {
+	return (len(s) == 22 &&
+		s[0] == OP_0 &&
+		s[1] == 0x14)
+}
+
 // IsP2WSH returns if the script is a scriptpubkey signaling segregated witness.
 func (s Script) IsP2WSH() bool {
 	// Extra-fast test for pay-to-witness-script-hash CScripts:
@@ -189,23 +219,27 @@ func (s Script) IsP2WSH() bool {
 		s[1] == 0x20)
 }
 
-// IsWitnessProgram aa
+// IsWitnessProgram returns if the script is a witness program.
+func (s Script) IsWitnessProgram() bool {
+	_, _, ok := s.WitnessProgram()
+	return ok
+}
+
+// WitnessProgram returns the version and the program of the witness program.
 // A witness program is any valid CScript that consists of a 1-byte push opcode
 // followed by a data push between 2 and 40 bytes.
-func (s Script) IsWitnessProgram(version int, program []byte) bool {
+func (s Script) WitnessProgram() (version int, program []byte, ok bool) {
 	scriptSize := len(s)
 	if scriptSize < 4 || scriptSize > 42 {
-		return false
+		return 0, nil, false
 	}
 	if s[0] != OP_0 && (s[0] < OP_1 || s[0] > OP_16) {
-		return false
+		return 0, nil, false
 	}
-	if int(s[1]+2) == scriptSize {
-		version = DecodeOPN((int)(s[0]))
-		program = s[2:scriptSize]
-		return true
+	if int(s[1])+2 == scriptSize {
+		return DecodeOPN(int(s[0])), s[2:scriptSize], true
 	}
-	return false
+	return 0, nil, false
 }
 
 // IsPushOnly returns if the script only contains pushdata ops.
@@ -215,8 +249,8 @@ func (s Script) IsPushOnly() bool {
 		opCode int
 		ok     bool
 	)
-	for i := 1; i < len(s); i++ {
-		if opCode, _, i, ok = s.GetOp(i); ok {
+	for i := 0; i < len(s); {
+		if opCode, _, i, ok = s.GetOp(i); !ok {
 			return false
 		}
 		// Note that IsPushOnly() *does* consider OP_RESERVED to be a
@@ -238,14 +272,63 @@ func (s Script) HasValidOps() bool {
 		i      int
 		ok     bool
 	)
-	for i = 0; i < len(s); i++ {
-		if opCode, data, i, ok = s.GetOp(i); ok || opCode > MaxOpCode || len(data) > MaxScriptElementSize {
+	for i = 0; i < len(s); {
+		if opCode, data, i, ok = s.GetOp(i); !ok || opCode > MaxOpCode || len(data) > MaxScriptElementSize {
 			return false
 		}
 	}
 	return true
 }
 
+// RemoveCodeSeparators returns the script with OP_CODESEPARATORs removed, the
+// bytes after a malformed op are kept as is.
+func (s Script) RemoveCodeSeparators() Script {
+	var (
+		result = make(Script, 0, len(s))
+		begin  int
+	)
+	for i := 0; i < len(s); {
+		opCode, _, next, ok := s.GetOp(i)
+		if !ok {
+			break
+		}
+		if opCode == OP_CODESEPARATOR {
+			result = append(result, s[begin:i]...)
+			begin = next
+		}
+		i = next
+	}
+	return append(result, s[begin:]...)
+}
+
+// FindAndDelete returns the script with the ops matching b removed and the
+// number of the matches, b is matched at op boundaries only.
+func (s Script) FindAndDelete(b Script) (Script, int) {
+	if len(b) == 0 {
+		return s, 0
+	}
+
+	var (
+		result = make(Script, 0, len(s))
+		found  int
+		begin  int
+		ok     = true
+	)
+	for i := 0; ok; {
+		result = append(result, s[begin:i]...)
+		for len(s)-i >= len(b) && bytes.Equal(s[i:i+len(b)], b) {
+			i += len(b)
+			found++
+		}
+		begin = i
+		_, _, i, ok = s.GetOp(i)
+	}
+	if found == 0 {
+		return s, 0
+	}
+	return append(result, s[begin:]...), found
+}
+
 // IsUnspendable Returns whether the script is guaranteed to fail at execution,
 // regardless of the initial stack. This allows outputs to be pruned
 // instantly when entering the UTXO set.
@@ -262,30 +345,16 @@ func (s Script) Size() int {
 //
 // }
 
-// P2PKH DUP HASH160 PUSHDATA(20)[679fbd5c5e5146d61b00042a3c68f8682651aa66] EQUALVERIFY CHECKSIG
-// P2SH HASH160 PUSHDATA(20)[355fa493f0dc8795f3ba4dfdce346a51c6008375] EQUAL
-
-// ScriptSig:
-// PUSHDATA(72)[3045022100da92e9bd2dd926acd062bfc5cc694ed4639fc092d154321a064313b64708e36b022040377fe4d632fd87fac9b2d52c82c184b579eb7179defdd4dd826fd98d9e0a5901]
-// PUSHDATA(33)[038836175234670ee4c53943900615248f4a007b002b3c91c84c3edae009b9af3b]
-
-// ScriptSig:
-// 0[]
-// PUSHDATA(71)[304402202d36d44387d92366d3c5469f26bc413e907564bd646f304f37eefab1371242d902200b0f0ed43a9f66b8e0da96907fdcdd362d5a66ca8fce041d7f20f8024dcaf66b01]
-// PUSHDATA(72)[304502210090ca8f60035a0d2d42417714b1b702cb21b77fe7540436c8526736dd5cc40b60022038d6a1552710b90120d8c3a4c669fd6f3b878d1fb87fee453e693dd75d8854ec01]
-// PUSHDATA1[5221023df3558b2d0cd5ac358a3f0a6d10b4f8fd74af7fc5c18e6b502d56768c1acf1b2102c977fbf3fb7919d6d0411a001113510f0c9e4b74988e749378e77aa465beda71210240dbbb25ce93a544a47002af049d33f04465a886b967fb6375cd2c88902b69e453ae]
-
+// ToP2SHScriptPubkey returns the p2sh scriptPubKey which requires this script
+// as the redeem script to spend. A redeem script larger than 520 bytes can not
+// be pushed, so the output would be unspendable.
 func (s Script) ToP2SHScriptPubkey() Script {
-	// Create P2SH scriptPubKey from this redeemScript
-	// That is, create the P2SH scriptPubKey that requires this script as a
-	// redeemScript to spend.
-	// checksize - Check if the redeemScript is larger than the 520-byte max
-	// pushdata limit; raise ValueError if limit exceeded.
-	// Since a >520-byte PUSHDATA makes EvalScript() fail, it's not actually
-	// possible to redeem P2SH outputs with redeem scripts >520 bytes.
-
-	// if checksize and len(self) > MAX_SCRIPT_ELEMENT_SIZE:
-	// 	raise ValueError("redeemScript exceeds max allowed size; P2SH output would be unspendable")
-	// return Script([OP_HASH160, crypto.Hash160(self), OP_EQUAL])
-	return nil
+	return P2SHScript(crypto.Hash160(s))
+}
+
+// ToP2WSHScriptPubkey returns the p2wsh scriptPubKey which requires this script
+// as the witness script to spend.
+func (s Script) ToP2WSHScriptPubkey() Script {
+	h := crypto.Sha256(s)
+	return P2WSHScript(h[:])
 }
@@ -5,14 +5,27 @@ type Stack struct {
 	d [][]byte
 }
 
-// Pop pops the value off the top of the stack.
-func (s Stack) Pop() (elem []byte) {
-	elem, s.d = s.d[len(s.d)-1], s.d[:len(s.d)-1]
-	return
+// NewStack returns the stack of the items, the last item is the top.
+func NewStack(items ...[]byte) *Stack {
+	return &Stack{d: append([][]byte{}, items...)}
+}
+
+// Items returns the items of the stack, the last item is the top.
+func (s *Stack) Items() [][]byte {
+	return s.d
+}
+
+// Pop pops the value off the top of the stack, it returns
+// ErrInvalidStackOperation if the stack is empty.
+func (s *Stack) Pop() ([]byte, error) {
+	if len(s.d) == 0 {
+		return nil, ErrInvalidStackOperation
+	}
+	return s.pop(), nil
 }
 
 // Push pushs the value to the top of the stack.
-func (s Stack) Push(elem []byte) {
+func (s *Stack) Push(elem []byte) {
 	s.d = append(s.d, elem)
 }
 
@@ -27,11 +40,83 @@ func (s Stack) Push(elem []byte) {
 // }
 
 // Size returns the length of the stack.
-func (s Stack) Size() int {
+func (s *Stack) Size() int {
 	return len(s.d)
 }
 
-// Top returns the elem according the index.
-func (s Stack) Top(i int) []byte {
+// Top returns the elem according the index, -1 is the top. It returns
+// ErrInvalidStackOperation if the index is out of the stack.
+func (s *Stack) Top(i int) ([]byte, error) {
+	if i >= 0 || -i > len(s.d) {
+		return nil, ErrInvalidStackOperation
+	}
+	return s.top(i), nil
+}
+
+// TopNumber returns the elem according the index as a script number of at
+// most maxNumSize bytes.
+func (s *Stack) TopNumber(i int, isRequireMinimal bool, maxNumSize int) (BigNumber, error) {
+	elem, err := s.Top(i)
+	if err != nil {
+		return 0, err
+	}
+	return BytesToBigNumber(elem, isRequireMinimal, maxNumSize)
+}
+
+// PushNumber pushs the script number to the top of the stack.
+func (s *Stack) PushNumber(bn BigNumber) {
+	s.Push(bn.Bytes())
+}
+
+// PushBool pushs 1 for true and an empty value for false.
+func (s *Stack) PushBool(v bool) {
+	if v {
+		s.Push(vchTrue)
+	} else {
+		s.Push(vchFalse)
+	}
+}
+
+// top returns the elem according the index as Top, the caller checks the
+// size of the stack.
+func (s *Stack) top(i int) []byte {
 	return s.d[len(s.d)+i]
 }
+
+// pop pops the value off the top of the stack, the caller checks the stack is
+// not empty.
+func (s *Stack) pop() (elem []byte) {
+	elem, s.d = s.d[len(s.d)-1], s.d[:len(s.d)-1]
+	return
+}
+
+// remove removes the elem according the index and returns it.
+func (s *Stack) remove(i int) []byte {
+	i += len(s.d)
+	elem := s.d[i]
+	s.d = append(s.d[:i], s.d[i+1:]...)
+	return elem
+}
+
+// insert inserts the elem before the elem of the index.
+func (s *Stack) insert(i int, elem []byte) {
+	i += len(s.d)
+	s.d = append(s.d, nil)
+	copy(s.d[i+1:], s.d[i:])
+	s.d[i] = elem
+}
+
+// swap swaps the elems of the indexes.
+func (s *Stack) swap(i, j int) {
+	i, j = i+len(s.d), j+len(s.d)
+	s.d[i], s.d[j] = s.d[j], s.d[i]
+}
+
+// checkStackSize checks the size of the stack and the altstack against
+// MaxStackSize.
+func checkStackSize(stack, altstack *Stack) error {
+	if stack.Size()+altstack.Size() > MaxStackSize {
+		return ErrStackSize
+	}
+	return nil
+}
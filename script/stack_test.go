@@ -0,0 +1,86 @@
+package script
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStack(t *testing.T) {
+	stack := NewStack([]byte{1}, []byte{2})
+	stack.Push([]byte{3})
+	if stack.Size() != 3 || !bytes.Equal(stack.top(-1), []byte{3}) || !bytes.Equal(stack.top(-3), []byte{1}) {
+		t.Fatalf("stack %x, except 010203", stack.Items())
+	}
+
+	for _, i := range []int{0, 1, -4} {
+		if _, err := stack.Top(i); err != ErrInvalidStackOperation {
+			t.Errorf("Top(%d) error %v, except %v", i, err, ErrInvalidStackOperation)
+		}
+	}
+	if elem, err := stack.Top(-2); err != nil || !bytes.Equal(elem, []byte{2}) {
+		t.Errorf("Top(-2) = %x, except 02, err %v", elem, err)
+	}
+
+	stack.insert(-1, []byte{4})
+	stack.swap(-4, -1)
+	if elem := stack.remove(-2); !bytes.Equal(elem, []byte{4}) {
+		t.Errorf("remove(-2) = %x, except 04", elem)
+	}
+	for _, except := range [][]byte{{1}, {2}, {3}} {
+		if elem, err := stack.Pop(); err != nil || !bytes.Equal(elem, except) {
+			t.Errorf("Pop = %x, except %x, err %v", elem, except, err)
+		}
+	}
+	if _, err := stack.Pop(); err != ErrInvalidStackOperation {
+		t.Errorf("Pop of empty stack error %v, except %v", err, ErrInvalidStackOperation)
+	}
+
+	stack.PushNumber(-255)
+	stack.PushBool(true)
+	stack.PushBool(false)
+	if n, err := stack.TopNumber(-3, true, defaultMaxNumSize); err != nil || n != -255 {
+		t.Errorf("TopNumber(-3) = %d, except -255, err %v", n, err)
+	}
+	if n, err := stack.TopNumber(-2, true, defaultMaxNumSize); err != nil || n != 1 {
+		t.Errorf("TopNumber(-2) = %d, except 1, err %v", n, err)
+	}
+	if elem := stack.top(-1); len(elem) != 0 {
+		t.Errorf("PushBool(false) = %x, except empty", elem)
+	}
+	if _, err := stack.TopNumber(-4, true, defaultMaxNumSize); err != ErrInvalidStackOperation {
+		t.Errorf("TopNumber(-4) error %v, except %v", err, ErrInvalidStackOperation)
+	}
+	stack.Push([]byte{1, 0})
+	if _, err := stack.TopNumber(-1, true, defaultMaxNumSize); err != ErrNumberNotMinimal {
+		t.Errorf("TopNumber of 0100 error %v, except %v", err, ErrNumberNotMinimal)
+	}
+}
+
+func TestStackSize(t *testing.T) {
+	var stack, altstack Stack
+	for i := 0; i < MaxStackSize; i++ {
+		stack.Push(nil)
+	}
+	if err := checkStackSize(&stack, &altstack); err != nil {
+		t.Errorf("checkStackSize error %v", err)
+	}
+	altstack.Push(nil)
+	if err := checkStackSize(&stack, &altstack); err != ErrStackSize {
+		t.Errorf("checkStackSize error %v, except %v", err, ErrStackSize)
+	}
+
+	// 1000 items on the stack and the altstack
+	s, _ := ParseAsm("OP_1 OP_TOALTSTACK OP_1 OP_DUP")
+	vm, _ := NewInterpreter(NewStack(stack.Items()[:MaxStackSize-2]...), s, SigVersionBase, ScriptVerifyNone, BaseSignatureChecker{})
+	for i := 0; i < 3; i++ {
+		if err := vm.Step(); err != nil {
+			t.Fatalf("#%d Step error %v", i, err)
+		}
+	}
+	if vm.Stack().Size() != MaxStackSize-1 || vm.AltStack().Size() != 1 {
+		t.Errorf("stack %d altstack %d, except %d 1", vm.Stack().Size(), vm.AltStack().Size(), MaxStackSize-1)
+	}
+	if err := vm.Step(); err != ErrStackSize {
+		t.Errorf("Step error %v, except %v", err, ErrStackSize)
+	}
+}
@@ -0,0 +1,268 @@
+package script
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+
+	"github.com/maiiz/coinlib/crypto"
+	"github.com/maiiz/coinlib/params"
+)
+
+var (
+	ErrMultisigParams = errors.New("invalid multisig m or n")
+	ErrMultisigPubkey = errors.New("invalid multisig pubkey")
+)
+
+// P2PKHScript returns the p2pkh scriptPubKey of the 20 bytes pubkey hash:
+// DUP HASH160 <hash> EQUALVERIFY CHECKSIG, it is also the scriptCode of a
+// p2wpkh input.
+func P2PKHScript(hash []byte) Script {
+	s := Script{OP_DUP, OP_HASH160}
+	s.PushData(hash)
+	s.AddOpCode(OP_EQUALVERIFY)
+	s.AddOpCode(OP_CHECKSIG)
+	return s
+}
+
+// P2SHScript returns the p2sh scriptPubKey of the 20 bytes script hash:
+// HASH160 <hash> EQUAL.
+func P2SHScript(hash []byte) Script {
+	s := Script{OP_HASH160}
+	s.PushData(hash)
+	s.AddOpCode(OP_EQUAL)
+	return s
+}
+
+// P2WPKHScript returns the version 0 witness program of the 20 bytes pubkey
+// hash: 0 <hash>.
+func P2WPKHScript(hash []byte) Script {
+	s := Script{OP_0}
+	s.PushData(hash)
+	return s
+}
+
+// P2WSHScript returns the version 0 witness program of the 32 bytes script
+// hash: 0 <hash>.
+func P2WSHScript(hash []byte) Script {
+	s := Script{OP_0}
+	s.PushData(hash)
+	return s
+}
+
+// P2TRScript returns the version 1 witness program of the 32 bytes x-only
+// taproot output key: 1 <key>.
+func P2TRScript(outputKey []byte) Script {
+	s := Script{OP_1}
+	s.PushData(outputKey)
+	return s
+}
+
+// MultisigScript returns the m-of-n redeem script of the public keys sorted
+// as BIP67: OP_m <pubkey>... OP_n CHECKMULTISIG.
+func MultisigScript(m int, pubkeys [][]byte) (Script, error) {
+	n := len(pubkeys)
+	if m < 1 || m > n || n > 16 {
+		return nil, ErrMultisigParams
+	}
+	sorted := make([][]byte, n)
+	for i, pub := range pubkeys {
+		if !isPubkey(pub) {
+			return nil, ErrMultisigPubkey
+		}
+		sorted[i] = pub
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i], sorted[j]) < 0
+	})
+
+	var s Script
+	s.AddInt64(int64(m))
+	for _, pub := range sorted {
+		s.PushData(pub)
+	}
+	s.AddInt64(int64(n))
+	s.AddOpCode(OP_CHECKMULTISIG)
+	return s, nil
+}
+
+// ParseMultisigScript returns m and the public keys of a CHECKMULTISIG redeem
+// script, ok is false if the script is not one.
+func ParseMultisigScript(s Script) (m int, pubkeys [][]byte, ok bool) {
+	if len(s) < 3 || s[len(s)-1] != OP_CHECKMULTISIG {
+		return 0, nil, false
+	}
+	if s[0] < OP_1 || s[0] > OP_16 || s[len(s)-2] < OP_1 || s[len(s)-2] > OP_16 {
+		return 0, nil, false
+	}
+	m, n := DecodeOPN(int(s[0])), DecodeOPN(int(s[len(s)-2]))
+	if m > n {
+		return 0, nil, false
+	}
+
+	body := s[1 : len(s)-2]
+	for i := 0; i < len(body); {
+		opCode, data, next, ok := body.GetOp(i)
+		if !ok || opCode > OP_PUSHDATA4 || !isPubkey(data) {
+			return 0, nil, false
+		}
+		pubkeys = append(pubkeys, data)
+		i = next
+	}
+	if len(pubkeys) != n {
+		return 0, nil, false
+	}
+	return m, pubkeys, true
+}
+
+// isPubkey reports whether b is a compressed or uncompressed public key.
+func isPubkey(b []byte) bool {
+	switch len(b) {
+	case 33:
+		return b[0] == 0x02 || b[0] == 0x03
+	case 65:
+		return b[0] == 0x04
+	}
+	return false
+}
+
+// ScriptClass is the standard template of a scriptPubKey.
+type ScriptClass int
+
+const (
+	ClassNonStandard ScriptClass = iota
+	ClassP2PK
+	ClassP2PKH
+	ClassP2SH
+	ClassP2WPKH
+	ClassP2WSH
+	ClassP2TR
+	ClassMultisig
+	ClassNullData
+)
+
+// String returns the name of the class used by Bitcoin Core.
+func (c ScriptClass) String() string {
+	switch c {
+	case ClassP2PK:
+		return "pubkey"
+	case ClassP2PKH:
+		return "pubkeyhash"
+	case ClassP2SH:
+		return "scripthash"
+	case ClassP2WPKH:
+		return "witness_v0_keyhash"
+	case ClassP2WSH:
+		return "witness_v0_scripthash"
+	case ClassP2TR:
+		return "witness_v1_taproot"
+	case ClassMultisig:
+		return "multisig"
+	case ClassNullData:
+		return "nulldata"
+	}
+	return "nonstandard"
+}
+
+// IsP2TR returns if the script is a version 1 witness program of a 32 bytes
+// x-only public key.
+func (s Script) IsP2TR() bool {
+	return (len(s) == 34 &&
+		s[0] == OP_1 &&
+		s[1] == 0x20)
+}
+
+// IsP2PK returns if the script is <pubkey> CHECKSIG.
+func (s Script) IsP2PK() bool {
+	_, ok := s.p2pkPubkey()
+	return ok
+}
+
+func (s Script) p2pkPubkey() ([]byte, bool) {
+	if len(s) < 2 || s[len(s)-1] != OP_CHECKSIG {
+		return nil, false
+	}
+	opCode, data, next, ok := s.GetOp(0)
+	if !ok || opCode > OP_PUSHDATA4 || next != len(s)-1 || !isPubkey(data) {
+		return nil, false
+	}
+	return data, true
+}
+
+// IsNullData returns if the script is OP_RETURN followed by pushes only.
+func (s Script) IsNullData() bool {
+	return len(s) >= 1 && s[0] == OP_RETURN && s[1:].IsPushOnly()
+}
+
+// Class returns the standard template of the scriptPubKey.
+func (s Script) Class() ScriptClass {
+	switch {
+	case s.IsP2PKH():
+		return ClassP2PKH
+	case s.IsP2SH():
+		return ClassP2SH
+	case s.IsP2WPKH():
+		return ClassP2WPKH
+	case s.IsP2WSH():
+		return ClassP2WSH
+	case s.IsP2TR():
+		return ClassP2TR
+	case s.IsP2PK():
+		return ClassP2PK
+	case s.IsNullData():
+		return ClassNullData
+	}
+	if _, _, ok := ParseMultisigScript(s); ok {
+		return ClassMultisig
+	}
+	return ClassNonStandard
+}
+
+// ScriptInfo is the template of a scriptPubKey and what it pays to.
+type ScriptInfo struct {
+	Class ScriptClass
+	// Addresses are the addresses the script pays to, the public keys of
+	// P2PK and multisig are rendered as their P2PKH addresses.
+	Addresses []string
+	// Pubkeys are the public keys of P2PK and multisig, and the x-only output
+	// key of P2TR.
+	Pubkeys [][]byte
+	// Required is the number of signatures required to spend the script.
+	Required int
+}
+
+// ExtractAddresses returns the template of the scriptPubKey and the addresses
+// of the chain it pays to. On a chain without segwit the witness programs are
+// nonstandard.
+func (s Script) ExtractAddresses(p *params.ChainParams) *ScriptInfo {
+	info := &ScriptInfo{Class: s.Class(), Required: 1}
+	switch info.Class {
+	case ClassP2PK:
+		pubkey, _ := s.p2pkPubkey()
+		info.Pubkeys = [][]byte{pubkey}
+		info.Addresses = []string{p.ToAddress(crypto.Hash160(pubkey))}
+	case ClassP2PKH:
+		info.Addresses = []string{p.ToAddress(s[3:23])}
+	case ClassP2SH:
+		info.Addresses = []string{p.ToScriptAddress(s[2:22])}
+	case ClassP2WPKH, ClassP2WSH, ClassP2TR:
+		if p.Bech32HRPSegwit == "" {
+			return &ScriptInfo{Class: ClassNonStandard}
+		}
+		version := byte(0)
+		if info.Class == ClassP2TR {
+			version = 1
+			info.Pubkeys = [][]byte{s[2:]}
+		}
+		addr, _ := p.ToWitnessAddress(version, s[2:])
+		info.Addresses = []string{addr}
+	case ClassMultisig:
+		info.Required, info.Pubkeys, _ = ParseMultisigScript(s)
+		for _, pubkey := range info.Pubkeys {
+			info.Addresses = append(info.Addresses, p.ToAddress(crypto.Hash160(pubkey)))
+		}
+	default:
+		info.Required = 0
+	}
+	return info
+}
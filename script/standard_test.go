@@ -0,0 +1,146 @@
+package script
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/maiiz/coinlib/params"
+)
+
+// Test vector of BIP67, the keys are sorted in the redeem script.
+var multisigTests = []struct {
+	m       int
+	pubkeys []string
+	script  string
+}{
+	{
+		2,
+		[]string{
+			"02ff12471208c14bd580709cb2358d98975247d8765f92bc25eab3b2763ed605f8",
+			"02fe6f0a5a297eb38c391581c4413e084773ea23954d93f7753db7dc0adc188b2f",
+		},
+		"522102fe6f0a5a297eb38c391581c4413e084773ea23954d93f7753db7dc0adc188b2f2102ff12471208c14bd580709cb2358d98975247d8765f92bc25eab3b2763ed605f852ae",
+	},
+	{
+		2,
+		[]string{
+			"02d0de0aaeaefad02b8bdc8a01a1b8b11c696bd3d66a2c5f10780d95b7df42645c",
+			"034f355bdcb7cc0af728ef3cceb9615d90684bb5b2ca5f859ab0f0b704075871aa",
+			"02466d7fcae563e5cb09a0d1870bb580344804617879a14949cf22285f1bae3f27",
+		},
+		"522102466d7fcae563e5cb09a0d1870bb580344804617879a14949cf22285f1bae3f272102d0de0aaeaefad02b8bdc8a01a1b8b11c696bd3d66a2c5f10780d95b7df42645c21034f355bdcb7cc0af728ef3cceb9615d90684bb5b2ca5f859ab0f0b704075871aa53ae",
+	},
+}
+
+func TestMultisigScript(t *testing.T) {
+	for i, test := range multisigTests {
+		var pubkeys [][]byte
+		for _, pub := range test.pubkeys {
+			b, _ := hex.DecodeString(pub)
+			pubkeys = append(pubkeys, b)
+		}
+		s, err := MultisigScript(test.m, pubkeys)
+		if err != nil || hex.EncodeToString(s) != test.script {
+			t.Errorf("#%d MultisigScript = %x, except %s, err %v", i, s, test.script, err)
+			continue
+		}
+
+		m, parsed, ok := ParseMultisigScript(s)
+		if !ok || m != test.m || len(parsed) != len(pubkeys) {
+			t.Errorf("#%d ParseMultisigScript = %d %x %v", i, m, parsed, ok)
+			continue
+		}
+		for j := 1; j < len(parsed); j++ {
+			if bytes.Compare(parsed[j-1], parsed[j]) >= 0 {
+				t.Errorf("#%d pubkeys not sorted", i)
+			}
+		}
+	}
+
+	pub, _ := hex.DecodeString(multisigTests[0].pubkeys[0])
+	if _, err := MultisigScript(2, [][]byte{pub}); err != ErrMultisigParams {
+		t.Errorf("MultisigScript 2-of-1 error %v, except %v", err, ErrMultisigParams)
+	}
+	if _, err := MultisigScript(1, [][]byte{pub[:32]}); err != ErrMultisigPubkey {
+		t.Errorf("MultisigScript of 32 bytes pubkey error %v, except %v", err, ErrMultisigPubkey)
+	}
+	if _, _, ok := ParseMultisigScript(P2PKHScript(make([]byte, 20))); ok {
+		t.Errorf("ParseMultisigScript of p2pkh script ok")
+	}
+}
+
+func TestToScriptPubkey(t *testing.T) {
+	s, _ := hex.DecodeString(multisigTests[1].script)
+	if p2sh := hex.EncodeToString(Script(s).ToP2SHScriptPubkey()); p2sh != "a914db9463443be7bf9bb04d2a27f0c8e5710829ddd587" {
+		t.Errorf("ToP2SHScriptPubkey = %s", p2sh)
+	}
+	if p2wsh := hex.EncodeToString(Script(s).ToP2WSHScriptPubkey()); p2wsh != "0020a6cac52c2f646efa5e7cf2aea0fb80c1c595113b8a845b0b5ac07a38bd243ef8" {
+		t.Errorf("ToP2WSHScriptPubkey = %s", p2wsh)
+	}
+}
+
+func TestExtractAddresses(t *testing.T) {
+	const (
+		g    = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+		hash = "751e76e8199196d454941c45d1b3a323f1433bd6"
+		key  = "a60869f0dbcf1dc659c9cecbaf8050135ea9e8cdc487053f1dc6880949dc684c"
+	)
+	tests := []struct {
+		script   string
+		class    ScriptClass
+		addrs    []string
+		pubkeys  []string
+		required int
+	}{
+		{"21" + g + "ac", ClassP2PK, []string{"1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"}, []string{g}, 1},
+		{"76a914" + hash + "88ac", ClassP2PKH, []string{"1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"}, nil, 1},
+		{"a914" + hash + "87", ClassP2SH, []string{"3CNHUhP3uyB9EUtRLsmvFUmvGdjGdkTxJw"}, nil, 1},
+		{"0014" + hash, ClassP2WPKH, []string{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"}, nil, 1},
+		{"00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262", ClassP2WSH, []string{"bc1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3qccfmv3"}, nil, 1},
+		{"5120" + key, ClassP2TR, []string{"bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr"}, []string{key}, 1},
+		{"5121" + g + "51ae", ClassMultisig, []string{"1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"}, []string{g}, 1},
+		{"6a0568656c6c6f", ClassNullData, nil, nil, 0},
+		{"6a", ClassNullData, nil, nil, 0},
+		// unknown witness version
+		{"5214" + hash, ClassNonStandard, nil, nil, 0},
+		// invalid pubkey
+		{"2105" + g[2:] + "ac", ClassNonStandard, nil, nil, 0},
+		{"6a76", ClassNonStandard, nil, nil, 0},
+		{"", ClassNonStandard, nil, nil, 0},
+	}
+
+	btc := params.GetChain(params.BTC)
+	for i, test := range tests {
+		s, _ := hex.DecodeString(test.script)
+		info := Script(s).ExtractAddresses(btc)
+		if info.Class != test.class || info.Required != test.required {
+			t.Errorf("#%d class %s required %d, except %s %d", i, info.Class, info.Required, test.class, test.required)
+			continue
+		}
+		if len(info.Addresses) != len(test.addrs) || len(info.Pubkeys) != len(test.pubkeys) {
+			t.Errorf("#%d addresses %v pubkeys %x, except %v %v", i, info.Addresses, info.Pubkeys, test.addrs, test.pubkeys)
+			continue
+		}
+		for j, addr := range test.addrs {
+			if info.Addresses[j] != addr {
+				t.Errorf("#%d address %s, except %s", i, info.Addresses[j], addr)
+			}
+		}
+		for j, pub := range test.pubkeys {
+			if hex.EncodeToString(info.Pubkeys[j]) != pub {
+				t.Errorf("#%d pubkey %x, except %s", i, info.Pubkeys[j], pub)
+			}
+		}
+	}
+
+	// no segwit on bcc
+	s, _ := hex.DecodeString("0014" + hash)
+	if info := Script(s).ExtractAddresses(params.GetChain(params.BCC)); info.Class != ClassNonStandard {
+		t.Errorf("bcc class %s, except %s", info.Class, ClassNonStandard)
+	}
+	s, _ = hex.DecodeString("a914" + hash + "87")
+	if info := Script(s).ExtractAddresses(params.GetChain(params.LTC)); info.Addresses[0] != "3CNHUhP3uyB9EUtRLsmvFUmvGdjGdkTxJw" {
+		t.Errorf("ltc address %s", info.Addresses[0])
+	}
+}
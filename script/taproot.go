@@ -0,0 +1,132 @@
+package script
+
+import (
+	"bytes"
+
+	"github.com/maiiz/coinlib/crypto"
+	"github.com/maiiz/coinlib/crypto/secp256k1"
+	"github.com/maiiz/coinlib/encoding/varint"
+)
+
+const (
+	// TaprootLeafTapscript is the leaf version of the tapscripts of BIP342.
+	TaprootLeafTapscript = 0xc0
+	// taprootLeafMask masks the parity of the output key off the first byte
+	// of a control block.
+	taprootLeafMask = 0xfe
+
+	// annexTag is the first byte of the annex, the last witness item of a
+	// taproot spend.
+	annexTag = 0x50
+
+	// The control block is the leaf version and internal key followed by the
+	// merkle path of the tapscript.
+	taprootControlBaseSize     = 33
+	taprootControlNodeSize     = 32
+	taprootControlMaxNodeCount = 128
+	taprootControlMaxSize      = taprootControlBaseSize + taprootControlNodeSize*taprootControlMaxNodeCount
+)
+
+// TapleafHash returns the leaf hash of the script with the leaf version, the
+// leaf of a taproot script tree (BIP341).
+func TapleafHash(leafVersion byte, s Script) crypto.Hash {
+	buf := new(bytes.Buffer)
+	buf.WriteByte(leafVersion)
+	s.Marshal(buf)
+	return crypto.TaggedHash("TapLeaf", buf.Bytes())
+}
+
+// TapBranchHash returns the hash of the branch of two nodes in a taproot
+// script tree, the nodes are sorted so the order of the children is free.
+func TapBranchHash(a, b []byte) crypto.Hash {
+	if bytes.Compare(a, b) > 0 {
+		a, b = b, a
+	}
+	return crypto.TaggedHash("TapBranch", a, b)
+}
+
+// taprootMerkleRoot returns the merkle root of the tree from the leaf hash up
+// the merkle path of the control block.
+func taprootMerkleRoot(control []byte, tapleafHash crypto.Hash) crypto.Hash {
+	k := tapleafHash
+	for i := taprootControlBaseSize; i < len(control); i += taprootControlNodeSize {
+		k = TapBranchHash(k[:], control[i:i+taprootControlNodeSize])
+	}
+	return k
+}
+
+// verifyTaproot verifies the witness spending the taproot output key by the
+// key path, a signature, or the script path, a tapscript with its control
+// block.
+func verifyTaproot(witness [][]byte, program []byte, flags int, checker SignatureChecker, trace *Trace) error {
+	if len(witness) == 0 {
+		return ErrWitnessProgramWitnessEmpty
+	}
+	execData := newExecData()
+	stack := NewStack(witness...)
+
+	// the annex is removed from the stack, only its hash is signed.
+	if last := witness[len(witness)-1]; len(witness) >= 2 && len(last) > 0 && last[0] == annexTag {
+		stack.pop()
+		buf := new(bytes.Buffer)
+		Script(last).Marshal(buf)
+		execData.AnnexPresent = true
+		execData.AnnexHash = crypto.Sha256(buf.Bytes())
+	}
+
+	if stack.Size() == 1 {
+		// Key path spending, the stack is the signature.
+		return checker.CheckSchnorrSig(stack.top(-1), program, SigVersionTaproot, execData)
+	}
+
+	// Script path spending, the stack is the input of the script, the script
+	// and the control block.
+	control := stack.pop()
+	tapscript := Script(stack.pop())
+	if len(control) < taprootControlBaseSize || len(control) > taprootControlMaxSize ||
+		(len(control)-taprootControlBaseSize)%taprootControlNodeSize != 0 {
+		return ErrTaprootWrongControlSize
+	}
+	leafVersion := control[0] & taprootLeafMask
+	execData.TapleafHash = TapleafHash(leafVersion, tapscript)
+	root := taprootMerkleRoot(control, execData.TapleafHash)
+	if !secp256k1.CheckTaprootTweak(control[1:taprootControlBaseSize], root[:], program, control[0]&1) {
+		return ErrWitnessProgramMimatch
+	}
+
+	if leafVersion != TaprootLeafTapscript {
+		if (flags & ScriptVerifyDiscourageUpgradableTaprootVersion) != 0 {
+			return ErrDiscourageUpgradableTaprootVersion
+		}
+		// The unknown leaf versions succeed for future softfork compatibility
+		return nil
+	}
+
+	// The signatures of the tapscript are paid by the size of the witness.
+	size := len(varint.VarInt(uint64(len(witness))))
+	for _, item := range witness {
+		size += len(varint.VarInt(uint64(len(item)))) + len(item)
+	}
+	execData.validationWeightLeft = int64(size) + validationWeightOffset
+
+	// OP_SUCCESSx makes the tapscript succeed unconditionally, even with
+	// stack items over the size limit.
+	for i := 0; i < len(tapscript); {
+		opCode, _, next, ok := tapscript.GetOp(i)
+		if !ok {
+			return ErrBadOPCode
+		}
+		if IsOpSuccess(opCode) {
+			if (flags & ScriptVerifyDiscourageOpSuccess) != 0 {
+				return ErrDiscourageOpSuccess
+			}
+			return nil
+		}
+		i = next
+	}
+	// The initial stack of a tapscript is limited as the stack after an opcode.
+	if stack.Size() > MaxStackSize {
+		return ErrStackSize
+	}
+	return executeWitnessScript(stack, tapscript, SigVersionTapscript, flags, checker, execData, trace, "tapscript")
+}
@@ -0,0 +1,129 @@
+package script
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/maiiz/coinlib/crypto/secp256k1"
+)
+
+// the x coordinate of G is the internal key of the test outputs.
+var testInternalKey, _ = hex.DecodeString("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798")
+
+// taprootOutput returns the scriptPubKey of the script tree of the tapscript
+// and the sibling node, and the control block of the tapscript.
+func taprootOutput(leafVersion byte, tapscript Script, sibling []byte) (Script, []byte) {
+	root := TapleafHash(leafVersion, tapscript)
+	if sibling != nil {
+		root = TapBranchHash(root[:], sibling)
+	}
+	outputKey, parity, _ := secp256k1.TweakTaprootPubkey(testInternalKey, root[:])
+	control := append([]byte{leafVersion | parity}, testInternalKey...)
+	return P2TRScript(outputKey), append(control, sibling...)
+}
+
+func TestVerifyTaproot(t *testing.T) {
+	const flags = ScriptVeryP2SH | ScriptVerifyWitness | ScriptVerifyTaproot
+	var (
+		sibling  = bytes.Repeat([]byte{0xab}, 32)
+		parse    = func(asm string) Script { s, _ := ParseAsm(asm); return s }
+		pubkey33 = "02" + hex.EncodeToString(testInternalKey)
+	)
+	tests := []struct {
+		leafVersion byte
+		tapscript   Script
+		stack       [][]byte
+		flags       int
+		err         error
+	}{
+		{TaprootLeafTapscript, parse("OP_1"), nil, flags, nil},
+		{TaprootLeafTapscript, parse("OP_0"), nil, flags, ErrEvalFalse},
+		{TaprootLeafTapscript, parse("OP_1 OP_1"), nil, flags, ErrEvalFalse},
+		// OP_SUCCESSx succeeds before a malformed push
+		{TaprootLeafTapscript, Script{OP_RESERVED, OP_PUSHDATA1}, nil, flags, nil},
+		{TaprootLeafTapscript, Script{0xbb}, nil, flags | ScriptVerifyDiscourageOpSuccess, ErrDiscourageOpSuccess},
+		{TaprootLeafTapscript, Script{OP_1, OP_PUSHDATA1}, nil, flags, ErrBadOPCode},
+		// unknown leaf versions
+		{0xc2, parse("OP_0"), nil, flags, nil},
+		{0xc2, parse("OP_0"), nil, flags | ScriptVerifyDiscourageUpgradableTaprootVersion, ErrDiscourageUpgradableTaprootVersion},
+		// tapscript rules
+		{TaprootLeafTapscript, parse("OP_IF OP_1 OP_ENDIF"), [][]byte{{2}}, flags, ErrTapscriptMinimalIf},
+		{TaprootLeafTapscript, parse("OP_IF OP_1 OP_ENDIF"), [][]byte{{1}}, flags, nil},
+		{TaprootLeafTapscript, parse("OP_0 OP_0 OP_CHECKMULTISIG"), nil, flags, ErrTapscriptCheckMultiSig},
+		{TaprootLeafTapscript, parse("OP_0 OP_CHECKSIG"), [][]byte{{1}}, flags, ErrPubkeyType},
+		// the signatures of unknown public key types are valid
+		{TaprootLeafTapscript, parse(pubkey33 + " OP_CHECKSIG"), [][]byte{{1}}, flags, nil},
+		{TaprootLeafTapscript, parse(pubkey33 + " OP_CHECKSIG"), [][]byte{{1}}, flags | ScriptVerifyDiscourageUpgradablePubkeyType, ErrDiscourageUpgradablePubkeyType},
+		// an empty signature is false, others must be valid
+		{TaprootLeafTapscript, parse(hex.EncodeToString(testInternalKey) + " OP_CHECKSIG OP_NOT"), [][]byte{{}}, flags, nil},
+		{TaprootLeafTapscript, parse(hex.EncodeToString(testInternalKey) + " OP_CHECKSIG"), [][]byte{{1}}, flags, ErrSchnorrSig},
+		{TaprootLeafTapscript, parse("OP_0 OP_1 " + pubkey33 + " OP_CHECKSIGADD"), nil, flags, nil},
+		// no opcode limit in tapscript
+		{TaprootLeafTapscript, append(bytes.Repeat(Script{OP_NOP}, MaxOpsPerScript+1), OP_1), nil, flags, nil},
+	}
+	for i, test := range tests {
+		pkScript, control := taprootOutput(test.leafVersion, test.tapscript, sibling)
+		witness := append(test.stack, test.tapscript, control)
+		if err := VerifyScript(nil, pkScript, witness, test.flags, BaseSignatureChecker{}); err != test.err {
+			t.Errorf("#%d VerifyScript error %v, except %v", i, err, test.err)
+		}
+	}
+
+	pkScript, control := taprootOutput(TaprootLeafTapscript, Script{OP_1}, sibling)
+	witnessTests := []struct {
+		witness [][]byte
+		flags   int
+		err     error
+	}{
+		{nil, flags, ErrWitnessProgramWitnessEmpty},
+		// key path
+		{[][]byte{make([]byte, 64)}, flags, ErrSchnorrSig},
+		// the annex is removed before the key path and script path
+		{[][]byte{make([]byte, 64), {annexTag}}, flags, ErrSchnorrSig},
+		{[][]byte{{OP_1}, control, {annexTag, 1}}, flags, nil},
+		{[][]byte{{OP_1}, control[:len(control)-1]}, flags, ErrTaprootWrongControlSize},
+		{[][]byte{{OP_1}, control[:32]}, flags, ErrTaprootWrongControlSize},
+		{[][]byte{{OP_1}, append([]byte{control[0] ^ 1}, control[1:]...)}, flags, ErrWitnessProgramMimatch},
+		{[][]byte{{OP_2}, control}, flags, ErrWitnessProgramMimatch},
+		// taproot is not active
+		{[][]byte{{OP_2}, control}, ScriptVeryP2SH | ScriptVerifyWitness, nil},
+		{[][]byte{{OP_2}, control}, ScriptVeryP2SH | ScriptVerifyWitness | ScriptVerifyDiscourageUpgradableWitnessProgram, nil},
+	}
+	for i, test := range witnessTests {
+		if err := VerifyScript(nil, pkScript, test.witness, test.flags, BaseSignatureChecker{}); err != test.err {
+			t.Errorf("#%d VerifyScript error %v, except %v", i, err, test.err)
+		}
+	}
+
+	// the version 1 program in P2SH is not taproot
+	var scriptSig Script
+	scriptSig.PushData(pkScript)
+	if err := VerifyScript(scriptSig, pkScript.ToP2SHScriptPubkey(), [][]byte{{OP_0}}, flags, BaseSignatureChecker{}); err != nil {
+		t.Errorf("VerifyScript of P2SH error %v, except nil", err)
+	}
+
+	// OP_CHECKSIGADD is only defined in tapscript
+	if err := EvalScript(new(Stack), parse("OP_0 OP_0 OP_1 OP_CHECKSIGADD"), SigVersionWitnessV0, flags, BaseSignatureChecker{}); err != ErrBadOPCode {
+		t.Errorf("EvalScript error %v, except %v", err, ErrBadOPCode)
+	}
+}
+
+func TestTapscriptValidationWeight(t *testing.T) {
+	const flags = ScriptVeryP2SH | ScriptVerifyWitness | ScriptVerifyTaproot
+
+	// each signature costs 50 of the budget, the witness size + 50. The
+	// public key 0x01 is of an unknown type, its signatures are valid.
+	tapscript, _ := ParseAsm("OP_DUP OP_1 OP_CHECKSIGVERIFY OP_DUP OP_1 OP_CHECKSIGVERIFY")
+	pkScript, control := taprootOutput(TaprootLeafTapscript, tapscript, nil)
+
+	// 1 + 2 + 7 + 34 + 50 < 100
+	witness := [][]byte{{1}, tapscript, control}
+	if err := VerifyScript(nil, pkScript, witness, flags, BaseSignatureChecker{}); err != ErrTapscriptValidationWeight {
+		t.Errorf("VerifyScript error %v, except %v", err, ErrTapscriptValidationWeight)
+	}
+	witness = [][]byte{bytes.Repeat([]byte{1}, 10), tapscript, control}
+	if err := VerifyScript(nil, pkScript, witness, flags, BaseSignatureChecker{}); err != nil {
+		t.Errorf("VerifyScript of larger witness error %v, except nil", err)
+	}
+}
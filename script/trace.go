@@ -0,0 +1,86 @@
+package script
+
+import (
+	"encoding/hex"
+	"encoding/json"
+)
+
+// TraceStep is the state of the interpreter after an opcode, the stack items
+// are in hex with the top item last.
+type TraceStep struct {
+	// PC is the index of the opcode in the script.
+	PC int    `json:"pc"`
+	Op string `json:"op"`
+	// Executed is false for the opcodes in a not executed IF branch.
+	Executed bool     `json:"executed"`
+	Stack    []string `json:"stack"`
+	AltStack []string `json:"altstack"`
+	VfExec   []bool   `json:"vfexec"`
+	OpCount  int      `json:"opcount"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// ScriptTrace is the steps of an evaluated script.
+type ScriptTrace struct {
+	// Name is the role of the script, like scriptSig or redeemScript.
+	Name  string      `json:"name"`
+	Asm   string      `json:"asm"`
+	Steps []TraceStep `json:"steps"`
+}
+
+// Trace is the execution trace of the scripts evaluated for an input.
+type Trace struct {
+	Scripts []*ScriptTrace `json:"scripts"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// JSON returns the indented JSON of the trace.
+func (t *Trace) JSON() ([]byte, error) {
+	return json.MarshalIndent(t, "", "  ")
+}
+
+// State returns the state of the interpreter after the last step.
+func (vm *Interpreter) State() TraceStep {
+	step := TraceStep{
+		PC:       vm.opPC,
+		Op:       vm.script[vm.opPC:vm.pc].Disasm(),
+		Executed: vm.isExec,
+		Stack:    hexItems(vm.stack.d),
+		AltStack: hexItems(vm.altstack.d),
+		VfExec:   append([]bool{}, vm.vfExec...),
+		OpCount:  vm.opCount,
+	}
+	if vm.err != nil {
+		step.Error = vm.err.Error()
+	}
+	return step
+}
+
+func hexItems(items [][]byte) []string {
+	result := make([]string, len(items))
+	for i, item := range items {
+		result[i] = hex.EncodeToString(item)
+	}
+	return result
+}
+
+// TraceScript evaluates the script as EvalScript and returns its trace.
+func TraceScript(stack *Stack, script Script, sigVersion, flags int, checker SignatureChecker) (*Trace, error) {
+	trace := new(Trace)
+	err := evalScript(stack, script, sigVersion, flags, checker, newExecData(), trace, "script")
+	if err != nil {
+		trace.Error = err.Error()
+	}
+	return trace, err
+}
+
+// TraceVerifyScript verifies the input as VerifyScript and returns the trace
+// of the scriptSig, scriptPubKey, redeemScript and witnessScript evaluated.
+func TraceVerifyScript(scriptSig, scriptPubKey Script, witness [][]byte, flags int, checker SignatureChecker) (*Trace, error) {
+	trace := new(Trace)
+	err := verifyScript(scriptSig, scriptPubKey, witness, flags, checker, trace)
+	if err != nil {
+		trace.Error = err.Error()
+	}
+	return trace, err
+}
@@ -0,0 +1,104 @@
+package script
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestInterpreterStep(t *testing.T) {
+	s, _ := ParseAsm("OP_1 OP_IF OP_2 OP_TOALTSTACK OP_ELSE OP_3 OP_ENDIF OP_FROMALTSTACK")
+	tests := []TraceStep{
+		{0, "OP_1", true, []string{"01"}, []string{}, []bool{}, 0, ""},
+		{1, "OP_IF", true, []string{}, []string{}, []bool{true}, 1, ""},
+		{2, "OP_2", true, []string{"02"}, []string{}, []bool{true}, 1, ""},
+		{3, "OP_TOALTSTACK", true, []string{}, []string{"02"}, []bool{true}, 2, ""},
+		{4, "OP_ELSE", true, []string{}, []string{"02"}, []bool{false}, 3, ""},
+		{5, "OP_3", false, []string{}, []string{"02"}, []bool{false}, 3, ""},
+		{6, "OP_ENDIF", false, []string{}, []string{"02"}, []bool{}, 4, ""},
+		{7, "OP_FROMALTSTACK", true, []string{"02"}, []string{}, []bool{}, 5, ""},
+	}
+
+	vm, err := NewInterpreter(new(Stack), s, SigVersionBase, ScriptVerifyNone, BaseSignatureChecker{})
+	if err != nil {
+		t.Fatalf("NewInterpreter error %v", err)
+	}
+	for i, test := range tests {
+		if vm.Done() {
+			t.Fatalf("#%d Done, except more steps", i)
+		}
+		if err := vm.Step(); err != nil {
+			t.Fatalf("#%d Step error %v", i, err)
+		}
+		if state := vm.State(); !reflect.DeepEqual(state, test) {
+			t.Errorf("#%d State %+v, except %+v", i, state, test)
+		}
+	}
+	if !vm.Done() || vm.Err() != nil {
+		t.Errorf("Done %v error %v, except finished", vm.Done(), vm.Err())
+	}
+
+	// the last step fails for the IF not closed, the later steps do nothing.
+	s, _ = ParseAsm("OP_1 OP_IF")
+	vm, _ = NewInterpreter(new(Stack), s, SigVersionBase, ScriptVerifyNone, BaseSignatureChecker{})
+	vm.Step()
+	if err := vm.Step(); err != ErrUnbalancedConditional {
+		t.Errorf("Step error %v, except %v", err, ErrUnbalancedConditional)
+	}
+	if err := vm.Step(); err != ErrUnbalancedConditional || !vm.Done() {
+		t.Errorf("Step after done error %v, except %v", err, ErrUnbalancedConditional)
+	}
+	if state := vm.State(); state.PC != 1 || state.Error != ErrUnbalancedConditional.Error() {
+		t.Errorf("State %+v, except the error of OP_IF", state)
+	}
+
+	if _, err := NewInterpreter(new(Stack), make(Script, MaxScriptSize+1), SigVersionBase, ScriptVerifyNone, nil); err != ErrScriptSize {
+		t.Errorf("NewInterpreter error %v, except %v", err, ErrScriptSize)
+	}
+}
+
+func TestTraceVerifyScript(t *testing.T) {
+	redeemScript, _ := ParseAsm("OP_2 OP_EQUAL")
+	pkScript := redeemScript.ToP2SHScriptPubkey()
+
+	var scriptSig Script
+	scriptSig.AddOpCode(OP_2)
+	scriptSig.PushData(redeemScript)
+	trace, err := TraceVerifyScript(scriptSig, pkScript, nil, StandardScriptVerifyFlags, BaseSignatureChecker{})
+	if err != nil || trace.Error != "" {
+		t.Fatalf("TraceVerifyScript error %v %s", err, trace.Error)
+	}
+	var names []string
+	for _, st := range trace.Scripts {
+		names = append(names, st.Name)
+	}
+	if except := []string{"scriptSig", "scriptPubKey", "redeemScript"}; !reflect.DeepEqual(names, except) {
+		t.Errorf("scripts %v, except %v", names, except)
+	}
+	if st := trace.Scripts[2]; st.Asm != "OP_2 OP_EQUAL" || len(st.Steps) != 2 || !reflect.DeepEqual(st.Steps[1].Stack, []string{"01"}) {
+		t.Errorf("redeemScript trace %+v", st)
+	}
+
+	data, err := trace.JSON()
+	if err != nil {
+		t.Fatalf("JSON error %v", err)
+	}
+	decoded := new(Trace)
+	if err := json.Unmarshal(data, decoded); err != nil || !reflect.DeepEqual(decoded, trace) {
+		t.Errorf("JSON %s, error %v", data, err)
+	}
+
+	// the failed step is the last one traced
+	scriptSig = Script{OP_3}
+	scriptSig.PushData(redeemScript)
+	trace, err = TraceVerifyScript(scriptSig, pkScript, nil, StandardScriptVerifyFlags, BaseSignatureChecker{})
+	if err != ErrEvalFalse || trace.Error != ErrEvalFalse.Error() || len(trace.Scripts) != 3 {
+		t.Errorf("TraceVerifyScript error %v, trace %+v, except %v", err, trace, ErrEvalFalse)
+	}
+
+	trace, err = TraceScript(new(Stack), Script{OP_1, OP_VERIFY, OP_VERIFY}, SigVersionBase, ScriptVerifyNone, BaseSignatureChecker{})
+	steps := trace.Scripts[0].Steps
+	if err != ErrInvalidStackOperation || len(steps) != 3 || steps[2].Error != err.Error() {
+		t.Errorf("TraceScript error %v, steps %+v", err, steps)
+	}
+}
@@ -0,0 +1,179 @@
+package script
+
+import (
+	"bytes"
+
+	"github.com/maiiz/coinlib/crypto"
+)
+
+// VerifyScript verifies that the scriptSig and witness of an input satisfy
+// the scriptPubKey it spends. The scriptSig is evaluated first and its
+// stack is passed to the scriptPubKey, then the redeem script of a P2SH
+// output is evaluated with ScriptVeryP2SH and the witness program with
+// ScriptVerifyWitness.
+func VerifyScript(scriptSig, scriptPubKey Script, witness [][]byte, flags int, checker SignatureChecker) error {
+	return verifyScript(scriptSig, scriptPubKey, witness, flags, checker, nil)
+}
+
+// verifyScript verifies the input as VerifyScript, the evaluated scripts are
+// recorded in the trace if it is not nil.
+func verifyScript(scriptSig, scriptPubKey Script, witness [][]byte, flags int, checker SignatureChecker, trace *Trace) error {
+	if (flags&ScriptVerivySigPushOnly) != 0 && !scriptSig.IsPushOnly() {
+		return ErrSigPushOnly
+	}
+
+	stack := new(Stack)
+	if err := evalScript(stack, scriptSig, SigVersionBase, flags, checker, newExecData(), trace, "scriptSig"); err != nil {
+		return err
+	}
+	var stackCopy Stack
+	if (flags & ScriptVeryP2SH) != 0 {
+		stackCopy.d = append(stackCopy.d, stack.d...)
+	}
+	if err := evalScript(stack, scriptPubKey, SigVersionBase, flags, checker, newExecData(), trace, "scriptPubKey"); err != nil {
+		return err
+	}
+	if stack.Size() == 0 || !CastToBool(stack.top(-1)) {
+		return ErrEvalFalse
+	}
+
+	// Bare witness programs
+	hadWitness := false
+	if (flags & ScriptVerifyWitness) != 0 {
+		if version, program, ok := scriptPubKey.WitnessProgram(); ok {
+			hadWitness = true
+			if len(scriptSig) != 0 {
+				// The scriptSig must be _exactly_ CScript(), otherwise we reintroduce malleability.
+				return ErrWitnessMalleated
+			}
+			if err := verifyWitnessProgram(witness, version, program, false, flags, checker, trace); err != nil {
+				return err
+			}
+			// Bypass the cleanstack check at the end. The actual stack is obviously not clean
+			// for witness programs.
+			stack.d = stack.d[:1]
+		}
+	}
+
+	// Additional validation for spend-to-script-hash transactions:
+	if (flags&ScriptVeryP2SH) != 0 && scriptPubKey.IsP2SH() {
+		// scriptSig must be literals-only or validation fails
+		if !scriptSig.IsPushOnly() {
+			return ErrSigPushOnly
+		}
+
+		// Restore stack, the scriptSig left the serialized redeem script on its top.
+		stack = &stackCopy
+		redeemScript := Script(stack.pop())
+
+		if err := evalScript(stack, redeemScript, SigVersionBase, flags, checker, newExecData(), trace, "redeemScript"); err != nil {
+			return err
+		}
+		if stack.Size() == 0 || !CastToBool(stack.top(-1)) {
+			return ErrEvalFalse
+		}
+
+		// P2SH witness program
+		if (flags & ScriptVerifyWitness) != 0 {
+			if version, program, ok := redeemScript.WitnessProgram(); ok {
+				hadWitness = true
+				var pushed Script
+				pushed.PushData(redeemScript)
+				if !bytes.Equal(scriptSig, pushed) {
+					// The scriptSig must be _exactly_ a single push of the redeemScript. Otherwise we
+					// reintroduce malleability.
+					return ErrWitnessMalleatedP2SH
+				}
+				if err := verifyWitnessProgram(witness, version, program, true, flags, checker, trace); err != nil {
+					return err
+				}
+				stack.d = stack.d[:1]
+			}
+		}
+	}
+
+	// The CLEANSTACK check is only performed after potential P2SH evaluation,
+	// as the non-P2SH evaluation of a P2SH script will obviously not result in
+	// a clean stack (the P2SH inputs remain). The same holds for witness evaluation.
+	if (flags&ScriptVerifyCleanStack) != 0 && stack.Size() != 1 {
+		return ErrCleanStack
+	}
+
+	// We can't check for correct unexpected witness data if P2SH was off, so require
+	// that WITNESS implies P2SH. Otherwise, going from WITNESS->P2SH+WITNESS would be
+	// possible, which is not a softfork.
+	if (flags&ScriptVerifyWitness) != 0 && !hadWitness && len(witness) != 0 {
+		return ErrWitnessUnexpected
+	}
+	return nil
+}
+
+// verifyWitnessProgram evaluates the witness of a version 0 program or a
+// taproot output, the programs of unknown versions are left for future
+// softforks. A P2SH wrapped version 1 program is not taproot.
+func verifyWitnessProgram(witness [][]byte, version int, program []byte, isP2SH bool, flags int, checker SignatureChecker, trace *Trace) error {
+	var (
+		stack        = new(Stack)
+		scriptPubKey Script
+	)
+	if version == 1 && len(program) == 32 && !isP2SH {
+		if (flags & ScriptVerifyTaproot) == 0 {
+			// Taproot is not active, the output is anyone-can-spend
+			return nil
+		}
+		return verifyTaproot(witness, program, flags, checker, trace)
+	}
+	if version != 0 {
+		if (flags & ScriptVerifyDiscourageUpgradableWitnessProgram) != 0 {
+			return ErrDiscourageUpgradableWitnessProgram
+		}
+		// Higher version witness scripts return true for future softfork compatibility
+		return nil
+	}
+
+	switch len(program) {
+	case 32:
+		// P2WSH, the last item of the witness is the witness script.
+		if len(witness) == 0 {
+			return ErrWitnessProgramWitnessEmpty
+		}
+		scriptPubKey = witness[len(witness)-1]
+		stack.d = append(stack.d, witness[:len(witness)-1]...)
+		if h := crypto.Sha256(scriptPubKey); !bytes.Equal(h[:], program) {
+			return ErrWitnessProgramMimatch
+		}
+
+	case 20:
+		// P2WPKH, the witness is the signature and the public key.
+		if len(witness) != 2 {
+			return ErrWitnessProgramMimatch
+		}
+		scriptPubKey = P2PKHScript(program)
+		stack.d = append(stack.d, witness...)
+
+	default:
+		return ErrWitnessProgramWrongLength
+	}
+	return executeWitnessScript(stack, scriptPubKey, SigVersionWitnessV0, flags, checker, newExecData(), trace, "witnessScript")
+}
+
+// executeWitnessScript evaluates the witness script or tapscript on the stack
+// of the witness, which must end with a single true item.
+func executeWitnessScript(stack *Stack, s Script, sigVersion, flags int, checker SignatureChecker, execData *ExecData, trace *Trace, name string) error {
+	// Disallow stack item size > MAX_SCRIPT_ELEMENT_SIZE in witness stack
+	for _, elem := range stack.d {
+		if len(elem) > MaxScriptElementSize {
+			return ErrorPushSize
+		}
+	}
+
+	if err := evalScript(stack, s, sigVersion, flags, checker, execData, trace, name); err != nil {
+		return err
+	}
+
+	// Scripts inside witness implicitly require cleanstack behaviour
+	if stack.Size() != 1 || !CastToBool(stack.top(-1)) {
+		return ErrEvalFalse
+	}
+	return nil
+}
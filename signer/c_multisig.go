@@ -0,0 +1,266 @@
+package signer
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/maiiz/coinlib/crypto"
+	"github.com/maiiz/coinlib/keystore"
+	"github.com/maiiz/coinlib/script"
+	"github.com/maiiz/coinlib/types"
+	"github.com/maiiz/coinlib/utils"
+)
+
+var (
+	ErrWitnessScript = errors.New("witness script not match scriptPubkey")
+	ErrTxNotMatch    = errors.New("transactions spend different outputs")
+)
+
+// multisigInput is an input spending a CHECKMULTISIG script by P2SH, P2WSH or
+// P2SH-P2WSH.
+//
+// A partially signed input holds a slot for each public key in the order of
+// the script, with an empty item for the missing signatures:
+//
+//	P2SH:  scriptSig 0 <sig or 0>... <redeemScript>
+//	P2WSH: witness   "" <sig or "">... <witnessScript>
+//
+// The slots are replaced with the first m signatures once the input has
+// enough of them.
+type multisigInput struct {
+	script  script.Script
+	m       int
+	pubkeys [][]byte
+	witness bool
+	// prefix is the scriptSig of a P2SH-P2WSH input.
+	prefix script.Script
+}
+
+// isMultisig reports whether the prevout is a multisig output.
+func isMultisig(prev *PrevOut) bool {
+	if prev.ScriptPubkey.IsP2WSH() || len(prev.WitnessScript) > 0 {
+		return true
+	}
+	_, _, ok := script.ParseMultisigScript(prev.RedeemScript)
+	return ok && prev.ScriptPubkey.IsP2SH()
+}
+
+func newMultisigInput(prev *PrevOut) (*multisigInput, error) {
+	var (
+		pkScript = prev.ScriptPubkey
+		in       = new(multisigInput)
+	)
+	switch {
+	case pkScript.IsP2WSH():
+		h := crypto.Sha256(prev.WitnessScript)
+		if !bytes.Equal(h[:], pkScript[2:34]) {
+			return nil, ErrWitnessScript
+		}
+		in.script, in.witness = prev.WitnessScript, true
+
+	case pkScript.IsP2SH() && len(prev.WitnessScript) > 0:
+		program := prev.WitnessScript.ToP2WSHScriptPubkey()
+		if !bytes.Equal(crypto.Hash160(program), pkScript[2:22]) {
+			return nil, ErrWitnessScript
+		}
+		in.script, in.witness = prev.WitnessScript, true
+		in.prefix.PushData(program)
+
+	case pkScript.IsP2SH():
+		if !bytes.Equal(crypto.Hash160(prev.RedeemScript), pkScript[2:22]) {
+			return nil, ErrRedeemScript
+		}
+		in.script = prev.RedeemScript
+
+	default:
+		return nil, ErrUnsupportedScript
+	}
+
+	var ok bool
+	if in.m, in.pubkeys, ok = script.ParseMultisigScript(in.script); !ok {
+		return nil, ErrUnsupportedScript
+	}
+	return in, nil
+}
+
+// slots returns the signatures of the input in the order of the public keys,
+// complete is true if the input is already final.
+func (in *multisigInput) slots(ti *types.TxIn) (slots [][]byte, complete bool) {
+	var items [][]byte
+	if in.witness {
+		items = ti.Witness
+	} else {
+		for i := 0; i < len(ti.ScriptSig); {
+			opCode, data, next, ok := ti.ScriptSig.GetOp(i)
+			if !ok || opCode > script.OP_PUSHDATA4 {
+				items = nil
+				break
+			}
+			items = append(items, data)
+			i = next
+		}
+	}
+
+	slots = make([][]byte, len(in.pubkeys))
+	// the dummy item of CHECKMULTISIG and the script
+	if len(items) < 2 || !bytes.Equal(items[len(items)-1], in.script) {
+		return slots, false
+	}
+	sigs := items[1 : len(items)-1]
+	switch len(sigs) {
+	case len(in.pubkeys):
+		copy(slots, sigs)
+		return slots, in.count(slots) >= in.m
+	case in.m:
+		return slots, true
+	}
+	return slots, false
+}
+
+func (in *multisigInput) count(slots [][]byte) int {
+	n := 0
+	for _, sig := range slots {
+		if len(sig) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// write sets the scriptSig or witness of the input with the signatures.
+func (in *multisigInput) write(ti *types.TxIn, slots [][]byte) {
+	sigs := slots
+	if in.count(slots) >= in.m {
+		sigs = make([][]byte, 0, in.m)
+		for _, sig := range slots {
+			if len(sig) > 0 && len(sigs) < in.m {
+				sigs = append(sigs, sig)
+			}
+		}
+	}
+
+	if in.witness {
+		witness := types.TxWitness{{}}
+		witness = append(witness, sigs...)
+		ti.ScriptSig, ti.Witness = in.prefix, append(witness, in.script)
+		return
+	}
+
+	scriptSig := script.Script{script.OP_0}
+	for _, sig := range sigs {
+		scriptSig.PushData(sig)
+	}
+	scriptSig.PushData(in.script)
+	ti.ScriptSig, ti.Witness = scriptSig, nil
+}
+
+// signMultisig adds the signatures of the keys of ks in the multisig script
+// to the input.
+func signMultisig(tx *types.Transaction, idx int, prev *PrevOut, cache *types.SigHashCache, auth string, ks *keystore.KeyStore) error {
+	in, err := newMultisigInput(prev)
+	if err != nil {
+		return err
+	}
+	ti := tx.Vin[idx]
+	slots, complete := in.slots(ti)
+	if complete {
+		if in.count(slots) > 0 {
+			in.write(ti, slots)
+		}
+		return nil
+	}
+
+	sigVersion := script.SigVersionBase
+	if in.witness {
+		sigVersion = script.SigVersionWitnessV0
+	}
+
+	found := false
+	for i, pub := range in.pubkeys {
+		addr := utils.BytesToAddress(crypto.Hash160(pub))
+		if _, err := ks.KeyInfo(addr); err != nil {
+			continue
+		}
+		found = true
+		if len(slots[i]) > 0 {
+			continue
+		}
+
+		priv, _, err := signingKey(addr[:], !in.witness, auth, ks)
+		if err != nil {
+			return err
+		}
+		sig, err := signHash(priv, cache, idx, in.script, prev.Amount, sigVersion, script.SigHashAll)
+		utils.ZeroMemory(priv.D.Bits())
+		if err != nil {
+			return err
+		}
+		slots[i] = sig
+	}
+	if !found {
+		return keystore.ErrKeyNotFind
+	}
+
+	in.write(ti, slots)
+	return nil
+}
+
+// CombineMultisig merges the signatures of the multisig inputs of the copies
+// of tx partially signed by the cosigners into tx, the inputs with enough
+// signatures are finalized. Other inputs take the first non-empty scriptSig
+// and witness.
+func CombineMultisig(tx *types.Transaction, prevOuts []*PrevOut, others ...*types.Transaction) error {
+	if len(prevOuts) != len(tx.Vin) {
+		return ErrPrevOuts
+	}
+	for _, other := range others {
+		if len(other.Vin) != len(tx.Vin) {
+			return ErrTxNotMatch
+		}
+		for i, ti := range other.Vin {
+			if *ti.Prevout != *tx.Vin[i].Prevout {
+				return ErrTxNotMatch
+			}
+		}
+	}
+
+	for i, prev := range prevOuts {
+		ti := tx.Vin[i]
+		if !isMultisig(prev) {
+			for _, other := range others {
+				if len(ti.ScriptSig) == 0 && len(ti.Witness) == 0 {
+					ti.ScriptSig, ti.Witness = other.Vin[i].ScriptSig, other.Vin[i].Witness
+				}
+			}
+			continue
+		}
+
+		in, err := newMultisigInput(prev)
+		if err != nil {
+			return err
+		}
+		slots, complete := in.slots(ti)
+		for _, other := range others {
+			if complete {
+				break
+			}
+			otherSlots, otherComplete := in.slots(other.Vin[i])
+			if otherComplete && in.count(otherSlots) < in.m {
+				// final input of the other copy
+				ti.ScriptSig, ti.Witness = other.Vin[i].ScriptSig, other.Vin[i].Witness
+				slots, complete = in.slots(ti)
+				break
+			}
+			for j, sig := range otherSlots {
+				if len(slots[j]) == 0 {
+					slots[j] = sig
+				}
+			}
+			complete = in.count(slots) >= in.m
+		}
+		if in.count(slots) > 0 {
+			in.write(ti, slots)
+		}
+	}
+	return nil
+}
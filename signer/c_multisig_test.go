@@ -0,0 +1,143 @@
+package signer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/maiiz/coinlib/keystore"
+	"github.com/maiiz/coinlib/script"
+	"github.com/maiiz/coinlib/types"
+	"github.com/maiiz/coinlib/utils"
+)
+
+const (
+	// the cosigners of the 2-of-3 script besides testWIF.
+	cosignerWIF2 = "KwntMbt59tTsj8xqpqYqRRWufyjGunvhSyeMo3NTYpFYzZbXJ5Hp"
+	cosignerWIF3 = "KxN4XYdzu6f9j3EMryaMwZvUVLk3y29M4QZ2xwPoFP2zwka1aWxU"
+
+	testMultisig = "522102466d7fcae563e5cb09a0d1870bb580344804617879a14949cf22285f1bae3f272102d0de0aaeaefad02b8bdc8a01a1b8b11c696bd3d66a2c5f10780d95b7df42645c21034f355bdcb7cc0af728ef3cceb9615d90684bb5b2ca5f859ab0f0b704075871aa53ae"
+
+	unsignedMultisigTx = "02000000039c827201b94019b42f85706bc49c59ff84b5604d11caafb90ab94856c4e1dd7a0000000000ffffffff92a9cee8d181100da0604847187508328ef3a768612ec0d0dcd4ca2314b45d2d0100000000ffffffff0eac589aa6ef7f5232a21b36ddac0b586b707acebdeac6082e10a9a9f80860da0200000000ffffffff0190d003000000000017a914db9463443be7bf9bb04d2a27f0c8e5710829ddd58700000000"
+	// signed by testWIF and cosignerWIF2 and verified by btcd.
+	signedMultisigTx = "020000000001039c827201b94019b42f85706bc49c59ff84b5604d11caafb90ab94856c4e1dd7a00000000fdfe0000483045022100c1fd19fb73775e18d7490c08ad6b2f233770da7b626e483d813ae3e2461b23e6022014def75ffd7e17d649255f7d2a72f0a622c49bcb92adfd2336bad5df8f195e2501483045022100e6883098c300f4a936a7310a7db2c4607bfaf2b64ced60b0519d19d602a3e2b002201bcb18b11023af1b5cb7d92404fad2989a3444c77f98f4a6b712bdc9d6e5600a014c69522102466d7fcae563e5cb09a0d1870bb580344804617879a14949cf22285f1bae3f272102d0de0aaeaefad02b8bdc8a01a1b8b11c696bd3d66a2c5f10780d95b7df42645c21034f355bdcb7cc0af728ef3cceb9615d90684bb5b2ca5f859ab0f0b704075871aa53aeffffffff92a9cee8d181100da0604847187508328ef3a768612ec0d0dcd4ca2314b45d2d0100000000ffffffff0eac589aa6ef7f5232a21b36ddac0b586b707acebdeac6082e10a9a9f80860da0200000023220020a6cac52c2f646efa5e7cf2aea0fb80c1c595113b8a845b0b5ac07a38bd243ef8ffffffff0190d003000000000017a914db9463443be7bf9bb04d2a27f0c8e5710829ddd58700040047304402200a821f23ca4ada2ca2c7502ff9b51fda9465831e3bbf9734e22134d4c0e1254002205d3895e59a4e4dd98ec24bde170faaa3ca71b97e342c69ac956d0bc275abf03f0147304402203254b862c74fc070d4f3d76317724e090897d1c270159e62ef8f884f12b69be202200928f0a7117176514f77f67d57dbcbf1fb068482d5897682c8c120e8c2296e6d0169522102466d7fcae563e5cb09a0d1870bb580344804617879a14949cf22285f1bae3f272102d0de0aaeaefad02b8bdc8a01a1b8b11c696bd3d66a2c5f10780d95b7df42645c21034f355bdcb7cc0af728ef3cceb9615d90684bb5b2ca5f859ab0f0b704075871aa53ae040047304402207086d15fc2add1d250050ac39fe08ee8e829ad846276f0651aeb7e9ee98699dc022039405fec20787be71e84add00a2b531342fcf0a42ea1186cb007df34e2e4064901483045022100fae7288cb2ad91a8dad8ac7db4abc05b66a607a4bcec89a7c973eadcea51265302203a00a6f0e116f89a28e29b34dcc26be013de7961c9bc4ad421c82d22e0e7b6bc0169522102466d7fcae563e5cb09a0d1870bb580344804617879a14949cf22285f1bae3f272102d0de0aaeaefad02b8bdc8a01a1b8b11c696bd3d66a2c5f10780d95b7df42645c21034f355bdcb7cc0af728ef3cceb9615d90684bb5b2ca5f859ab0f0b704075871aa53ae00000000"
+)
+
+func testMultisigPrevOuts() []*PrevOut {
+	ms := utils.HexToBytes(testMultisig)
+	return []*PrevOut{
+		{ScriptPubkey: utils.HexToBytes("a914db9463443be7bf9bb04d2a27f0c8e5710829ddd587"), Amount: 100000, RedeemScript: ms},
+		{ScriptPubkey: utils.HexToBytes("0020a6cac52c2f646efa5e7cf2aea0fb80c1c595113b8a845b0b5ac07a38bd243ef8"), Amount: 110000, WitnessScript: ms},
+		{ScriptPubkey: utils.HexToBytes("a914e9b143dfd6c7702fba5f6c3ecb67cd5848500fa687"), Amount: 120000, WitnessScript: ms},
+	}
+}
+
+func txHex(tx *types.Transaction) string {
+	buf := new(bytes.Buffer)
+	tx.Marshal(buf)
+	return utils.BytesToHex(buf.Bytes())
+}
+
+func TestSignMultisig(t *testing.T) {
+	ks1, cleanup1 := testKeyStore(t, testWIF)
+	defer cleanup1()
+	ks2, cleanup2 := testKeyStore(t, cosignerWIF2)
+	defer cleanup2()
+
+	// the cosigners sign in turn.
+	partial, err := CSignTxWithPassphrase(unsignedMultisigTx, testMultisigPrevOuts(), testAuth, ks1)
+	if err != nil {
+		t.Fatalf("CSignTxWithPassphrase of cosigner 1 error %v", err)
+	}
+	if partial == signedMultisigTx {
+		t.Fatalf("partially signed transaction is final")
+	}
+	signed, err := CSignTxWithPassphrase(partial, testMultisigPrevOuts(), testAuth, ks2)
+	if err != nil {
+		t.Fatalf("CSignTxWithPassphrase of cosigner 2 error %v", err)
+	}
+	if signed != signedMultisigTx {
+		t.Errorf("CSignTxWithPassphrase = %s, except %s", signed, signedMultisigTx)
+	}
+
+	// signing a final transaction changes nothing.
+	again, err := CSignTxWithPassphrase(signed, testMultisigPrevOuts(), testAuth, ks1)
+	if err != nil || again != signedMultisigTx {
+		t.Errorf("CSignTxWithPassphrase of final transaction = %s, err %v", again, err)
+	}
+}
+
+func TestCombineMultisig(t *testing.T) {
+	ks1, cleanup1 := testKeyStore(t, testWIF)
+	defer cleanup1()
+	ks2, cleanup2 := testKeyStore(t, cosignerWIF2)
+	defer cleanup2()
+
+	var txs []*types.Transaction
+	for _, ks := range []*keystore.KeyStore{ks2, ks1} {
+		tx := new(types.Transaction)
+		tx.Unmarshal(bytes.NewReader(utils.HexToBytes(unsignedMultisigTx)))
+		if err := CSignTx(tx, testMultisigPrevOuts(), testAuth, ks); err != nil {
+			t.Fatalf("CSignTx error %v", err)
+		}
+		txs = append(txs, tx)
+	}
+
+	if err := CombineMultisig(txs[0], testMultisigPrevOuts(), txs[1]); err != nil {
+		t.Fatalf("CombineMultisig error %v", err)
+	}
+	if signed := txHex(txs[0]); signed != signedMultisigTx {
+		t.Errorf("CombineMultisig = %s, except %s", signed, signedMultisigTx)
+	}
+
+	other := new(types.Transaction)
+	other.Unmarshal(bytes.NewReader(utils.HexToBytes(unsignedTx)))
+	if err := CombineMultisig(txs[0], testMultisigPrevOuts(), other); err != ErrTxNotMatch {
+		t.Errorf("CombineMultisig of another transaction error %v, except %v", err, ErrTxNotMatch)
+	}
+}
+
+func TestSignMultisigInvalid(t *testing.T) {
+	ks, cleanup := testKeyStore(t, testWIF)
+	defer cleanup()
+
+	tx := new(types.Transaction)
+	tx.Unmarshal(bytes.NewReader(utils.HexToBytes(unsignedMultisigTx)))
+
+	prevOuts := testMultisigPrevOuts()
+	prevOuts[1].WitnessScript = prevOuts[1].WitnessScript[1:]
+	if err := CSignTx(tx, prevOuts, testAuth, ks); err != ErrWitnessScript {
+		t.Errorf("CSignTx with wrong witness script error %v, except %v", err, ErrWitnessScript)
+	}
+
+	// 1-of-1 of the key of cosignerWIF3
+	ms := script.Script(utils.HexToBytes("512102466d7fcae563e5cb09a0d1870bb580344804617879a14949cf22285f1bae3f2751ae"))
+	prevOuts = testMultisigPrevOuts()
+	prevOuts[1].ScriptPubkey, prevOuts[1].WitnessScript = ms.ToP2WSHScriptPubkey(), ms
+	if err := CSignTx(tx, prevOuts, testAuth, ks); err != keystore.ErrKeyNotFind {
+		t.Errorf("CSignTx without cosigner key error %v, except %v", err, keystore.ErrKeyNotFind)
+	}
+}
+
+func TestVerifySignedTx(t *testing.T) {
+	tests := []struct {
+		tx       string
+		prevOuts []*PrevOut
+	}{
+		{signedTx, testPrevOuts()},
+		{signedMultisigTx, testMultisigPrevOuts()},
+	}
+	for i, test := range tests {
+		tx := new(types.Transaction)
+		tx.Unmarshal(bytes.NewReader(utils.HexToBytes(test.tx)))
+		for j, prev := range test.prevOuts {
+			if err := types.VerifyInput(tx, j, prev.ScriptPubkey, prev.Amount, script.StandardScriptVerifyFlags); err != nil {
+				t.Errorf("#%d VerifyInput %d error %v", i, j, err)
+			}
+			// the witness signatures commit to the amount.
+			err := types.VerifyInput(tx, j, prev.ScriptPubkey, prev.Amount+1, script.StandardScriptVerifyFlags)
+			if len(tx.Vin[j].Witness) > 0 && err == nil {
+				t.Errorf("#%d VerifyInput %d of wrong amount error nil", i, j)
+			}
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package signer
+
+import (
+	"errors"
+
+	"github.com/maiiz/coinlib/crypto"
+	"github.com/maiiz/coinlib/keystore"
+	"github.com/maiiz/coinlib/psbt"
+	"github.com/maiiz/coinlib/script"
+	"github.com/maiiz/coinlib/types"
+	"github.com/maiiz/coinlib/utils"
+)
+
+var (
+	ErrSigHashType       = errors.New("invalid sighash type")
+	ErrSigHashNotAllowed = errors.New("sighash type not allowed")
+)
+
+// CSignPSBT adds the signatures of the keys of ks to the inputs of the psbt
+// spending P2PKH, P2WPKH, P2SH-P2WPKH or multisig outputs with SIGHASH_ALL.
+// It fails with ErrSigHashNotAllowed if an input requests another sighash
+// type, see CSignPSBTAllowSigHashes. The inputs without key of ks, final or
+// of unknown scripts are skipped, it fails with keystore.ErrKeyNotFind if no
+// input is signed.
+func CSignPSBT(p *psbt.Packet, auth string, ks *keystore.KeyStore) error {
+	return CSignPSBTAllowSigHashes(p, nil, auth, ks)
+}
+
+// CSignPSBTAllowSigHashes signs the psbt as CSignPSBT, the inputs may also
+// request the sighash types of allowed, e.g. SIGHASH_SINGLE|ANYONECANPAY.
+// The signatures of these types commit to part of the transaction only.
+func CSignPSBTAllowSigHashes(p *psbt.Packet, allowed []uint32, auth string, ks *keystore.KeyStore) error {
+	cache := types.NewSigHashCache(p.UnsignedTx)
+	signed := false
+	for i := range p.Inputs {
+		ok, err := signPSBTInput(p, i, cache, allowed, auth, ks)
+		if err != nil {
+			return err
+		}
+		signed = signed || ok
+	}
+	if !signed {
+		return keystore.ErrKeyNotFind
+	}
+	return nil
+}
+
+// CSignPSBTWithPassphrase signs the base64 psbt and returns the signed psbt
+// in base64.
+func CSignPSBTWithPassphrase(b64 string, auth string, ks *keystore.KeyStore) (string, error) {
+	p, err := psbt.ParseBase64(b64)
+	if err != nil {
+		return "decode psbt error", err
+	}
+	if err := CSignPSBT(p, auth, ks); err != nil {
+		return "", err
+	}
+	return p.Base64(), nil
+}
+
+func signPSBTInput(p *psbt.Packet, idx int, cache *types.SigHashCache, allowed []uint32, auth string, ks *keystore.KeyStore) (bool, error) {
+	in := p.Inputs[idx]
+	if in.IsFinal() {
+		return false, nil
+	}
+	utxo, err := p.Utxo(idx)
+	if err != nil {
+		return false, err
+	}
+	hashType := in.SigHashType
+	if hashType == 0 {
+		hashType = script.SigHashAll
+	}
+	if err := checkSigHashType(hashType, allowed); err != nil {
+		return false, err
+	}
+
+	prev := &PrevOut{
+		ScriptPubkey:  utxo.ScriptPubkey,
+		Amount:        utxo.Value,
+		RedeemScript:  in.RedeemScript,
+		WitnessScript: in.WitnessScript,
+	}
+	pkScript := prev.ScriptPubkey
+	if pkScript.IsP2WSH() && len(in.WitnessScript) == 0 {
+		return false, nil
+	}
+	if isMultisig(prev) {
+		return signPSBTMultisig(p, idx, prev, cache, hashType, auth, ks)
+	}
+
+	var (
+		hash       []byte
+		scriptCode script.Script
+		sigVersion = script.SigVersionWitnessV0
+	)
+	switch {
+	case pkScript.IsP2PKH():
+		hash, scriptCode, sigVersion = pkScript[3:23], pkScript, script.SigVersionBase
+
+	case pkScript.IsP2WPKH():
+		hash = pkScript[2:22]
+		scriptCode = script.P2PKHScript(hash)
+
+	case pkScript.IsP2SH():
+		redeemScript, err := nestedWitnessProgram(pkScript[2:22], in.RedeemScript, ks)
+		if err == keystore.ErrKeyNotFind {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if err := p.AddInRedeemScript(idx, redeemScript); err != nil {
+			return false, err
+		}
+		hash = redeemScript[2:22]
+		scriptCode = script.P2PKHScript(hash)
+
+	default:
+		return false, nil
+	}
+
+	if _, err := ks.KeyInfo(utils.BytesToAddress(hash)); err != nil {
+		return false, nil
+	}
+	priv, pub, err := signingKey(hash, sigVersion == script.SigVersionBase, auth, ks)
+	if err != nil {
+		return false, err
+	}
+	defer utils.ZeroMemory(priv.D.Bits())
+
+	sig, err := signHash(priv, cache, idx, scriptCode, prev.Amount, sigVersion, hashType)
+	if err != nil {
+		return false, err
+	}
+	return true, p.AddPartialSig(idx, pub, sig)
+}
+
+// signPSBTMultisig adds the signatures of the keys of ks in the multisig
+// script to the input.
+func signPSBTMultisig(p *psbt.Packet, idx int, prev *PrevOut, cache *types.SigHashCache, hashType uint32, auth string, ks *keystore.KeyStore) (bool, error) {
+	in, err := newMultisigInput(prev)
+	if err != nil {
+		return false, err
+	}
+	sigVersion := script.SigVersionBase
+	if in.witness {
+		sigVersion = script.SigVersionWitnessV0
+	}
+	// the witness program of a p2sh-p2wsh input for the finalizer
+	if in.witness && prev.ScriptPubkey.IsP2SH() {
+		if err := p.AddInRedeemScript(idx, prev.WitnessScript.ToP2WSHScriptPubkey()); err != nil {
+			return false, err
+		}
+	}
+
+	signed := false
+	for _, pub := range in.pubkeys {
+		addr := utils.BytesToAddress(crypto.Hash160(pub))
+		if _, err := ks.KeyInfo(addr); err != nil {
+			continue
+		}
+
+		priv, _, err := signingKey(addr[:], !in.witness, auth, ks)
+		if err != nil {
+			return false, err
+		}
+		sig, err := signHash(priv, cache, idx, in.script, prev.Amount, sigVersion, hashType)
+		utils.ZeroMemory(priv.D.Bits())
+		if err != nil {
+			return false, err
+		}
+		if err := p.AddPartialSig(idx, pub, sig); err != nil {
+			return false, err
+		}
+		signed = true
+	}
+	return signed, nil
+}
+
+// checkSigHashType checks the sighash type requested by an input is defined
+// and is SIGHASH_ALL or one of allowed. The signatures end with the type as
+// a single byte.
+func checkSigHashType(hashType uint32, allowed []uint32) error {
+	switch hashType &^ script.SighashAnyOneCanPay {
+	case script.SigHashAll, script.SigHashNone, script.SigHashSingle:
+	default:
+		return ErrSigHashType
+	}
+	if hashType == script.SigHashAll {
+		return nil
+	}
+	for _, t := range allowed {
+		if t == hashType {
+			return nil
+		}
+	}
+	return ErrSigHashNotAllowed
+}
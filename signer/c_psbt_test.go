@@ -0,0 +1,135 @@
+package signer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/maiiz/coinlib/keystore"
+	"github.com/maiiz/coinlib/psbt"
+	"github.com/maiiz/coinlib/script"
+	"github.com/maiiz/coinlib/types"
+	"github.com/maiiz/coinlib/utils"
+)
+
+// testPsbt returns the psbt of the hex transaction with the witness utxos and
+// scripts of prevOuts.
+func testPsbt(t *testing.T, txHex string, prevOuts []*PrevOut) *psbt.Packet {
+	tx := new(types.Transaction)
+	tx.Unmarshal(bytes.NewReader(utils.HexToBytes(txHex)))
+	p, err := psbt.New(tx)
+	if err != nil {
+		t.Fatalf("psbt.New error %v", err)
+	}
+	for i, prev := range prevOuts {
+		p.AddInWitnessUtxo(i, types.NewTxOut(prev.ScriptPubkey, prev.Amount))
+		if prev.RedeemScript != nil {
+			if err := p.AddInRedeemScript(i, prev.RedeemScript); err != nil {
+				t.Fatalf("AddInRedeemScript error %v", err)
+			}
+		}
+		if prev.WitnessScript != nil {
+			if err := p.AddInWitnessScript(i, prev.WitnessScript); err != nil {
+				t.Fatalf("AddInWitnessScript error %v", err)
+			}
+		}
+	}
+	return p
+}
+
+func TestCSignPSBT(t *testing.T) {
+	ks, cleanup := testKeyStore(t, testWIF)
+	defer cleanup()
+
+	p := testPsbt(t, unsignedTx, testPrevOuts())
+	signed, err := CSignPSBTWithPassphrase(p.Base64(), testAuth, ks)
+	if err != nil {
+		t.Fatalf("CSignPSBTWithPassphrase error %v", err)
+	}
+	if p, err = psbt.ParseBase64(signed); err != nil {
+		t.Fatalf("ParseBase64 error %v", err)
+	}
+	if err := p.Finalize(); err != nil {
+		t.Fatalf("Finalize error %v", err)
+	}
+	tx, err := p.Extract()
+	if err != nil {
+		t.Fatalf("Extract error %v", err)
+	}
+	if signed := txHex(tx); signed != signedTx {
+		t.Errorf("CSignPSBT = %s, except %s", signed, signedTx)
+	}
+
+	// no input of the keys of another wallet.
+	other, cleanup2 := testKeyStore(t, cosignerWIF2)
+	defer cleanup2()
+	p = testPsbt(t, unsignedTx, testPrevOuts())
+	if err := CSignPSBT(p, testAuth, other); err != keystore.ErrKeyNotFind {
+		t.Errorf("CSignPSBT of another wallet error %v, except %v", err, keystore.ErrKeyNotFind)
+	}
+}
+
+func TestCSignPSBTSigHashType(t *testing.T) {
+	ks, cleanup := testKeyStore(t, testWIF)
+	defer cleanup()
+
+	p := testPsbt(t, unsignedTx, testPrevOuts())
+	p.Inputs[0].SigHashType = script.SigHashNone
+	if err := CSignPSBT(p, testAuth, ks); err != ErrSigHashNotAllowed {
+		t.Errorf("CSignPSBT of SIGHASH_NONE error %v, except %v", err, ErrSigHashNotAllowed)
+	}
+	if err := CSignPSBTAllowSigHashes(p, []uint32{script.SigHashNone}, testAuth, ks); err != nil {
+		t.Fatalf("CSignPSBTAllowSigHashes error %v", err)
+	}
+	if len(p.Inputs[0].PartialSigs) == 0 {
+		t.Fatal("CSignPSBTAllowSigHashes signed no key of the input")
+	}
+	for _, sig := range p.Inputs[0].PartialSigs {
+		if hashType := sig.Signature[len(sig.Signature)-1]; hashType != script.SigHashNone {
+			t.Errorf("signature sighash type %d, except %d", hashType, script.SigHashNone)
+		}
+	}
+
+	// the type is appended to the signatures as a byte.
+	for _, hashType := range []uint32{0x101, 0x04} {
+		p = testPsbt(t, unsignedTx, testPrevOuts())
+		p.Inputs[0].SigHashType = hashType
+		if err := CSignPSBTAllowSigHashes(p, []uint32{hashType}, testAuth, ks); err != ErrSigHashType {
+			t.Errorf("CSignPSBT of sighash type %#x error %v, except %v", hashType, err, ErrSigHashType)
+		}
+	}
+}
+
+func TestCSignPSBTMultisig(t *testing.T) {
+	ks1, cleanup1 := testKeyStore(t, testWIF)
+	defer cleanup1()
+	ks2, cleanup2 := testKeyStore(t, cosignerWIF2)
+	defer cleanup2()
+
+	// the cosigners sign copies of the psbt, which are combined.
+	var packets []*psbt.Packet
+	for _, ks := range []*keystore.KeyStore{ks1, ks2} {
+		p := testPsbt(t, unsignedMultisigTx, testMultisigPrevOuts())
+		if err := CSignPSBT(p, testAuth, ks); err != nil {
+			t.Fatalf("CSignPSBT error %v", err)
+		}
+		if err := p.Finalize(); err != psbt.ErrMissingSigs {
+			t.Errorf("Finalize with one signature error %v, except %v", err, psbt.ErrMissingSigs)
+		}
+		packets = append(packets, p)
+	}
+
+	p := packets[0]
+	if err := p.Combine(packets[1]); err != nil {
+		t.Fatalf("Combine error %v", err)
+	}
+	if err := p.Finalize(); err != nil {
+		t.Fatalf("Finalize error %v", err)
+	}
+	tx, err := p.Extract()
+	if err != nil {
+		t.Fatalf("Extract error %v", err)
+	}
+	if signed := txHex(tx); signed != signedMultisigTx {
+		t.Errorf("CSignPSBT = %s, except %s", signed, signedMultisigTx)
+	}
+}
@@ -1,6 +1,247 @@
 package signer
 
-// CSign 
-// func CSign() {
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
 
-// }
+	"github.com/maiiz/coinlib/crypto"
+	"github.com/maiiz/coinlib/crypto/secp256k1"
+	"github.com/maiiz/coinlib/keystore"
+	"github.com/maiiz/coinlib/script"
+	"github.com/maiiz/coinlib/types"
+	"github.com/maiiz/coinlib/utils"
+)
+
+var (
+	ErrPrevOuts          = errors.New("prevouts not match inputs")
+	ErrUnsupportedScript = errors.New("unsupported scriptPubkey")
+	ErrRedeemScript      = errors.New("redeem script not match scriptPubkey")
+	ErrKeyNotMatch       = errors.New("key not match scriptPubkey")
+)
+
+// PrevOut is the output spent by a transaction input.
+type PrevOut struct {
+	ScriptPubkey script.Script
+	Amount       int64
+	// RedeemScript is the witness program of a P2SH-P2WPKH output, which is
+	// searched in the keystore if empty, or the multisig script of a P2SH
+	// output.
+	RedeemScript script.Script
+	// WitnessScript is the multisig script of a P2WSH or P2SH-P2WSH output.
+	WitnessScript script.Script
+	// InternalKey is the x-only internal key of a P2TR output without
+	// scripts (BIP86), the output key is tweaked from it.
+	InternalKey []byte
+}
+
+// CSignTx signs the inputs of the bitcoin transaction spending P2PKH,
+// P2SH-P2WPKH or P2WPKH outputs with SIGHASH_ALL and P2TR outputs by the key
+// path with SIGHASH_DEFAULT, prevOuts are the outputs spent by the inputs in
+// order. Multisig inputs are signed with the keys of ks in the script, see
+// CombineMultisig. auth is not used while ks is unlocked.
+func CSignTx(tx *types.Transaction, prevOuts []*PrevOut, auth string, ks *keystore.KeyStore) error {
+	if len(prevOuts) != len(tx.Vin) {
+		return ErrPrevOuts
+	}
+
+	cache := types.NewSigHashCache(tx)
+	// the taproot signatures commit to all the spent outputs
+	spent := make([]*types.TxOut, len(prevOuts))
+	for i, prev := range prevOuts {
+		spent[i] = types.NewTxOut(prev.ScriptPubkey, prev.Amount)
+	}
+	if err := cache.SetPrevOuts(spent); err != nil {
+		return err
+	}
+	for i, prev := range prevOuts {
+		if err := signInput(tx, i, prev, cache, auth, ks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CSignTxWithPassphrase signs the hex bitcoin transaction and returns the
+// signed transaction in hex.
+func CSignTxWithPassphrase(txHex string, prevOuts []*PrevOut, auth string, ks *keystore.KeyStore) (string, error) {
+	tx := new(types.Transaction)
+	if err := tx.Unmarshal(bytes.NewReader(utils.HexToBytes(txHex))); err != nil {
+		return "decode transaction error", err
+	}
+	if err := CSignTx(tx, prevOuts, auth, ks); err != nil {
+		return "", err
+	}
+
+	buf := new(bytes.Buffer)
+	tx.Marshal(buf)
+	return utils.BytesToHex(buf.Bytes()), nil
+}
+
+func signInput(tx *types.Transaction, idx int, prev *PrevOut, cache *types.SigHashCache, auth string, ks *keystore.KeyStore) error {
+	ti := tx.Vin[idx]
+	pkScript := prev.ScriptPubkey
+
+	if isMultisig(prev) {
+		return signMultisig(tx, idx, prev, cache, auth, ks)
+	}
+
+	switch {
+	case pkScript.IsP2PKH():
+		hash := pkScript[3:23]
+		priv, pub, err := signingKey(hash, true, auth, ks)
+		if err != nil {
+			return err
+		}
+		defer utils.ZeroMemory(priv.D.Bits())
+
+		sig, err := signHash(priv, cache, idx, pkScript, prev.Amount, script.SigVersionBase, script.SigHashAll)
+		if err != nil {
+			return err
+		}
+		var scriptSig script.Script
+		scriptSig.PushData(sig)
+		scriptSig.PushData(pub)
+		ti.ScriptSig, ti.Witness = scriptSig, nil
+
+	case pkScript.IsP2WPKH():
+		witness, err := signWitnessV0(pkScript[2:22], cache, idx, prev.Amount, auth, ks)
+		if err != nil {
+			return err
+		}
+		ti.ScriptSig, ti.Witness = nil, witness
+
+	case pkScript.IsP2SH():
+		redeemScript, err := nestedWitnessProgram(pkScript[2:22], prev.RedeemScript, ks)
+		if err != nil {
+			return err
+		}
+		witness, err := signWitnessV0(redeemScript[2:22], cache, idx, prev.Amount, auth, ks)
+		if err != nil {
+			return err
+		}
+		var scriptSig script.Script
+		scriptSig.PushData(redeemScript)
+		ti.ScriptSig, ti.Witness = scriptSig, witness
+
+	case pkScript.IsP2TR():
+		witness, err := signTaproot(pkScript[2:], prev.InternalKey, cache, idx, auth, ks)
+		if err != nil {
+			return err
+		}
+		ti.ScriptSig, ti.Witness = nil, witness
+
+	default:
+		return ErrUnsupportedScript
+	}
+	return nil
+}
+
+// signWitnessV0 returns the witness of a p2wpkh input: <sig> <pubkey>.
+func signWitnessV0(hash []byte, cache *types.SigHashCache, idx int, amount int64, auth string, ks *keystore.KeyStore) (types.TxWitness, error) {
+	priv, pub, err := signingKey(hash, false, auth, ks)
+	if err != nil {
+		return nil, err
+	}
+	defer utils.ZeroMemory(priv.D.Bits())
+
+	sig, err := signHash(priv, cache, idx, script.P2PKHScript(hash), amount, script.SigVersionWitnessV0, script.SigHashAll)
+	if err != nil {
+		return nil, err
+	}
+	return types.TxWitness{sig, pub}, nil
+}
+
+// signTaproot returns the witness of a taproot key path spend: <sig>, the
+// output key is tweaked from the internal key without scripts.
+func signTaproot(outputKey, internalKey []byte, cache *types.SigHashCache, idx int, auth string, ks *keystore.KeyStore) (types.TxWitness, error) {
+	if q, _, err := secp256k1.TweakTaprootPubkey(internalKey, nil); err != nil || !bytes.Equal(q, outputKey) {
+		return nil, ErrKeyNotMatch
+	}
+	priv, err := taprootSigningKey(internalKey, auth, ks)
+	if err != nil {
+		return nil, err
+	}
+	defer utils.ZeroMemory(priv.D.Bits())
+	tweaked, err := (*secp256k1.PrivateKey)(priv).TweakTaproot(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer utils.ZeroMemory(tweaked.D.Bits())
+
+	hash, err := cache.TaprootSignatureHash(idx, script.SigHashDefault, script.SigVersionTaproot, new(script.ExecData))
+	if err != nil {
+		return nil, err
+	}
+	sig, err := tweaked.SignSchnorr(hash[:], nil)
+	if err != nil {
+		return nil, err
+	}
+	return types.TxWitness{sig}, nil
+}
+
+// taprootSigningKey returns the key of the x-only public key, the keystore
+// finds the keys by the hash of the compressed public key of either parity.
+func taprootSigningKey(xonly []byte, auth string, ks *keystore.KeyStore) (*ecdsa.PrivateKey, error) {
+	var lastErr error
+	for _, prefix := range []byte{0x02, 0x03} {
+		hash := crypto.Hash160(append([]byte{prefix}, xonly...))
+		priv, err := privateKey(utils.BytesToAddress(hash), auth, ks)
+		if err == nil {
+			return priv, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// nestedWitnessProgram returns the p2wpkh redeem script of the p2sh script
+// hash, it is looked up in the keystore if not given.
+func nestedWitnessProgram(scriptHash []byte, redeemScript script.Script, ks *keystore.KeyStore) (script.Script, error) {
+	if len(redeemScript) > 0 {
+		if !redeemScript.IsP2WPKH() || !bytes.Equal(crypto.Hash160(redeemScript), scriptHash) {
+			return nil, ErrRedeemScript
+		}
+		return redeemScript, nil
+	}
+	for _, info := range ks.Keys() {
+		redeemScript = script.P2WPKHScript(info.Address[:])
+		if bytes.Equal(crypto.Hash160(redeemScript), scriptHash) {
+			return redeemScript, nil
+		}
+	}
+	return nil, keystore.ErrKeyNotFind
+}
+
+// signingKey returns the key of the pubkey hash and its serialized public key,
+// an uncompressed public key is only allowed out of segwit.
+func signingKey(hash []byte, allowUncompressed bool, auth string, ks *keystore.KeyStore) (*ecdsa.PrivateKey, []byte, error) {
+	priv, err := privateKey(utils.BytesToAddress(hash), auth, ks)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pub := (*secp256k1.PublicKey)(&priv.PublicKey)
+	if pubBytes := pub.CompressedBytes(); bytes.Equal(crypto.Hash160(pubBytes), hash) {
+		return priv, pubBytes, nil
+	}
+	if pubBytes := pub.Bytes(); allowUncompressed && bytes.Equal(crypto.Hash160(pubBytes), hash) {
+		return priv, pubBytes, nil
+	}
+	utils.ZeroMemory(priv.D.Bits())
+	return nil, nil, ErrKeyNotMatch
+}
+
+// signHash returns the low-S DER signature of the input with the sighash type
+// appended.
+func signHash(priv *ecdsa.PrivateKey, cache *types.SigHashCache, idx int, scriptCode script.Script, amount int64, sigVersion int, hashType uint32) ([]byte, error) {
+	hash, err := cache.SignatureHash(idx, scriptCode, hashType, amount, sigVersion)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := (*secp256k1.PrivateKey)(priv).Sign(hash[:])
+	if err != nil {
+		return nil, err
+	}
+	return append(sig.(*secp256k1.Signature).DER(), byte(hashType)), nil
+}
@@ -0,0 +1,176 @@
+package signer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maiiz/coinlib/crypto/secp256k1"
+	"github.com/maiiz/coinlib/keystore"
+	"github.com/maiiz/coinlib/params"
+	"github.com/maiiz/coinlib/script"
+	"github.com/maiiz/coinlib/types"
+	"github.com/maiiz/coinlib/utils"
+)
+
+const (
+	testAuth = "test"
+	// the key 0c28fca386c7a227600b2fe50b7cae11ec86d3bf1fbe471be89827e19d72aa1d
+	testWIF = "KwdMAjGmerYanjeui5SHS7JkmpZvVipYvB2LJGU1ZxJwYvP98617"
+
+	testP2PKH  = "76a914d9351dcbad5b8f3b8bfa2f2cdc85c28118ca932688ac"
+	testP2WPKH = "0014d9351dcbad5b8f3b8bfa2f2cdc85c28118ca9326"
+	testP2SH   = "a9147db6766dce18d816eaac1198391e8bdcf70b253a87"
+
+	unsignedTx = "02000000031406e05881e299367766d313e26c05564ec91bf721d31726bd6e46e60689539a0000000000ffffffff9c12cfdc04c74584d787ac3d23772132c18524bc7ab28dec4219b8fc5b425f700100000000ffffffff1cc3adea40ebfd94433ac004777d68150cce9db4c771bc7de1b297a7b795bbba0200000000ffffffff0190d00300000000001976a914d9351dcbad5b8f3b8bfa2f2cdc85c28118ca932688ac00000000"
+	// signed and verified by btcd.
+	signedTx = "020000000001031406e05881e299367766d313e26c05564ec91bf721d31726bd6e46e60689539a000000006a473044022062bb11b1dfb3f3e50d9ab228ae15ab196fe6064ce4c56ac2a62703c6a213428502206dedb6fef04a4fd764d7ed70594543fb08f15b69e3bd24da75b713fd8fa1b22b012102d0de0aaeaefad02b8bdc8a01a1b8b11c696bd3d66a2c5f10780d95b7df42645cffffffff9c12cfdc04c74584d787ac3d23772132c18524bc7ab28dec4219b8fc5b425f700100000000ffffffff1cc3adea40ebfd94433ac004777d68150cce9db4c771bc7de1b297a7b795bbba0200000017160014d9351dcbad5b8f3b8bfa2f2cdc85c28118ca9326ffffffff0190d00300000000001976a914d9351dcbad5b8f3b8bfa2f2cdc85c28118ca932688ac0002483045022100f79be4e4b0f4ac731ae1bd17bad1c1725bff817a677bb80ad1641bbede2192680220339ae92bbc10b845956db6c3ac71ebea9c2535ee3f92a9dbf01cd442f6ba8685012102d0de0aaeaefad02b8bdc8a01a1b8b11c696bd3d66a2c5f10780d95b7df42645c024730440220245b378bd8bf2d5ff3412eaccb5d314c10b8443b20a081dc3ee961d49552739902207972f938413da9a2a2dd1aa726e417a2e69fe2ea9ebe3fe2dc8c1036d71c7af1012102d0de0aaeaefad02b8bdc8a01a1b8b11c696bd3d66a2c5f10780d95b7df42645c00000000"
+)
+
+func testKeyStore(t *testing.T, wif string) (*keystore.KeyStore, func()) {
+	dir, err := ioutil.TempDir("", "signer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks := keystore.NewKeyStore(filepath.Join(dir, "wallet.dat"), params.GetChain(params.BTC))
+	if err := ks.GenerateKeys(1, testAuth); err != nil {
+		t.Fatalf("GenerateKeys error %v", err)
+	}
+	if _, err := ks.ImportWIF(wif, testAuth); err != nil {
+		t.Fatalf("ImportWIF error %v", err)
+	}
+	return ks, func() { os.RemoveAll(dir) }
+}
+
+func testPrevOuts() []*PrevOut {
+	return []*PrevOut{
+		{ScriptPubkey: utils.HexToBytes(testP2PKH), Amount: 100000},
+		{ScriptPubkey: utils.HexToBytes(testP2WPKH), Amount: 110000},
+		{ScriptPubkey: utils.HexToBytes(testP2SH), Amount: 120000},
+	}
+}
+
+func TestCSignTx(t *testing.T) {
+	ks, cleanup := testKeyStore(t, testWIF)
+	defer cleanup()
+
+	signed, err := CSignTxWithPassphrase(unsignedTx, testPrevOuts(), testAuth, ks)
+	if err != nil {
+		t.Fatalf("CSignTxWithPassphrase error %v", err)
+	}
+	if signed != signedTx {
+		t.Errorf("CSignTxWithPassphrase = %s, except %s", signed, signedTx)
+	}
+
+	// the redeem script may be given.
+	prevOuts := testPrevOuts()
+	prevOuts[2].RedeemScript = utils.HexToBytes(testP2WPKH)
+	tx := new(types.Transaction)
+	tx.Unmarshal(bytes.NewReader(utils.HexToBytes(unsignedTx)))
+	if err := CSignTx(tx, prevOuts, testAuth, ks); err != nil {
+		t.Fatalf("CSignTx with redeem script error %v", err)
+	}
+	buf := new(bytes.Buffer)
+	tx.Marshal(buf)
+	if utils.BytesToHex(buf.Bytes()) != signedTx {
+		t.Errorf("CSignTx with redeem script = %x", buf.Bytes())
+	}
+}
+
+func TestCSignTxUnlocked(t *testing.T) {
+	ks, cleanup := testKeyStore(t, testWIF)
+	defer cleanup()
+
+	// the unlocked wallet signs with the cached key, the passphrase is not
+	// needed to derive it again.
+	if err := ks.Unlock(testAuth, 0); err != nil {
+		t.Fatalf("Unlock error %v", err)
+	}
+	signed, err := CSignTxWithPassphrase(unsignedTx, testPrevOuts(), "", ks)
+	if err != nil {
+		t.Fatalf("CSignTxWithPassphrase of unlocked wallet error %v", err)
+	}
+	if signed != signedTx {
+		t.Errorf("CSignTxWithPassphrase of unlocked wallet = %s, except %s", signed, signedTx)
+	}
+
+	ks.Lock()
+	if _, err := CSignTxWithPassphrase(unsignedTx, testPrevOuts(), "", ks); err != keystore.ErrWrongPasspharse {
+		t.Errorf("CSignTxWithPassphrase of locked wallet error %v, except %v", err, keystore.ErrWrongPasspharse)
+	}
+}
+
+func TestCSignTxInvalid(t *testing.T) {
+	ks, cleanup := testKeyStore(t, testWIF)
+	defer cleanup()
+
+	tx := new(types.Transaction)
+	tx.Unmarshal(bytes.NewReader(utils.HexToBytes(unsignedTx)))
+
+	if err := CSignTx(tx, testPrevOuts()[:2], testAuth, ks); err != ErrPrevOuts {
+		t.Errorf("CSignTx with 2 prevouts error %v, except %v", err, ErrPrevOuts)
+	}
+
+	prevOuts := testPrevOuts()
+	prevOuts[2].RedeemScript = utils.HexToBytes(testP2PKH)
+	if err := CSignTx(tx, prevOuts, testAuth, ks); err != ErrRedeemScript {
+		t.Errorf("CSignTx with p2pkh redeem script error %v, except %v", err, ErrRedeemScript)
+	}
+
+	prevOuts = testPrevOuts()
+	prevOuts[1].ScriptPubkey = utils.HexToBytes("6a")
+	if err := CSignTx(tx, prevOuts, testAuth, ks); err != ErrUnsupportedScript {
+		t.Errorf("CSignTx of OP_RETURN error %v, except %v", err, ErrUnsupportedScript)
+	}
+
+	prevOuts = testPrevOuts()
+	prevOuts[1].ScriptPubkey = utils.HexToBytes("00140000000000000000000000000000000000000000")
+	if err := CSignTx(tx, prevOuts, testAuth, ks); err != keystore.ErrKeyNotFind {
+		t.Errorf("CSignTx of unknown key error %v, except %v", err, keystore.ErrKeyNotFind)
+	}
+
+	if err := CSignTx(tx, testPrevOuts(), "wrong", ks); err != keystore.ErrWrongPasspharse {
+		t.Errorf("CSignTx with wrong auth error %v, except %v", err, keystore.ErrWrongPasspharse)
+	}
+}
+
+func TestCSignTxTaproot(t *testing.T) {
+	ks, cleanup := testKeyStore(t, testWIF)
+	defer cleanup()
+
+	// the x-only public key of testWIF and the BIP86 output key of it
+	internalKey := utils.HexToBytes("d0de0aaeaefad02b8bdc8a01a1b8b11c696bd3d66a2c5f10780d95b7df42645c")
+	outputKey, _, _ := secp256k1.TweakTaprootPubkey(internalKey, nil)
+
+	tx := new(types.Transaction)
+	tx.Unmarshal(bytes.NewReader(utils.HexToBytes(unsignedTx)))
+	prevOuts := testPrevOuts()
+	prevOuts[1] = &PrevOut{ScriptPubkey: script.P2TRScript(outputKey), Amount: 110000, InternalKey: internalKey}
+	if err := CSignTx(tx, prevOuts, testAuth, ks); err != nil {
+		t.Fatalf("CSignTx error %v", err)
+	}
+	if w := tx.Vin[1].Witness; len(w) != 1 || len(w[0]) != 64 || len(tx.Vin[1].ScriptSig) != 0 {
+		t.Errorf("taproot witness %x, except a 64 bytes signature", w)
+	}
+
+	spent := make([]*types.TxOut, len(prevOuts))
+	for i, prev := range prevOuts {
+		spent[i] = types.NewTxOut(prev.ScriptPubkey, prev.Amount)
+	}
+	if err := types.VerifyTx(tx, spent, script.StandardScriptVerifyFlags); err != nil {
+		t.Errorf("VerifyTx error %v", err)
+	}
+
+	// the internal key is not of the output key
+	prevOuts[1].InternalKey = utils.HexToBytes("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798")
+	if err := CSignTx(tx, prevOuts, testAuth, ks); err != ErrKeyNotMatch {
+		t.Errorf("CSignTx of another internal key error %v, except %v", err, ErrKeyNotMatch)
+	}
+	outputKey, _, _ = secp256k1.TweakTaprootPubkey(prevOuts[1].InternalKey, nil)
+	prevOuts[1].ScriptPubkey = script.P2TRScript(outputKey)
+	if err := CSignTx(tx, prevOuts, testAuth, ks); err != keystore.ErrKeyNotFind {
+		t.Errorf("CSignTx of unknown key error %v, except %v", err, keystore.ErrKeyNotFind)
+	}
+}
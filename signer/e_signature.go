@@ -33,7 +33,7 @@ func ESignTxWithPassphrase(
 	}
 
 	if len(addresses) == 1 {
-		priv, err = ks.GetPrivkey(addresses[0], auth)
+		priv, err = privateKey(addresses[0], auth, ks)
 		if err != nil {
 			return "no privatekey", err
 		}
@@ -21,7 +21,7 @@ func SignWithPassphrase(addresses []utils.Address,
 		sig  crypto.Signature
 	)
 	if len(addresses) == 1 {
-		priv, err = ks.GetPrivkey(addresses[0], auth)
+		priv, err = privateKey(addresses[0], auth, ks)
 		if err != nil {
 			return "no privatekey", err
 		}
@@ -37,3 +37,13 @@ func SignWithPassphrase(addresses []utils.Address,
 
 	return utils.BytesToHex(sig.Bytes()), err
 }
+
+// privateKey returns the key of the address, the key cached by Unlock is used
+// while the wallet is unlocked, otherwise the key is decrypted with auth.
+func privateKey(addr utils.Address, auth string, ks *keystore.KeyStore) (*ecdsa.PrivateKey, error) {
+	priv, err := ks.GetUnlockedPrivkey(addr)
+	if err == keystore.ErrLocked {
+		return ks.GetPrivkey(addr, auth)
+	}
+	return priv, err
+}
@@ -0,0 +1,168 @@
+package types
+
+import (
+	"github.com/maiiz/coinlib/crypto/secp256k1"
+	"github.com/maiiz/coinlib/script"
+)
+
+// TxSignatureChecker checks the signatures of an input of a transaction
+// spending an output of amount, it implements script.SignatureChecker.
+type TxSignatureChecker struct {
+	cache  *SigHashCache
+	idx    int
+	amount int64
+}
+
+// NewTxSignatureChecker returns the signature checker of the input idx of tx,
+// the cache may be shared by the checkers of all inputs of tx or be nil.
+func NewTxSignatureChecker(tx *Transaction, idx int, amount int64, cache *SigHashCache) *TxSignatureChecker {
+	if cache == nil {
+		cache = NewSigHashCache(tx)
+	}
+	return &TxSignatureChecker{cache: cache, idx: idx, amount: amount}
+}
+
+// CheckSig reports whether the DER signature ending with the sighash type is
+// signed by the public key for the scriptCode of the input.
+func (c *TxSignatureChecker) CheckSig(sig, pubkey []byte, scriptCode script.Script, sigVersion int) bool {
+	pub, err := secp256k1.ParsePubkey(pubkey)
+	if err != nil || len(sig) == 0 {
+		return false
+	}
+
+	hashType := uint32(sig[len(sig)-1])
+	hash, err := c.cache.SignatureHash(c.idx, scriptCode, hashType, c.amount, sigVersion)
+	if err != nil {
+		return false
+	}
+	return pub.VerifyDER(hash[:], sig[:len(sig)-1])
+}
+
+// CheckSchnorrSig checks the BIP340 signature of the x-only public key for the
+// taproot spend of the input, a 65 bytes signature ends with a sighash type
+// other than SIGHASH_DEFAULT. The spent outputs must be set in the cache.
+func (c *TxSignatureChecker) CheckSchnorrSig(sig, pubkey []byte, sigVersion int, execData *script.ExecData) error {
+	var hashType uint32
+	switch len(sig) {
+	case 64:
+	case 65:
+		hashType = uint32(sig[64])
+		if hashType == 0 {
+			return script.ErrSchnorrSigHashType
+		}
+		sig = sig[:64]
+	default:
+		return script.ErrSchnorrSigSize
+	}
+
+	hash, err := c.cache.TaprootSignatureHash(c.idx, hashType, sigVersion, execData)
+	if err == ErrHashType {
+		return script.ErrSchnorrSigHashType
+	} else if err != nil {
+		return err
+	}
+	pub, err := secp256k1.ParseXOnlyPubkey(pubkey)
+	if err != nil || !pub.VerifySchnorr(hash[:], sig) {
+		return script.ErrSchnorrSig
+	}
+	return nil
+}
+
+// CheckLockTime reports whether the lock time of the transaction is of the
+// same kind, block height or time, as lockTime and not earlier (BIP65).
+func (c *TxSignatureChecker) CheckLockTime(lockTime script.BigNumber) bool {
+	tx := c.cache.tx
+	// There are two kinds of nLockTime: lock-by-blockheight
+	// and lock-by-blocktime, distinguished by whether
+	// nLockTime < LOCKTIME_THRESHOLD.
+	//
+	// We want to compare apples to apples, so fail the script
+	// unless the type of nLockTime being tested is the same as
+	// the nLockTime in the transaction.
+	if (tx.LockTime < script.LocktimeThreshold) != (lockTime < script.LocktimeThreshold) {
+		return false
+	}
+	// Now that we know we're comparing apples-to-apples, the
+	// comparison is a simple numeric one.
+	if int64(lockTime) > int64(tx.LockTime) {
+		return false
+	}
+	// Finally the nLockTime feature can be disabled and thus
+	// CHECKLOCKTIMEVERIFY bypassed if every txin has been
+	// finalized by setting nSequence to maxint. The
+	// transaction would be allowed into the blockchain, making
+	// the opcode ineffective.
+	return tx.Vin[c.idx].Sequence != SequenceFinal
+}
+
+// CheckSequence reports whether the relative lock time of the input is of
+// the same kind, blocks or time, as sequence and not shorter (BIP112).
+func (c *TxSignatureChecker) CheckSequence(sequence script.BigNumber) bool {
+	tx := c.cache.tx
+	txSequence := int64(tx.Vin[c.idx].Sequence)
+
+	// Fail if the transaction's version number is not set high
+	// enough to trigger BIP 68 rules.
+	if uint32(tx.Version) < 2 {
+		return false
+	}
+	// Sequence numbers with their most significant bit set are not
+	// consensus constrained. Testing that the transaction's sequence
+	// number do not have this bit set prevents using this property
+	// to get around a CHECKSEQUENCEVERIFY check.
+	if txSequence&SequenceLocktimeDisableFlag != 0 {
+		return false
+	}
+
+	// Mask off any bits that do not have consensus-enforced meaning
+	// before doing the integer comparisons
+	const lockTimeMask = SequnceLocktimeTypeFlag | SequenceLocktimeMask
+	txSequenceMasked := txSequence & lockTimeMask
+	sequenceMasked := int64(sequence) & lockTimeMask
+
+	// There are two kinds of nSequence: lock-by-blockheight
+	// and lock-by-blocktime, distinguished by whether
+	// nSequenceMasked < CTxIn::SEQUENCE_LOCKTIME_TYPE_FLAG.
+	if (txSequenceMasked < SequnceLocktimeTypeFlag) != (sequenceMasked < SequnceLocktimeTypeFlag) {
+		return false
+	}
+	return sequenceMasked <= txSequenceMasked
+}
+
+// VerifyInput verifies the scriptSig and witness of the input idx of tx
+// spending an output of amount locked by pkScript. The taproot inputs need
+// all the spent outputs, they are verified by VerifyTx.
+func VerifyInput(tx *Transaction, idx int, pkScript script.Script, amount int64, flags int) error {
+	if idx < 0 || idx >= len(tx.Vin) {
+		return ErrInputIndex
+	}
+	ti := tx.Vin[idx]
+	return script.VerifyScript(ti.ScriptSig, pkScript, ti.Witness, flags, NewTxSignatureChecker(tx, idx, amount, nil))
+}
+
+// TraceInput verifies the input idx of tx as VerifyInput and returns the
+// execution trace of its scripts.
+func TraceInput(tx *Transaction, idx int, pkScript script.Script, amount int64, flags int) (*script.Trace, error) {
+	if idx < 0 || idx >= len(tx.Vin) {
+		return nil, ErrInputIndex
+	}
+	ti := tx.Vin[idx]
+	return script.TraceVerifyScript(ti.ScriptSig, pkScript, ti.Witness, flags, NewTxSignatureChecker(tx, idx, amount, nil))
+}
+
+// VerifyTx verifies the scriptSig and witness of all inputs of tx, prevOuts
+// are the outputs spent by the inputs in order. It returns the error of the
+// first invalid input.
+func VerifyTx(tx *Transaction, prevOuts []*TxOut, flags int) error {
+	cache := NewSigHashCache(tx)
+	if err := cache.SetPrevOuts(prevOuts); err != nil {
+		return err
+	}
+	for i, ti := range tx.Vin {
+		checker := NewTxSignatureChecker(tx, i, prevOuts[i].Value, cache)
+		if err := script.VerifyScript(ti.ScriptSig, prevOuts[i].ScriptPubkey, ti.Witness, flags, checker); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package types
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/maiiz/coinlib/crypto"
+	"github.com/maiiz/coinlib/crypto/secp256k1"
+	"github.com/maiiz/coinlib/script"
+	"github.com/maiiz/coinlib/utils"
+)
+
+// checkerTx spends a P2PK, a bare 2-of-3 multisig signed with ALL and
+// SINGLE|ANYONECANPAY, and the P2WSH of the multisig signed with ALL and
+// NONE, each output is of checkerAmount.
+const (
+	checkerTx       = "020000000001033b2a1908f7e6d5c4b3a2c3f1e0e6c051cd4beec0c83f0ee30d4ed0c1a5a9f2a000000000494830450221008874d0b033de01686e160b7a7dbecedac01506eac7112db86558f50c9ef951de022051d4413c39c9edd46bf6a0473b1aac500fdf627608a5f14a4f5a3e42c070003a01ffffffff3b2a1908f7e6d5c4b3a2c3f1e0e6c051cd4beec0c83f0ee30d4ed0c1a5a9f2a001000000910047304402204aa37f4285f51c6d1510c382d65ee7c9bd1f395d043182b4fe06831e3dc24ae602205e923621f964ed76ac61b7c34b0f44184c42c6898c2a142f162a58279cf2b6e1014730440220439ed0d43275bd054609f968f550e9470fd6471b1db6e73c4e74fa944fd802c2022036c168b92976474420a00048323fab80ab12ad09f3bb5c8c59510c8935dd652483ffffffff3b2a1908f7e6d5c4b3a2c3f1e0e6c051cd4beec0c83f0ee30d4ed0c1a5a9f2a00200000000ffffffff0190d0030000000000232102d0de0aaeaefad02b8bdc8a01a1b8b11c696bd3d66a2c5f10780d95b7df42645cac00000400483045022100988d9a25048e64602d379ddca5b051a3864aff70ac9019c48486d61a434bc8dc02200c19e8dc2071fefbbe21550d0994bcdce0188f00442d08eb6a161e18423eacc8014830450221009a7a816f92667ef2e93805b76722d42f1e1ee108d6caceb1f21d581e254e66ae0220644a0258a88414d8d9ce9183d9c0e53b4001803f3ae568bc2dee5f16fa98ad170269522102d0de0aaeaefad02b8bdc8a01a1b8b11c696bd3d66a2c5f10780d95b7df42645c21034f355bdcb7cc0af728ef3cceb9615d90684bb5b2ca5f859ab0f0b704075871aa2102466d7fcae563e5cb09a0d1870bb580344804617879a14949cf22285f1bae3f2753ae00000000"
+	checkerP2PK     = "2102d0de0aaeaefad02b8bdc8a01a1b8b11c696bd3d66a2c5f10780d95b7df42645cac"
+	checkerMultisig = "522102d0de0aaeaefad02b8bdc8a01a1b8b11c696bd3d66a2c5f10780d95b7df42645c21034f355bdcb7cc0af728ef3cceb9615d90684bb5b2ca5f859ab0f0b704075871aa2102466d7fcae563e5cb09a0d1870bb580344804617879a14949cf22285f1bae3f2753ae"
+	checkerAmount   = 100000
+)
+
+const checkerFlags = script.ScriptVerifyStrictenc | script.ScriptVerifyDERSig | script.ScriptVerifyLowS |
+	script.ScriptVerifyNullDummy | script.ScriptVerifyNullFail | script.ScriptVerifyWitnessPubkeyType
+
+// evalInput evaluates the scriptSig and pkScript, or the witness script, of
+// the input idx and returns the final stack.
+func evalInput(tx *Transaction, idx int, pkScript script.Script, amount int64, flags int) (*script.Stack, error) {
+	var (
+		stack   = new(script.Stack)
+		checker = NewTxSignatureChecker(tx, idx, amount, nil)
+		ti      = tx.Vin[idx]
+	)
+	if len(ti.Witness) > 0 {
+		for _, item := range ti.Witness[:len(ti.Witness)-1] {
+			stack.Push(item)
+		}
+		return stack, script.EvalScript(stack, ti.Witness[len(ti.Witness)-1], script.SigVersionWitnessV0, flags, checker)
+	}
+	if err := script.EvalScript(stack, ti.ScriptSig, script.SigVersionBase, flags, checker); err != nil {
+		return stack, err
+	}
+	return stack, script.EvalScript(stack, pkScript, script.SigVersionBase, flags, checker)
+}
+
+func TestTxSignatureChecker(t *testing.T) {
+	tx := new(Transaction)
+	if err := tx.Unmarshal(bytes.NewReader(utils.HexToBytes(checkerTx))); err != nil {
+		t.Fatalf("Unmarshal error %v", err)
+	}
+	pkScripts := []script.Script{utils.HexToBytes(checkerP2PK), utils.HexToBytes(checkerMultisig), nil}
+
+	for i, pkScript := range pkScripts {
+		stack, err := evalInput(tx, i, pkScript, checkerAmount, checkerFlags)
+		if err != nil {
+			t.Errorf("#%d EvalScript error %v", i, err)
+			continue
+		}
+		if top, err := stack.Top(-1); stack.Size() != 1 || err != nil || !script.CastToBool(top) {
+			t.Errorf("#%d EvalScript failed, except true", i)
+		}
+	}
+
+	// the witness signatures commit to the amount.
+	stack, err := evalInput(tx, 2, nil, checkerAmount+1, script.ScriptVerifyNone)
+	if err != nil {
+		t.Errorf("EvalScript of wrong amount error %v", err)
+	} else if top, _ := stack.Top(-1); script.CastToBool(top) {
+		t.Errorf("EvalScript of wrong amount = true, except false")
+	}
+	if _, err := evalInput(tx, 2, nil, checkerAmount+1, checkerFlags); err != script.ErrSigNullFail {
+		t.Errorf("EvalScript of wrong amount error %v, except %v", err, script.ErrSigNullFail)
+	}
+
+	// the signature of the multisig of input 1 does not sign the P2PK.
+	stack = new(script.Stack)
+	stack.Push(tx.Vin[1].ScriptSig[2:73])
+	checker := NewTxSignatureChecker(tx, 1, 0, nil)
+	err = script.EvalScript(stack, pkScripts[0], script.SigVersionBase, script.ScriptVerifyNone, checker)
+	if top, _ := stack.Top(-1); err != nil || script.CastToBool(top) {
+		t.Errorf("EvalScript of other scriptCode = %v, except false", err)
+	}
+}
+
+func TestVerifyInput(t *testing.T) {
+	tx := new(Transaction)
+	if err := tx.Unmarshal(bytes.NewReader(utils.HexToBytes(checkerTx))); err != nil {
+		t.Fatalf("Unmarshal error %v", err)
+	}
+	multisig := script.Script(utils.HexToBytes(checkerMultisig))
+	pkScripts := []script.Script{utils.HexToBytes(checkerP2PK), multisig, multisig.ToP2WSHScriptPubkey()}
+	for i, pkScript := range pkScripts {
+		if err := VerifyInput(tx, i, pkScript, checkerAmount, script.StandardScriptVerifyFlags); err != nil {
+			t.Errorf("#%d VerifyInput error %v", i, err)
+		}
+	}
+
+	tests := []struct {
+		idx      int
+		pkScript script.Script
+		flags    int
+		err      error
+	}{
+		// the signature of input 0 does not sign input 1.
+		{1, pkScripts[0], script.ScriptVerifyNone, script.ErrEvalFalse},
+		{1, pkScripts[0], script.ScriptVerifyNullFail, script.ErrSigNullFail},
+		// the witness is not checked without the witness flag.
+		{0, pkScripts[0], script.ScriptVeryP2SH | script.ScriptVerifyWitness, nil},
+		{2, pkScripts[2], script.ScriptVeryP2SH, nil},
+		{2, pkScripts[0], script.ScriptVeryP2SH | script.ScriptVerifyWitness, script.ErrInvalidStackOperation},
+		{2, multisig.ToP2SHScriptPubkey(), script.ScriptVeryP2SH, script.ErrInvalidStackOperation},
+		// the witness does not match the program.
+		{2, script.P2WSHScript(bytes.Repeat([]byte{1}, 21)), script.ScriptVerifyWitness, script.ErrWitnessProgramWrongLength},
+		{2, script.P2WSHScript(bytes.Repeat([]byte{1}, 32)), script.ScriptVerifyWitness, script.ErrWitnessProgramMimatch},
+		{2, script.P2WPKHScript(bytes.Repeat([]byte{1}, 20)), script.ScriptVerifyWitness, script.ErrWitnessProgramMimatch},
+		{2, script.Script{script.OP_1, 2, 1, 1}, script.ScriptVerifyWitness, nil},
+		{2, script.Script{script.OP_1, 2, 1, 1}, script.ScriptVerifyWitness | script.ScriptVerifyDiscourageUpgradableWitnessProgram, script.ErrDiscourageUpgradableWitnessProgram},
+	}
+	for i, test := range tests {
+		if err := VerifyInput(tx, test.idx, test.pkScript, checkerAmount, test.flags); err != test.err {
+			t.Errorf("#%d VerifyInput error %v, except %v", i, err, test.err)
+		}
+	}
+
+	// a witness input must have an empty scriptSig.
+	tx.Vin[2].ScriptSig = script.Script{script.OP_1}
+	if err := VerifyInput(tx, 2, pkScripts[2], checkerAmount, script.StandardScriptVerifyFlags); err != script.ErrWitnessMalleated {
+		t.Errorf("VerifyInput of scriptSig error %v, except %v", err, script.ErrWitnessMalleated)
+	}
+	// an input with unexpected witness.
+	tx.Vin[0].Witness = TxWitness{{}}
+	if err := VerifyInput(tx, 0, pkScripts[0], checkerAmount, script.StandardScriptVerifyFlags); err != script.ErrWitnessUnexpected {
+		t.Errorf("VerifyInput of witness error %v, except %v", err, script.ErrWitnessUnexpected)
+	}
+	if err := VerifyInput(tx, 3, pkScripts[0], checkerAmount, script.StandardScriptVerifyFlags); err != ErrInputIndex {
+		t.Errorf("VerifyInput of index 3 error %v, except %v", err, ErrInputIndex)
+	}
+}
+
+func TestTraceInput(t *testing.T) {
+	tx := new(Transaction)
+	if err := tx.Unmarshal(bytes.NewReader(utils.HexToBytes(checkerTx))); err != nil {
+		t.Fatalf("Unmarshal error %v", err)
+	}
+	multisig := script.Script(utils.HexToBytes(checkerMultisig))
+	trace, err := TraceInput(tx, 2, multisig.ToP2WSHScriptPubkey(), checkerAmount, script.StandardScriptVerifyFlags)
+	if err != nil {
+		t.Fatalf("TraceInput error %v", err)
+	}
+	if len(trace.Scripts) != 3 || trace.Scripts[2].Name != "witnessScript" || trace.Scripts[2].Asm != multisig.Disasm() {
+		t.Fatalf("TraceInput scripts %+v, except the witness script last", trace.Scripts)
+	}
+	steps := trace.Scripts[2].Steps
+	if last := steps[len(steps)-1]; last.Op != "OP_CHECKMULTISIG" || !reflect.DeepEqual(last.Stack, []string{"01"}) {
+		t.Errorf("last step %+v, except CHECKMULTISIG succeeded", last)
+	}
+
+	// a wrong amount fails the signatures of the witness.
+	trace, err = TraceInput(tx, 2, multisig.ToP2WSHScriptPubkey(), checkerAmount+1, script.StandardScriptVerifyFlags)
+	if err != script.ErrSigNullFail || trace.Error != err.Error() {
+		t.Errorf("TraceInput error %v, except %v", err, script.ErrSigNullFail)
+	}
+	if _, err := TraceInput(tx, 3, nil, checkerAmount, script.StandardScriptVerifyFlags); err != ErrInputIndex {
+		t.Errorf("TraceInput error %v, except %v", err, ErrInputIndex)
+	}
+}
+
+// taprootTx returns a transaction spending a taproot output by the key path
+// and one by the script path of a 2-of-2 CHECKSIGADD tapscript, both have
+// the internal key of keys[0].
+func taprootTx(t *testing.T) (*Transaction, []*TxOut) {
+	var keys []*secp256k1.PrivateKey
+	for _, b := range []byte{1, 2, 3} {
+		keys = append(keys, secp256k1.ToECDSA(bytes.Repeat([]byte{b}, 32)))
+	}
+	xonly := func(k *secp256k1.PrivateKey) []byte { return (*secp256k1.PublicKey)(&k.PublicKey).XOnly() }
+	internalKey := xonly(keys[0])
+
+	keyPathKey, _, _ := secp256k1.TweakTaprootPubkey(internalKey, nil)
+	tapscript := script.Script{}
+	tapscript.PushData(xonly(keys[1]))
+	tapscript.AddOpCode(script.OP_CHECKSIG)
+	tapscript.PushData(xonly(keys[2]))
+	tapscript.AddOpCode(script.OP_CHECKSIGADD)
+	tapscript.AddOpCode(script.OP_2)
+	tapscript.AddOpCode(script.OP_NUMEQUAL)
+	leaf := script.TapleafHash(script.TaprootLeafTapscript, tapscript)
+	scriptPathKey, parity, _ := secp256k1.TweakTaprootPubkey(internalKey, leaf[:])
+
+	tx := &Transaction{Version: 2}
+	tx.AddTxIn(NewTxIn(crypto.Hash{1}, 0, nil))
+	tx.AddTxIn(NewTxIn(crypto.Hash{2}, 1, nil))
+	tx.AddTxOut(NewTxOut(script.P2TRScript(keyPathKey), 150000))
+	tx.AddTxOut(NewTxOut(script.P2TRScript(scriptPathKey), 100000))
+	prevOuts := []*TxOut{
+		NewTxOut(script.P2TRScript(keyPathKey), 100000),
+		NewTxOut(script.P2TRScript(scriptPathKey), 200000),
+	}
+
+	cache := NewSigHashCache(tx)
+	cache.SetPrevOuts(prevOuts)
+	sign := func(k *secp256k1.PrivateKey, idx int, hashType uint32, sigVersion int, execData *script.ExecData) []byte {
+		h, err := cache.TaprootSignatureHash(idx, hashType, sigVersion, execData)
+		if err != nil {
+			t.Fatalf("TaprootSignatureHash error %v", err)
+		}
+		sig, err := k.SignSchnorr(h[:], nil)
+		if err != nil {
+			t.Fatalf("SignSchnorr error %v", err)
+		}
+		if hashType != script.SigHashDefault {
+			sig = append(sig, byte(hashType))
+		}
+		return sig
+	}
+
+	tweaked, _ := keys[0].TweakTaproot(nil)
+	tx.Vin[0].Witness = TxWitness{sign(tweaked, 0, script.SigHashDefault, script.SigVersionTaproot, new(script.ExecData))}
+
+	execData := &script.ExecData{TapleafHash: leaf, CodeSeparatorPos: 0xffffffff}
+	control := append([]byte{script.TaprootLeafTapscript | parity}, internalKey...)
+	tx.Vin[1].Witness = TxWitness{
+		sign(keys[2], 1, script.SigHashSingle|script.SighashAnyOneCanPay, script.SigVersionTapscript, execData),
+		sign(keys[1], 1, script.SigHashAll, script.SigVersionTapscript, execData),
+		tapscript,
+		control,
+	}
+	return tx, prevOuts
+}
+
+func TestVerifyTx(t *testing.T) {
+	tx, prevOuts := taprootTx(t)
+	if err := VerifyTx(tx, prevOuts, script.StandardScriptVerifyFlags); err != nil {
+		t.Fatalf("VerifyTx error %v", err)
+	}
+
+	// the taproot signatures commit to all the spent outputs
+	prevOuts[1].Value++
+	if err := VerifyTx(tx, prevOuts, script.StandardScriptVerifyFlags); err != script.ErrSchnorrSig {
+		t.Errorf("VerifyTx of another amount error %v, except %v", err, script.ErrSchnorrSig)
+	}
+	prevOuts[1].Value--
+	if err := VerifyTx(tx, prevOuts[:1], script.StandardScriptVerifyFlags); err != ErrPrevOuts {
+		t.Errorf("VerifyTx of 1 prevout error %v, except %v", err, ErrPrevOuts)
+	}
+	if err := VerifyInput(tx, 0, prevOuts[0].ScriptPubkey, prevOuts[0].Value, script.StandardScriptVerifyFlags); err != ErrPrevOuts {
+		t.Errorf("VerifyInput error %v, except %v", err, ErrPrevOuts)
+	}
+
+	keySig := tx.Vin[0].Witness[0]
+	tests := []struct {
+		sig []byte
+		err error
+	}{
+		{append(keySig[:64:64], script.SigHashDefault), script.ErrSchnorrSigHashType},
+		{append(keySig[:64:64], 0x04), script.ErrSchnorrSigHashType},
+		{append(keySig[:64:64], script.SigHashAll), script.ErrSchnorrSig},
+		{keySig[:63], script.ErrSchnorrSigSize},
+	}
+	for i, test := range tests {
+		tx.Vin[0].Witness[0] = test.sig
+		if err := VerifyTx(tx, prevOuts, script.StandardScriptVerifyFlags); err != test.err {
+			t.Errorf("#%d VerifyTx error %v, except %v", i, err, test.err)
+		}
+	}
+	tx.Vin[0].Witness[0] = keySig
+
+	// an empty signature fails the 2-of-2 tapscript
+	tx.Vin[1].Witness[0] = nil
+	if err := VerifyTx(tx, prevOuts, script.StandardScriptVerifyFlags); err != script.ErrEvalFalse {
+		t.Errorf("VerifyTx of empty signature error %v, except %v", err, script.ErrEvalFalse)
+	}
+
+	// SIGHASH_SINGLE without the output of the input
+	tx.Vout = tx.Vout[:1]
+	cache := NewSigHashCache(tx)
+	cache.SetPrevOuts(prevOuts)
+	if _, err := cache.TaprootSignatureHash(1, script.SigHashSingle, script.SigVersionTaproot, new(script.ExecData)); err != ErrHashType {
+		t.Errorf("TaprootSignatureHash error %v, except %v", err, ErrHashType)
+	}
+}
@@ -0,0 +1,306 @@
+package types
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/maiiz/coinlib/crypto"
+	"github.com/maiiz/coinlib/encoding/varint"
+	"github.com/maiiz/coinlib/script"
+)
+
+const sigHashMask = 0x1f
+
+var (
+	ErrInputIndex = errors.New("input index out of range")
+	ErrSigVersion = errors.New("unknown signature version")
+	ErrPrevOuts   = errors.New("prevouts not match inputs")
+	ErrHashType   = errors.New("invalid sighash type")
+)
+
+// sigHashOne is the hash signed by SIGHASH_SINGLE without a matching output,
+// kept for compatibility with the original client.
+var sigHashOne = crypto.Hash{0x01}
+
+// SigHashCache holds the hashes of BIP143 and BIP341 shared by the inputs of
+// a transaction, so they are computed once when signing or verifying all
+// inputs.
+type SigHashCache struct {
+	tx           *Transaction
+	HashPrevouts crypto.Hash
+	HashSequence crypto.Hash
+	HashOutputs  crypto.Hash
+
+	// the single sha256 of BIP341, the hashes of BIP143 are their sha256.
+	shaPrevouts, shaSequences, shaOutputs crypto.Hash
+	// the outputs spent by the inputs, they are only set for taproot.
+	prevOuts                     []*TxOut
+	shaAmounts, shaScriptPubkeys crypto.Hash
+}
+
+// NewSigHashCache returns the signature hash cache of the transaction, the
+// transaction must not change its inputs or outputs while the cache is used.
+func NewSigHashCache(tx *Transaction) *SigHashCache {
+	c := &SigHashCache{tx: tx}
+
+	buf := new(bytes.Buffer)
+	for _, ti := range tx.Vin {
+		ti.Prevout.marshal(buf)
+	}
+	c.shaPrevouts = crypto.Sha256(buf.Bytes())
+	c.HashPrevouts = crypto.Sha256(c.shaPrevouts[:])
+
+	buf.Reset()
+	for _, ti := range tx.Vin {
+		binary.Write(buf, binary.LittleEndian, ti.Sequence)
+	}
+	c.shaSequences = crypto.Sha256(buf.Bytes())
+	c.HashSequence = crypto.Sha256(c.shaSequences[:])
+
+	buf.Reset()
+	for _, to := range tx.Vout {
+		to.Marshal(buf)
+	}
+	c.shaOutputs = crypto.Sha256(buf.Bytes())
+	c.HashOutputs = crypto.Sha256(c.shaOutputs[:])
+	return c
+}
+
+// SetPrevOuts sets the outputs spent by the inputs in order, the signatures
+// of taproot commit to all of them.
+func (c *SigHashCache) SetPrevOuts(prevOuts []*TxOut) error {
+	if len(prevOuts) != len(c.tx.Vin) {
+		return ErrPrevOuts
+	}
+	buf := new(bytes.Buffer)
+	for _, to := range prevOuts {
+		binary.Write(buf, binary.LittleEndian, to.Value)
+	}
+	c.shaAmounts = crypto.Sha256(buf.Bytes())
+
+	buf.Reset()
+	for _, to := range prevOuts {
+		to.ScriptPubkey.Marshal(buf)
+	}
+	c.shaScriptPubkeys = crypto.Sha256(buf.Bytes())
+	c.prevOuts = prevOuts
+	return nil
+}
+
+// SignatureHash returns the digest signed by the input idx of tx spending an
+// output of amount locked by scriptCode, see SigHashCache.SignatureHash.
+func SignatureHash(tx *Transaction, idx int, scriptCode script.Script, hashType uint32, amount int64, sigVersion int) (crypto.Hash, error) {
+	if sigVersion == script.SigVersionBase {
+		return legacySignatureHash(tx, idx, scriptCode, hashType)
+	}
+	return NewSigHashCache(tx).SignatureHash(idx, scriptCode, hashType, amount, sigVersion)
+}
+
+// SignatureHash returns the digest signed by the input idx of the transaction.
+// It is the original algorithm for SigVersionBase, which ignores amount, and
+// BIP143 for SigVersionWitnessV0.
+func (c *SigHashCache) SignatureHash(idx int, scriptCode script.Script, hashType uint32, amount int64, sigVersion int) (crypto.Hash, error) {
+	switch sigVersion {
+	case script.SigVersionBase:
+		return legacySignatureHash(c.tx, idx, scriptCode, hashType)
+	case script.SigVersionWitnessV0:
+		return c.witnessV0SignatureHash(idx, scriptCode, hashType, amount)
+	}
+	return crypto.Hash{}, ErrSigVersion
+}
+
+// legacySignatureHash serializes a copy of the transaction with scriptCode in
+// the signed input and empty scripts in the others, then modifies it by
+// hashType.
+func legacySignatureHash(tx *Transaction, idx int, scriptCode script.Script, hashType uint32) (crypto.Hash, error) {
+	if idx < 0 || idx >= len(tx.Vin) {
+		return crypto.Hash{}, ErrInputIndex
+	}
+	var (
+		anyoneCanPay = hashType&script.SighashAnyOneCanPay != 0
+		hashSingle   = hashType&sigHashMask == script.SigHashSingle
+		hashNone     = hashType&sigHashMask == script.SigHashNone
+	)
+	// the SIGHASH_SINGLE bug: the hash of one is signed if there is no
+	// output of the same index.
+	if hashSingle && idx >= len(tx.Vout) {
+		return sigHashOne, nil
+	}
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, tx.Version)
+
+	scriptCode = scriptCode.RemoveCodeSeparators()
+	if anyoneCanPay {
+		varint.WriteVarInt(buf, 1)
+		writeSigHashInput(buf, tx.Vin[idx], scriptCode, tx.Vin[idx].Sequence)
+	} else {
+		varint.WriteVarInt(buf, uint64(len(tx.Vin)))
+		for i, ti := range tx.Vin {
+			var (
+				s        script.Script
+				sequence = ti.Sequence
+			)
+			if i == idx {
+				s = scriptCode
+			} else if hashSingle || hashNone {
+				// let the others update their inputs
+				sequence = 0
+			}
+			writeSigHashInput(buf, ti, s, sequence)
+		}
+	}
+
+	switch {
+	case hashNone:
+		varint.WriteVarInt(buf, 0)
+	case hashSingle:
+		varint.WriteVarInt(buf, uint64(idx+1))
+		for i := 0; i < idx; i++ {
+			NewTxOut(nil, -1).Marshal(buf)
+		}
+		tx.Vout[idx].Marshal(buf)
+	default:
+		varint.WriteVarInt(buf, uint64(len(tx.Vout)))
+		for _, to := range tx.Vout {
+			to.Marshal(buf)
+		}
+	}
+
+	binary.Write(buf, binary.LittleEndian, tx.LockTime)
+	binary.Write(buf, binary.LittleEndian, hashType)
+	return crypto.DoubleSha256(buf.Bytes()), nil
+}
+
+func writeSigHashInput(w io.Writer, ti *TxIn, s script.Script, sequence uint32) {
+	ti.Prevout.marshal(w)
+	s.Marshal(w)
+	binary.Write(w, binary.LittleEndian, sequence)
+}
+
+// witnessV0SignatureHash returns the BIP143 digest which commits to the amount
+// spent, the hashes of the cache are replaced with zero as hashType excludes
+// the inputs or outputs.
+func (c *SigHashCache) witnessV0SignatureHash(idx int, scriptCode script.Script, hashType uint32, amount int64) (crypto.Hash, error) {
+	tx := c.tx
+	if idx < 0 || idx >= len(tx.Vin) {
+		return crypto.Hash{}, ErrInputIndex
+	}
+	var (
+		anyoneCanPay = hashType&script.SighashAnyOneCanPay != 0
+		hashSingle   = hashType&sigHashMask == script.SigHashSingle
+		hashNone     = hashType&sigHashMask == script.SigHashNone
+
+		hashPrevouts, hashSequence, hashOutputs crypto.Hash
+	)
+	if !anyoneCanPay {
+		hashPrevouts = c.HashPrevouts
+	}
+	if !anyoneCanPay && !hashSingle && !hashNone {
+		hashSequence = c.HashSequence
+	}
+	if !hashSingle && !hashNone {
+		hashOutputs = c.HashOutputs
+	} else if hashSingle && idx < len(tx.Vout) {
+		buf := new(bytes.Buffer)
+		tx.Vout[idx].Marshal(buf)
+		hashOutputs = crypto.DoubleSha256(buf.Bytes())
+	}
+
+	ti := tx.Vin[idx]
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, tx.Version)
+	buf.Write(hashPrevouts[:])
+	buf.Write(hashSequence[:])
+	ti.Prevout.marshal(buf)
+	scriptCode.Marshal(buf)
+	binary.Write(buf, binary.LittleEndian, amount)
+	binary.Write(buf, binary.LittleEndian, ti.Sequence)
+	buf.Write(hashOutputs[:])
+	binary.Write(buf, binary.LittleEndian, tx.LockTime)
+	binary.Write(buf, binary.LittleEndian, hashType)
+	return crypto.DoubleSha256(buf.Bytes()), nil
+}
+
+// TaprootSignatureHash returns the BIP341 digest signed by the input idx of
+// the transaction for the key path spend (SigVersionTaproot) or a tapscript
+// (SigVersionTapscript). The spent outputs must be set by SetPrevOuts.
+func (c *SigHashCache) TaprootSignatureHash(idx int, hashType uint32, sigVersion int, execData *script.ExecData) (crypto.Hash, error) {
+	tx := c.tx
+	if idx < 0 || idx >= len(tx.Vin) {
+		return crypto.Hash{}, ErrInputIndex
+	}
+	if c.prevOuts == nil {
+		return crypto.Hash{}, ErrPrevOuts
+	}
+	var extFlag byte
+	switch sigVersion {
+	case script.SigVersionTaproot:
+	case script.SigVersionTapscript:
+		extFlag = 1
+	default:
+		return crypto.Hash{}, ErrSigVersion
+	}
+
+	// SIGHASH_DEFAULT (0) signs as SIGHASH_ALL
+	outputType := hashType & script.SigHashSingle
+	if hashType == 0 {
+		outputType = script.SigHashAll
+	}
+	anyoneCanPay := hashType&script.SighashAnyOneCanPay != 0
+	if hashType > 3 && (hashType < 0x81 || hashType > 0x83) {
+		return crypto.Hash{}, ErrHashType
+	}
+	if outputType == script.SigHashSingle && idx >= len(tx.Vout) {
+		return crypto.Hash{}, ErrHashType
+	}
+
+	// the epoch of the digest
+	buf := bytes.NewBuffer([]byte{0x00})
+	buf.WriteByte(byte(hashType))
+	binary.Write(buf, binary.LittleEndian, tx.Version)
+	binary.Write(buf, binary.LittleEndian, tx.LockTime)
+	if !anyoneCanPay {
+		buf.Write(c.shaPrevouts[:])
+		buf.Write(c.shaAmounts[:])
+		buf.Write(c.shaScriptPubkeys[:])
+		buf.Write(c.shaSequences[:])
+	}
+	if outputType != script.SigHashNone && outputType != script.SigHashSingle {
+		buf.Write(c.shaOutputs[:])
+	}
+
+	spendType := extFlag << 1
+	if execData.AnnexPresent {
+		spendType |= 1
+	}
+	buf.WriteByte(spendType)
+	ti := tx.Vin[idx]
+	if anyoneCanPay {
+		ti.Prevout.marshal(buf)
+		binary.Write(buf, binary.LittleEndian, c.prevOuts[idx].Value)
+		c.prevOuts[idx].ScriptPubkey.Marshal(buf)
+		binary.Write(buf, binary.LittleEndian, ti.Sequence)
+	} else {
+		binary.Write(buf, binary.LittleEndian, uint32(idx))
+	}
+	if execData.AnnexPresent {
+		buf.Write(execData.AnnexHash[:])
+	}
+	if outputType == script.SigHashSingle {
+		out := new(bytes.Buffer)
+		tx.Vout[idx].Marshal(out)
+		h := crypto.Sha256(out.Bytes())
+		buf.Write(h[:])
+	}
+
+	if sigVersion == script.SigVersionTapscript {
+		buf.Write(execData.TapleafHash[:])
+		// the key version of the BIP340 public keys
+		buf.WriteByte(0x00)
+		binary.Write(buf, binary.LittleEndian, execData.CodeSeparatorPos)
+	}
+	return crypto.TaggedHash("TapSighash", buf.Bytes()), nil
+}
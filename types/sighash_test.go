@@ -0,0 +1,112 @@
+package types
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/maiiz/coinlib/script"
+)
+
+// TestSignatureHashLegacy runs the sighash.json vectors of Bitcoin Core:
+// [raw_transaction, script, input_index, hashType, signature_hash].
+func TestSignatureHashLegacy(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/sighash.json")
+	if err != nil {
+		t.Fatalf("read sighash.json error %v", err)
+	}
+	var tests [][]interface{}
+	if err := json.Unmarshal(data, &tests); err != nil {
+		t.Fatalf("parse sighash.json error %v", err)
+	}
+
+	for i, test := range tests {
+		if len(test) != 5 {
+			// comment
+			continue
+		}
+		raw, _ := hex.DecodeString(test[0].(string))
+		scriptCode, _ := hex.DecodeString(test[1].(string))
+		idx := int(test[2].(float64))
+		hashType := uint32(int32(test[3].(float64)))
+
+		tx := new(Transaction)
+		if err := tx.Unmarshal(bytes.NewReader(raw)); err != nil {
+			t.Errorf("#%d Unmarshal error %v", i, err)
+			continue
+		}
+		h, err := SignatureHash(tx, idx, scriptCode, hashType, 0, script.SigVersionBase)
+		if err != nil {
+			t.Errorf("#%d SignatureHash error %v", i, err)
+			continue
+		}
+		if got := h.Reverse().String(); got != test[4].(string) {
+			t.Errorf("#%d SignatureHash = %s, except %s", i, got, test[4])
+		}
+	}
+}
+
+// BIP143 examples of native P2WPKH and P2SH-P2WPKH.
+var witnessSigHashTests = []struct {
+	raw        string
+	idx        int
+	scriptCode string
+	amount     int64
+	sigHash    string
+}{
+	{
+		"0100000002fff7f7881a8099afa6940d42d1e7f6362bec38171ea3edf433541db4e4ad969f0000000000eeffffffef51e1b804cc89d182d279655c3aa89e815b1b309fe287d9b2b55d57b90ec68a0100000000ffffffff02202cb206000000001976a9148280b37df378db99f66f85c95a783a76ac7a6d5988ac9093510d000000001976a9143bde42dbee7e4dbe6a21b2d50ce2f0167faa815988ac11000000",
+		1, "76a9141d0f172a0ecb48aee1be1f2687d2963ae33f71a188ac", 600000000,
+		"c37af31116d1b27caf68aae9e3ac82f1477929014d5b917657d0eb49478cb670",
+	},
+	{
+		"0100000001db6b1b20aa0fd7b23880be2ecbd4a98130974cf4748fb66092ac4d3ceb1a54770100000000feffffff02b8b4eb0b000000001976a914a457b684d7f0d539a46a45bbc043f35b59d0d96388ac0008af2f000000001976a914fd270b1ee6abcaea97fea7ad0402e8bd8ad6d77c88ac92040000",
+		0, "76a91479091972186c449eb1ded22b78e40d009bdf008988ac", 1000000000,
+		"64f3b0f4dd2bb3aa1ce8566d220cc74dda9df97d8490cc81d89d735c92e59fb6",
+	},
+}
+
+func TestSignatureHashWitnessV0(t *testing.T) {
+	for i, test := range witnessSigHashTests {
+		raw, _ := hex.DecodeString(test.raw)
+		scriptCode, _ := hex.DecodeString(test.scriptCode)
+		tx := new(Transaction)
+		if err := tx.Unmarshal(bytes.NewReader(raw)); err != nil {
+			t.Fatalf("#%d Unmarshal error %v", i, err)
+		}
+
+		h, err := SignatureHash(tx, test.idx, scriptCode, script.SigHashAll, test.amount, script.SigVersionWitnessV0)
+		if err != nil || h.String() != test.sigHash {
+			t.Errorf("#%d SignatureHash = %s, except %s, err %v", i, h, test.sigHash, err)
+		}
+
+		// the amount is committed.
+		cache := NewSigHashCache(tx)
+		h1, _ := cache.SignatureHash(test.idx, scriptCode, script.SigHashAll, test.amount+1, script.SigVersionWitnessV0)
+		if h1.Equal(h) {
+			t.Errorf("#%d SignatureHash does not commit to amount", i)
+		}
+	}
+
+	tx := new(Transaction)
+	raw, _ := hex.DecodeString(witnessSigHashTests[0].raw)
+	tx.Unmarshal(bytes.NewReader(raw))
+	if _, err := SignatureHash(tx, 2, nil, script.SigHashAll, 0, script.SigVersionWitnessV0); err != ErrInputIndex {
+		t.Errorf("SignatureHash of input 2 error %v, except %v", err, ErrInputIndex)
+	}
+}
+
+func TestSignatureHashSingleBug(t *testing.T) {
+	raw, _ := hex.DecodeString(txTests[2].raw)
+	tx := new(Transaction)
+	if err := tx.Unmarshal(bytes.NewReader(raw)); err != nil {
+		t.Fatalf("Unmarshal error %v", err)
+	}
+	// 4 inputs and 2 outputs.
+	h, err := SignatureHash(tx, 3, nil, script.SigHashSingle, 0, script.SigVersionBase)
+	if err != nil || h != sigHashOne {
+		t.Errorf("SignatureHash of SIGHASH_SINGLE without output = %s, except %s", h, sigHashOne)
+	}
+}
@@ -0,0 +1,115 @@
+package types
+
+import (
+	"errors"
+
+	"github.com/maiiz/coinlib/encoding/varint"
+)
+
+// WitnessScaleFactor is the weight of a non-witness byte, witness bytes weigh 1.
+const WitnessScaleFactor = 4
+
+var (
+	ErrPrevValues  = errors.New("prevout values not match inputs")
+	ErrNegativeFee = errors.New("outputs exceed inputs")
+)
+
+// SerializeSize returns the size of the transaction serialized by Marshal.
+func (tx *Transaction) SerializeSize() int {
+	n := tx.SerializeSizeStripped()
+	if tx.HasWitness() {
+		n += len(MarkerFlag)
+		for _, ti := range tx.Vin {
+			n += ti.Witness.SerializeSize()
+		}
+	}
+	return n
+}
+
+// SerializeSizeStripped returns the size of the transaction without witness.
+func (tx *Transaction) SerializeSizeStripped() int {
+	// version and locktime
+	n := 8 + varIntSize(len(tx.Vin)) + varIntSize(len(tx.Vout))
+	for _, ti := range tx.Vin {
+		n += ti.SerializeSize()
+	}
+	for _, to := range tx.Vout {
+		n += to.SerializeSize()
+	}
+	return n
+}
+
+// Weight returns the BIP141 weight of the transaction.
+func (tx *Transaction) Weight() int {
+	stripped := tx.SerializeSizeStripped()
+	return stripped*(WitnessScaleFactor-1) + tx.SerializeSize()
+}
+
+// VSize returns the virtual size of the transaction, the weight divided by 4
+// rounded up.
+func (tx *Transaction) VSize() int {
+	return (tx.Weight() + WitnessScaleFactor - 1) / WitnessScaleFactor
+}
+
+// Fee returns the fee paid by the transaction, prevValues are the values of
+// the outputs spent by the inputs in order.
+func (tx *Transaction) Fee(prevValues []int64) (int64, error) {
+	if len(prevValues) != len(tx.Vin) {
+		return 0, ErrPrevValues
+	}
+	var fee int64
+	for _, v := range prevValues {
+		fee += v
+	}
+	for _, to := range tx.Vout {
+		fee -= to.Value
+	}
+	if fee < 0 {
+		return 0, ErrNegativeFee
+	}
+	return fee, nil
+}
+
+// FeeRate returns the fee rate of the transaction in satoshis per virtual byte.
+func (tx *Transaction) FeeRate(prevValues []int64) (float64, error) {
+	fee, err := tx.Fee(prevValues)
+	if err != nil {
+		return 0, err
+	}
+	return float64(fee) / float64(tx.VSize()), nil
+}
+
+// FeeForRate returns the fee for the transaction to pay rate satoshis per
+// virtual byte, rounded up. The inputs should carry signatures or placeholders
+// of the same size for an accurate estimation.
+func (tx *Transaction) FeeForRate(rate float64) int64 {
+	fee := rate * float64(tx.VSize())
+	if n := int64(fee); float64(n) < fee {
+		return n + 1
+	}
+	return int64(fee)
+}
+
+// SerializeSize returns the serialized size of the input without witness.
+func (ti *TxIn) SerializeSize() int {
+	// outpoint and sequence
+	return 32 + 4 + varIntSize(ti.ScriptSig.Size()) + ti.ScriptSig.Size() + 4
+}
+
+// SerializeSize returns the serialized size of the output.
+func (to *TxOut) SerializeSize() int {
+	return 8 + varIntSize(to.ScriptPubkey.Size()) + to.ScriptPubkey.Size()
+}
+
+// SerializeSize returns the serialized size of the witness stack.
+func (tw TxWitness) SerializeSize() int {
+	n := varIntSize(len(tw))
+	for _, item := range tw {
+		n += varIntSize(len(item)) + len(item)
+	}
+	return n
+}
+
+func varIntSize(n int) int {
+	return len(varint.VarInt(uint64(n)))
+}
@@ -0,0 +1,71 @@
+package types
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestSerializeSize(t *testing.T) {
+	for i, test := range txTests {
+		raw, _ := hex.DecodeString(test.raw)
+		tx := new(Transaction)
+		if err := tx.Unmarshal(bytes.NewReader(raw)); err != nil {
+			t.Fatalf("#%d Unmarshal error %v", i, err)
+		}
+		buf := new(bytes.Buffer)
+		tx.MarshalNoWitness(buf)
+		stripped := buf.Len()
+
+		if n := tx.SerializeSize(); n != len(raw) {
+			t.Errorf("#%d SerializeSize = %d, except %d", i, n, len(raw))
+		}
+		if n := tx.SerializeSizeStripped(); n != stripped {
+			t.Errorf("#%d SerializeSizeStripped = %d, except %d", i, n, stripped)
+		}
+		if w := tx.Weight(); w != stripped*3+len(raw) {
+			t.Errorf("#%d Weight = %d, except %d", i, w, stripped*3+len(raw))
+		}
+		vsize := (stripped*3 + len(raw) + 3) / 4
+		if test.witness == nil {
+			vsize = len(raw)
+		}
+		if v := tx.VSize(); v != vsize {
+			t.Errorf("#%d VSize = %d, except %d", i, v, vsize)
+		}
+	}
+}
+
+func TestFee(t *testing.T) {
+	raw, _ := hex.DecodeString(txTests[3].raw)
+	tx := new(Transaction)
+	if err := tx.Unmarshal(bytes.NewReader(raw)); err != nil {
+		t.Fatalf("Unmarshal error %v", err)
+	}
+	prev := int64(1000)
+	for _, out := range tx.Vout {
+		prev += out.Value
+	}
+
+	fee, err := tx.Fee([]int64{prev})
+	if err != nil || fee != 1000 {
+		t.Errorf("Fee = %d, except 1000, err %v", fee, err)
+	}
+	rate, err := tx.FeeRate([]int64{prev})
+	if except := 1000 / float64(tx.VSize()); err != nil || rate != except {
+		t.Errorf("FeeRate = %f, except %f, err %v", rate, except, err)
+	}
+	if fee := tx.FeeForRate(rate); fee != 1000 {
+		t.Errorf("FeeForRate(%f) = %d, except 1000", rate, fee)
+	}
+	if fee := tx.FeeForRate(1.5); fee != int64(tx.VSize()*3+1)/2 {
+		t.Errorf("FeeForRate(1.5) = %d for vsize %d", fee, tx.VSize())
+	}
+
+	if _, err := tx.Fee(nil); err != ErrPrevValues {
+		t.Errorf("Fee without prevout values error %v, except %v", err, ErrPrevValues)
+	}
+	if _, err := tx.Fee([]int64{prev - 2000}); err != ErrNegativeFee {
+		t.Errorf("Fee of outputs exceeding inputs error %v, except %v", err, ErrNegativeFee)
+	}
+}
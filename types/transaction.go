@@ -1,7 +1,9 @@
 package types
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"io"
 
 	"github.com/maiiz/coinlib/encoding/varint"
@@ -40,9 +42,31 @@ const (
 	SequenceLocktimeGranularity = 9
 )
 
+const (
+	// maxTxPayload defines the maximum size of a serialized transaction, which
+	// is the maximum block weight, it bounds the lengths read in Unmarshal.
+	maxTxPayload = 4000000
+
+	// minTxInSize is the size of a input with an empty script.
+	minTxInSize = 32 + 4 + 1 + 4
+
+	// minTxOutSize is the size of a output with an empty script.
+	minTxOutSize = 8 + 1
+
+	// maxWitnessItems is the maximum number of witness items of an input.
+	maxWitnessItems = 500000
+)
+
 var (
 	// MarkerFlag defines the marker and flag in witness.
 	MarkerFlag = []byte{0x00, 0x01}
+
+	ErrWitnessFlag   = errors.New("invalid witness flag")
+	ErrEmptyWitness  = errors.New("witness flag with empty witness")
+	ErrTooManyTxIns  = errors.New("too many transaction inputs")
+	ErrTooManyTxOuts = errors.New("too many transaction outputs")
+	ErrTooManyItems  = errors.New("too many witness items")
+	ErrDataTooLarge  = errors.New("data too large")
 )
 
 // Transaction represents a transaction in blockchain.
@@ -51,7 +75,6 @@ type Transaction struct {
 	Vin      []*TxIn
 	Vout     []*TxOut
 	LockTime uint32
-	Witness  [][]byte
 }
 
 // TxIn represets An input of a transaction
@@ -61,8 +84,14 @@ type TxIn struct {
 	Prevout   *OutPoint
 	ScriptSig script.Script
 	Sequence  uint32
+	// Witness is the witness stack of a segwit input.
+	Witness TxWitness
 }
 
+// TxWitness represents the witness stack of a transaction input, the items
+// are pushed in order.
+type TxWitness [][]byte
+
 // TxOut defines a transaction output.
 type TxOut struct {
 	ScriptPubkey script.Script
@@ -96,16 +125,50 @@ func (ti TxIn) marshal(w io.Writer) {
 	binary.Write(w, binary.LittleEndian, ti.Sequence)
 }
 
+func (ti *TxIn) unmarshal(r io.Reader) error {
+	ti.Prevout = new(OutPoint)
+	if err := ti.Prevout.unmarshal(r); err != nil {
+		return err
+	}
+	scriptSig, err := readBytes(r, maxTxPayload)
+	if err != nil {
+		return err
+	}
+	ti.ScriptSig = scriptSig
+	return binary.Read(r, binary.LittleEndian, &ti.Sequence)
+}
+
 func (op OutPoint) marshal(w io.Writer) {
 	w.Write(op.Hash.Bytes())
 	binary.Write(w, binary.LittleEndian, op.Index)
 }
 
-func (to TxOut) marshal(w io.Writer) {
+func (op *OutPoint) unmarshal(r io.Reader) error {
+	if _, err := io.ReadFull(r, op.Hash[:]); err != nil {
+		return err
+	}
+	return binary.Read(r, binary.LittleEndian, &op.Index)
+}
+
+// Marshal encodes the output to writer.
+func (to TxOut) Marshal(w io.Writer) {
 	binary.Write(w, binary.LittleEndian, to.Value)
 	to.ScriptPubkey.Marshal(w)
 }
 
+// Unmarshal decodes reader to the output.
+func (to *TxOut) Unmarshal(r io.Reader) error {
+	if err := binary.Read(r, binary.LittleEndian, &to.Value); err != nil {
+		return err
+	}
+	scriptPubkey, err := readBytes(r, maxTxPayload)
+	if err != nil {
+		return err
+	}
+	to.ScriptPubkey = scriptPubkey
+	return nil
+}
+
 // AddTxIn adds a transaction input to the transaction.
 func (tx *Transaction) AddTxIn(ti *TxIn) {
 	tx.Vin = append(tx.Vin, ti)
@@ -116,12 +179,23 @@ func (tx *Transaction) AddTxOut(to *TxOut) {
 	tx.Vout = append(tx.Vout, to)
 }
 
-// Marshal encodes transaction to writer.
+// Marshal encodes transaction to writer, the witness stacks are encoded as
+// BIP144 if any input has witness.
 func (tx *Transaction) Marshal(w io.Writer) {
+	tx.marshal(w, tx.HasWitness())
+}
+
+// MarshalNoWitness encodes transaction without the witness stacks, it is the
+// legacy serialization which the transaction id commits to.
+func (tx *Transaction) MarshalNoWitness(w io.Writer) {
+	tx.marshal(w, false)
+}
+
+func (tx *Transaction) marshal(w io.Writer, witness bool) {
 	binary.Write(w, binary.LittleEndian, tx.Version)
 
 	// marker & flag
-	if tx.HasWitness() {
+	if witness {
 		w.Write(MarkerFlag)
 	}
 
@@ -132,34 +206,183 @@ func (tx *Transaction) Marshal(w io.Writer) {
 
 	varint.WriteVarInt(w, uint64(len(tx.Vout)))
 	for _, to := range tx.Vout {
-		to.marshal(w)
+		to.Marshal(w)
 	}
 
-	if tx.HasWitness() {
-		for _, wit := range tx.Witness {
-			varint.WriteVarInt(w, uint64(len(wit)))
-			w.Write(wit)
+	if witness {
+		for _, ti := range tx.Vin {
+			ti.Witness.Marshal(w)
 		}
 	}
 
 	binary.Write(w, binary.LittleEndian, tx.LockTime)
 }
 
-// Unmarshal decodes reader to transaction.
+// Unmarshal decodes reader to transaction, a transaction whose input count is
+// followed by the witness flag is decoded with the witness stacks.
 func (tx *Transaction) Unmarshal(r io.Reader) error {
-	err := binary.Read(r, binary.LittleEndian, tx.Version)
-	if err != nil {
+	return tx.unmarshal(r, true)
+}
+
+// UnmarshalNoWitness decodes reader to transaction in the legacy
+// serialization, a transaction without inputs is not taken as witness marker.
+func (tx *Transaction) UnmarshalNoWitness(r io.Reader) error {
+	return tx.unmarshal(r, false)
+}
+
+func (tx *Transaction) unmarshal(r io.Reader, allowWitness bool) error {
+	if err := binary.Read(r, binary.LittleEndian, &tx.Version); err != nil {
 		return err
 	}
 
-	_, err = varint.ReadVarInt(r)
+	count, err := varint.ReadVarInt(r)
 	if err != nil {
 		return err
 	}
-	return nil
+
+	// marker & flag
+	var witness bool
+	if count == 0 && allowWitness {
+		flag := make([]byte, 1)
+		if _, err := io.ReadFull(r, flag); err != nil {
+			return err
+		}
+		if flag[0] != MarkerFlag[1] {
+			return ErrWitnessFlag
+		}
+		witness = true
+
+		if count, err = varint.ReadVarInt(r); err != nil {
+			return err
+		}
+	}
+
+	if count > maxTxPayload/minTxInSize {
+		return ErrTooManyTxIns
+	}
+	tx.Vin = make([]*TxIn, count)
+	for i := range tx.Vin {
+		ti := new(TxIn)
+		if err := ti.unmarshal(r); err != nil {
+			return err
+		}
+		tx.Vin[i] = ti
+	}
+
+	if count, err = varint.ReadVarInt(r); err != nil {
+		return err
+	}
+	if count > maxTxPayload/minTxOutSize {
+		return ErrTooManyTxOuts
+	}
+	tx.Vout = make([]*TxOut, count)
+	for i := range tx.Vout {
+		to := new(TxOut)
+		if err := to.Unmarshal(r); err != nil {
+			return err
+		}
+		tx.Vout[i] = to
+	}
+
+	if witness {
+		for _, ti := range tx.Vin {
+			if err := ti.Witness.Unmarshal(r); err != nil {
+				return err
+			}
+		}
+		// BIP144 requires the flag to be omitted if all witnesses are empty.
+		if !tx.HasWitness() {
+			return ErrEmptyWitness
+		}
+	}
+
+	return binary.Read(r, binary.LittleEndian, &tx.LockTime)
+}
+
+// TxHash returns the double sha256 of the transaction without witness, in the
+// byte order used by OutPoint.
+func (tx *Transaction) TxHash() crypto.Hash {
+	buf := new(bytes.Buffer)
+	tx.MarshalNoWitness(buf)
+	return crypto.DoubleSha256(buf.Bytes())
+}
+
+// WitnessHash returns the double sha256 of the transaction with witness, it
+// equals TxHash if the transaction has no witness.
+func (tx *Transaction) WitnessHash() crypto.Hash {
+	if !tx.HasWitness() {
+		return tx.TxHash()
+	}
+	buf := new(bytes.Buffer)
+	tx.Marshal(buf)
+	return crypto.DoubleSha256(buf.Bytes())
+}
+
+// TxID returns the transaction id shown by nodes and explorers, which is the
+// byte-reversed hex of TxHash.
+func (tx *Transaction) TxID() string {
+	h := tx.TxHash()
+	return h.Reverse().String()
+}
+
+// WTxID returns the byte-reversed hex of WitnessHash.
+func (tx *Transaction) WTxID() string {
+	h := tx.WitnessHash()
+	return h.Reverse().String()
 }
 
 // HasWitness returns the segwit flag of the transaction.
 func (tx Transaction) HasWitness() bool {
-	return len(tx.Witness) != 0
+	for _, ti := range tx.Vin {
+		if len(ti.Witness) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Marshal encodes the witness stack to writer.
+func (tw TxWitness) Marshal(w io.Writer) {
+	varint.WriteVarInt(w, uint64(len(tw)))
+	for _, item := range tw {
+		varint.WriteVarInt(w, uint64(len(item)))
+		w.Write(item)
+	}
+}
+
+// Unmarshal decodes reader to the witness stack.
+func (tw *TxWitness) Unmarshal(r io.Reader) error {
+	count, err := varint.ReadVarInt(r)
+	if err != nil {
+		return err
+	}
+	if count > maxWitnessItems {
+		return ErrTooManyItems
+	}
+
+	witness := make(TxWitness, count)
+	for i := range witness {
+		if witness[i], err = readBytes(r, maxTxPayload); err != nil {
+			return err
+		}
+	}
+	*tw = witness
+	return nil
+}
+
+// readBytes reads varint length prefixed bytes no longer than max.
+func readBytes(r io.Reader, max uint64) ([]byte, error) {
+	n, err := varint.ReadVarInt(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > max {
+		return nil, ErrDataTooLarge
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
 }
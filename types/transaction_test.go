@@ -0,0 +1,134 @@
+package types
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// Raw transactions of mainnet block 277647, and the first taproot key path
+// spend of mainnet block 709635.
+var txTests = []struct {
+	raw         string
+	txid, wtxid string
+	vin, vout   int
+	witness     []int
+}{
+	{
+		// coinbase
+		"01000000010000000000000000000000000000000000000000000000000000000000000000ffffffff53038f3c040400003d8b45124d696e656420627920425443204775696c642cfabe6d6d180ec2f9a5ff672bb0b3df6e14703defe4b6570194be38428122c0b001c5445b010000000000000008000008d700000dceffffffff014b424b95000000001976a91427a1f12771de5cc3b73941664b2537c15316be4388ac00000000",
+		"0fc1f998e6fc1fa43a879cea4a54fe9947e02b925ebc46237a2406c50e0f07ea",
+		"0fc1f998e6fc1fa43a879cea4a54fe9947e02b925ebc46237a2406c50e0f07ea",
+		1, 1, nil,
+	},
+	{
+		"01000000015848f8ac096da62cece1d74d0d071fedf4fc5e8ede79a6c0a238530bcc6fe589010000006a47304402206169c923b60214a5f8f120e1bd8b56d6dbbdd76235af8b0b90b7090058a10a210220106f86c066094ce38747dfafc9d83cbe33080c0879e7b6893fe9265d73b21b14012102470ef5c731b5d50f9f368a9902ed60c97f39628f4defaf3a4676ff19e949b3ecffffffff0200e1f505000000001976a914ef151e203f83bc68d21adf5f1c378bee1681c4ea88acdda9ed0e000000001976a914ce74f5d270a54f2c58ab42c912a1a78f677d17c788ac00000000",
+		"d88bca3658a3ca6a2fe7fd2b1ad19da2793fcf24617003eacad813322035e5a1",
+		"d88bca3658a3ca6a2fe7fd2b1ad19da2793fcf24617003eacad813322035e5a1",
+		1, 2, nil,
+	},
+	{
+		"01000000046b90d498bb60605bca98737e94730de9af90df324259c40f588a61bc64ccde4a010000006b483045022100abb6d0ef394caee02e154908d4834c4de44d4b50f6603a220ce1910594fc3e5402203a26d8e4d7d6a0c6fda782cae2fb3e39a4d0ca888874e89da43f7ed59cee45b301210201485a3acbd361ea4459ad618264204d60094460978ecd0f29c762d0c8315cc5ffffffffbab6c8fad21e09f4bf7730918a0200e2aa5f6cb5514777a1775d728dcf30def5010000006a47304402202a53dbdc02e5a3a0a41bc0cb3124a3ef9c2c82065f0ee85952f52669e5a368ce022000b5e358602285f385ecf27698af10d47b95685c0153f1fafaa708322045854a012103b4380edb87f5c428c51322c25cc035d80b40ad4a5bf5fd75083dcfcab2d09966ffffffffe449f5f459aeafd08bd3df3a4ca8394fdc1b9db46c070d2bd181c00217678ec7010000006a473044022049a733568ee42b56e738d0b801397b1cd0ee8f4b5fa5bd2f04dba84c25fa827f022027f80cc88a353b5e34334765f73b0fc80244b46b554ad3e82086bdaae85cdb4c012102b9b4010f1b6bdbacbb5cabb3917ad662f31f4ccf048f4853917fa7745f97e114ffffffffd28186c1701f0d1911cb02a60d9a47826f96d13f270cba02cc6ef24c29172c52000000006c493046022100b277c22fba08048cace3213edaa48c2229a0416ba5a33fb5f20ebfd5ea61e018022100d366aa57f562cb919dc1f4115c0ac53e65c14f095caa56a3fcda3aece7c7ca72012102d28e9b36ca015854d248c3cb655e4d1c80f57f88acac6d013deb678dc74667e4ffffffff021ebc9f00000000001976a9147a3fdef96c029eeec11061faa0b3a900b803125f88ac205b0f00000000001976a9140d803e298c1adf5307cc59153472f2ed3647a6eb88ac00000000",
+		"703d9e011c8a5223b0853ff1737f99c5c6f84cfd8becf5610d3ff1e9efbbfbdf",
+		"703d9e011c8a5223b0853ff1737f99c5c6f84cfd8becf5610d3ff1e9efbbfbdf",
+		4, 2, nil,
+	},
+	{
+		"01000000000101d1f1c1f8cdf6759167b90f52c9ad358a369f95284e841d7a2536cef31c0549580100000000fdffffff020000000000000000316a2f49206c696b65205363686e6f7272207369677320616e6420492063616e6e6f74206c69652e204062697462756734329e06010000000000225120a37c3903c8d0db6512e2b40b0dffa05e5a3ab73603ce8c9c4b7771e5412328f90140a60c383f71bac0ec919b1d7dbc3eb72dd56e7aa99583615564f9f99b8ae4e837b758773a5b2e4c51348854c8389f008e05029db7f464a5ff2e01d5e6e626174affd30a00",
+		"33e794d097969002ee05d336686fc03c9e15a597c1b9827669460fac98799036",
+		"af2fdc4c54270adfb2a65987a79ed2f0e771a779ea48bb0ef06095b48395f74d",
+		1, 2, []int{1},
+	},
+}
+
+func TestUnmarshal(t *testing.T) {
+	for i, test := range txTests {
+		raw, _ := hex.DecodeString(test.raw)
+		tx := new(Transaction)
+		if err := tx.Unmarshal(bytes.NewReader(raw)); err != nil {
+			t.Errorf("#%d Unmarshal error %v", i, err)
+			continue
+		}
+		if len(tx.Vin) != test.vin || len(tx.Vout) != test.vout || tx.Version != 1 {
+			t.Errorf("#%d Unmarshal got version %d, %d inputs, %d outputs", i, tx.Version, len(tx.Vin), len(tx.Vout))
+		}
+		if tx.HasWitness() != (test.witness != nil) {
+			t.Errorf("#%d HasWitness = %v", i, tx.HasWitness())
+		}
+		for j, n := range test.witness {
+			if len(tx.Vin[j].Witness) != n {
+				t.Errorf("#%d input %d has %d witness items, except %d", i, j, len(tx.Vin[j].Witness), n)
+			}
+		}
+
+		buf := new(bytes.Buffer)
+		tx.Marshal(buf)
+		if !bytes.Equal(buf.Bytes(), raw) {
+			t.Errorf("#%d Marshal = %x, except %s", i, buf.Bytes(), test.raw)
+		}
+	}
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+	raw, _ := hex.DecodeString(txTests[3].raw)
+
+	// truncated transactions.
+	for _, n := range []int{3, 6, 50, len(raw) - 1} {
+		if err := new(Transaction).Unmarshal(bytes.NewReader(raw[:n])); err == nil {
+			t.Errorf("Unmarshal of %d bytes succeeds", n)
+		}
+	}
+
+	flag := append([]byte{}, raw...)
+	flag[5] = 0x02
+	if err := new(Transaction).Unmarshal(bytes.NewReader(flag)); err != ErrWitnessFlag {
+		t.Errorf("Unmarshal with flag 0x02 error %v, except %v", err, ErrWitnessFlag)
+	}
+}
+
+func TestTxID(t *testing.T) {
+	for i, test := range txTests {
+		raw, _ := hex.DecodeString(test.raw)
+		tx := new(Transaction)
+		if err := tx.Unmarshal(bytes.NewReader(raw)); err != nil {
+			t.Fatalf("#%d Unmarshal error %v", i, err)
+		}
+		if txid := tx.TxID(); txid != test.txid {
+			t.Errorf("#%d TxID = %s, except %s", i, txid, test.txid)
+		}
+		if wtxid := tx.WTxID(); wtxid != test.wtxid {
+			t.Errorf("#%d WTxID = %s, except %s", i, wtxid, test.wtxid)
+		}
+		if h := tx.TxHash(); h.Reverse().String() != test.txid {
+			t.Errorf("#%d TxHash is not the reversed TxID", i)
+		}
+
+		// the legacy serialization drops marker, flag and witness.
+		buf := new(bytes.Buffer)
+		tx.MarshalNoWitness(buf)
+		if test.witness == nil && !bytes.Equal(buf.Bytes(), raw) {
+			t.Errorf("#%d MarshalNoWitness = %x, except %s", i, buf.Bytes(), test.raw)
+		}
+		if test.witness != nil && len(buf.Bytes()) >= len(raw) {
+			t.Errorf("#%d MarshalNoWitness keeps the witness", i)
+		}
+	}
+}
+
+func TestEmptyWitness(t *testing.T) {
+	raw, _ := hex.DecodeString(txTests[3].raw)
+	tx := new(Transaction)
+	if err := tx.Unmarshal(bytes.NewReader(raw)); err != nil {
+		t.Fatalf("Unmarshal error %v", err)
+	}
+
+	tx.Vin[0].Witness = TxWitness{}
+	if tx.HasWitness() {
+		t.Errorf("HasWitness of empty witness = true")
+	}
+	buf := new(bytes.Buffer)
+	tx.marshal(buf, true)
+	if err := new(Transaction).Unmarshal(bytes.NewReader(buf.Bytes())); err != ErrEmptyWitness {
+		t.Errorf("Unmarshal of superfluous witness flag error %v, except %v", err, ErrEmptyWitness)
+	}
+}
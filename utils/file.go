@@ -3,6 +3,7 @@ package utils
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 )
 
 // OpenFile opens or creates a file
@@ -34,3 +35,38 @@ func WriteToFile(d []byte, fileTo string) error {
 
 	return nil
 }
+
+// WriteFileAtomic writes data to a temporary file, syncs it to disk and
+// renames it to fileTo, so fileTo holds either the old or the new data.
+func WriteFileAtomic(d []byte, fileTo string) error {
+	tmp := fileTo + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(d); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, fileTo); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// sync the directory to persist the rename.
+	if dir, err := os.Open(filepath.Dir(fileTo)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
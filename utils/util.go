@@ -7,6 +7,7 @@ import (
 	"encoding/hex"
 	"io"
 	"math"
+	"math/big"
 	"time"
 )
 
@@ -61,7 +62,16 @@ func ByteToFloat64(bytes []byte) float64 {
 
 // ZeroMemory erases a slice memory
 func ZeroMemory(s interface{}) {
-	if v, ok := s.([]interface{}); ok {
+	switch v := s.(type) {
+	case []byte:
+		for i := range v {
+			v[i] = 0
+		}
+	case []big.Word:
+		for i := range v {
+			v[i] = 0
+		}
+	case []interface{}:
 		for i := range v {
 			v[i] = 0
 		}